@@ -9,6 +9,7 @@ import (
 	"runtime/trace"
 	"slices"
 	"strings"
+	"time"
 )
 
 // A VersionControlSystem/VCS is a tool that tracks changes to files over time.
@@ -64,6 +65,180 @@ type Repository interface {
 	Update(ctx context.Context, workUnitName string) error
 }
 
+// Deleter is an optional interface for Repositories that can delete work
+// units.
+type Deleter interface {
+	// Delete the work unit with the given name. It's an error to delete the
+	// current work unit.
+	// e.g. Delete the named branch.
+	Delete(ctx context.Context, workUnitName string) error
+}
+
+// Delete deletes the given work unit of repo.
+// Returns an error wrapping errors.ErrUnsupported if repo isn't a Deleter.
+func Delete(ctx context.Context, repo Repository, workUnitName string) error {
+	d, ok := repo.(Deleter)
+	if !ok {
+		return fmt.Errorf("%s can't delete %ss: %w", repo.VCS().Name(), repo.VCS().WorkUnitName(), errors.ErrUnsupported)
+	}
+	return d.Delete(ctx, workUnitName)
+}
+
+// Stacker is an optional interface for Repositories whose work units can be
+// based on one another.
+type Stacker interface {
+	// Parents returns the name of the work unit that each of the repository's
+	// work units is based on, or "" if it isn't based on another work unit.
+	// e.g. The branch that another branch was created from.
+	Parents(ctx context.Context) (map[string]string, error)
+}
+
+// Parents returns the parent of each of repo's work units, keyed by work unit.
+// Returns an error wrapping errors.ErrUnsupported if repo isn't a Stacker.
+func Parents(ctx context.Context, repo Repository) (map[string]string, error) {
+	s, ok := repo.(Stacker)
+	if !ok {
+		return nil, fmt.Errorf("%s can't tell which %ss are based on each other: %w", repo.VCS().Name(), repo.VCS().WorkUnitName(), errors.ErrUnsupported)
+	}
+	return s.Parents(ctx)
+}
+
+// StatusChecker is an optional interface for Repositories that can describe the
+// state of their work units.
+type StatusChecker interface {
+	// Status describes the state of each of the given work units, keyed by work
+	// unit. It's an error if any of them don't exist.
+	Status(ctx context.Context, workUnitNames []string) (map[string]WorkUnitStatus, error)
+}
+
+// Status describes the state of the given work units of repo, keyed by work
+// unit.
+// Returns an error wrapping errors.ErrUnsupported if repo isn't a
+// StatusChecker.
+func Status(ctx context.Context, repo Repository, workUnitNames []string) (map[string]WorkUnitStatus, error) {
+	sc, ok := repo.(StatusChecker)
+	if !ok {
+		return nil, fmt.Errorf("%s can't describe the status of %ss: %w", repo.VCS().Name(), repo.VCS().WorkUnitName(), errors.ErrUnsupported)
+	}
+	return sc.Status(ctx, workUnitNames)
+}
+
+// Logger is an optional interface for Repositories that can summarize the
+// changes in their work units.
+type Logger interface {
+	// Log returns one line summaries of the n most recent changes in the given
+	// work unit, newest first.
+	// e.g. git log --oneline
+	Log(ctx context.Context, workUnitName string, n int) ([]string, error)
+}
+
+// Log summarizes the n most recent changes in the given work unit of repo.
+// Returns an error wrapping errors.ErrUnsupported if repo isn't a Logger.
+func Log(ctx context.Context, repo Repository, workUnitName string, n int) ([]string, error) {
+	l, ok := repo.(Logger)
+	if !ok {
+		return nil, fmt.Errorf("%s can't summarize the changes in %ss: %w", repo.VCS().Name(), repo.VCS().WorkUnitName(), errors.ErrUnsupported)
+	}
+	return l.Log(ctx, workUnitName, n)
+}
+
+// MergeChecker is an optional interface for Repositories that can tell which
+// work units have been merged into the repository's trunk.
+type MergeChecker interface {
+	// Merged returns the work units whose changes are all in the repository's
+	// trunk, not including the trunk itself. Implementations should only return
+	// work units they're sure about, since they might be deleted.
+	// e.g. Branches that were merged into main, or squashed into a single commit
+	// on it.
+	Merged(ctx context.Context) ([]string, error)
+}
+
+// Merged returns the work units of repo that have been merged into its trunk.
+// Returns an error wrapping errors.ErrUnsupported if repo isn't a
+// MergeChecker.
+func Merged(ctx context.Context, repo Repository) ([]string, error) {
+	mc, ok := repo.(MergeChecker)
+	if !ok {
+		return nil, fmt.Errorf("%s can't tell which %ss are merged: %w", repo.VCS().Name(), repo.VCS().WorkUnitName(), errors.ErrUnsupported)
+	}
+	return mc.Merged(ctx)
+}
+
+// ChangeTimer is an optional interface for Repositories that can tell when work
+// units last changed.
+type ChangeTimer interface {
+	// LastChanged returns when the most recent change in the given work unit was
+	// made.
+	// e.g. The commit date of the branch's latest commit.
+	LastChanged(ctx context.Context, workUnitName string) (time.Time, error)
+}
+
+// LastChanged returns when the given work unit of repo last changed.
+// Returns an error wrapping errors.ErrUnsupported if repo isn't a ChangeTimer.
+func LastChanged(ctx context.Context, repo Repository, workUnitName string) (time.Time, error) {
+	ct, ok := repo.(ChangeTimer)
+	if !ok {
+		return time.Time{}, fmt.Errorf("%s can't tell when %ss changed: %w", repo.VCS().Name(), repo.VCS().WorkUnitName(), errors.ErrUnsupported)
+	}
+	return ct.LastChanged(ctx, workUnitName)
+}
+
+// CheckoutHookInstaller is an optional interface for Repositories that can run
+// a command whenever a work unit is checked out, even by the VCS's own tools.
+type CheckoutHookInstaller interface {
+	// InstallCheckoutHook arranges for the shell command to be run in the
+	// repository's root directory after another work unit is checked out.
+	// e.g. A git post-checkout hook.
+	// Returns an error wrapping fs.ErrExist if there's already a hook that
+	// wasn't installed by this tool, unless force is true.
+	InstallCheckoutHook(ctx context.Context, command string, force bool) error
+}
+
+// InstallCheckoutHook arranges for command to run whenever a work unit of repo
+// is checked out.
+// Returns an error wrapping errors.ErrUnsupported if repo isn't a
+// CheckoutHookInstaller.
+func InstallCheckoutHook(ctx context.Context, repo Repository, command string, force bool) error {
+	hi, ok := repo.(CheckoutHookInstaller)
+	if !ok {
+		return fmt.Errorf("%s doesn't support checkout hooks: %w", repo.VCS().Name(), errors.ErrUnsupported)
+	}
+	return hi.InstallCheckoutHook(ctx, command, force)
+}
+
+// StateFiler is an optional interface for Repositories whose state is stored
+// in files that can be watched for changes.
+type StateFiler interface {
+	// StateFiles returns the files and directories that change when the
+	// repository's current work unit changes, or when its work units are
+	// created, deleted, or renamed. Files in the directories might be nested.
+	// They might not exist yet.
+	// e.g. .git/HEAD and .git/refs/heads
+	StateFiles(ctx context.Context) ([]string, error)
+}
+
+// StateFiles returns the files that describe the state of repo.
+// Returns an error wrapping errors.ErrUnsupported if repo isn't a StateFiler.
+func StateFiles(ctx context.Context, repo Repository) ([]string, error) {
+	sf, ok := repo.(StateFiler)
+	if !ok {
+		return nil, fmt.Errorf("%s can't tell which files to watch: %w", repo.VCS().Name(), errors.ErrUnsupported)
+	}
+	return sf.StateFiles(ctx)
+}
+
+// WorkUnitStatus describes the state of a work unit.
+type WorkUnitStatus struct {
+	// Dirty is whether the work unit has uncommitted changes.
+	Dirty bool
+	// Ahead is the number of changes in the work unit that aren't in its
+	// upstream.
+	Ahead int
+	// Behind is the number of changes in the work unit's upstream that aren't in
+	// the work unit.
+	Behind int
+}
+
 type VersionControlSystems []VersionControlSystem
 
 var (
@@ -26,3 +26,22 @@ func PluginDir() (string, error) {
 func TraceDir() (string, error) {
 	return mkdir("trace")
 }
+
+// SnapshotFile returns the path of the file that tmux sessions are saved to.
+// The file might not exist.
+func SnapshotFile() (string, error) {
+	f, err := xdg.StateFile(filepath.Join("tmux-vcs-sync", "sessions.json"))
+	if err != nil {
+		return "", fmt.Errorf("could not find state directory: %w", err)
+	}
+	return f, nil
+}
+
+// LockDir returns the directory that lock files are kept in.
+func LockDir() (string, error) {
+	dir := filepath.Join(xdg.RuntimeDir, "tmux-vcs-sync")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("could not create lock directory: %w", err)
+	}
+	return dir, nil
+}
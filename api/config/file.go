@@ -0,0 +1,324 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/adrg/xdg"
+)
+
+// EnvPrefix is the prefix of environment variables that override settings in
+// the configuration file. The rest of the variable's name is the setting's
+// section and key, in upper case.
+// e.g. TMUX_VCS_SYNC_MENU_KEY_SHORTCUTS overrides key_shortcuts in [menu].
+const EnvPrefix = "TMUX_VCS_SYNC_"
+
+// Config is the configuration of tmux-vcs-sync.
+type Config struct {
+	Menu   Menu   `toml:"menu"`
+	Naming Naming `toml:"naming"`
+	Trace  Trace  `toml:"trace"`
+	Tmux   Tmux   `toml:"tmux"`
+	Lock   Lock   `toml:"lock"`
+	Daemon Daemon `toml:"daemon"`
+
+	// Repos override settings for particular repositories. When several of
+	// them match a repository, the later ones take precedence.
+	Repos []Repo `toml:"repo"`
+}
+
+type Menu struct {
+	// KeyShortcuts are the keys that display-menu assigns to sessions, in
+	// order. q can't be used since it closes the menu.
+	KeyShortcuts string `toml:"key_shortcuts"`
+}
+
+// Naming determines the tmux session names of work units.
+// The templates' syntax is described by tmux/state.Naming.
+type Naming struct {
+	// Qualified is the template for session names that need to say which
+	// repository they belong to.
+	Qualified string `toml:"qualified"`
+	// Unqualified is the template for session names that don't.
+	Unqualified string `toml:"unqualified"`
+	// Qualify is when to use Qualified: auto, always, or never.
+	Qualify string `toml:"qualify"`
+}
+
+type Trace struct {
+	// RecordAfter is how long a command can take before an execution trace of
+	// it is saved to TraceDir.
+	RecordAfter time.Duration `toml:"record_after"`
+}
+
+type Tmux struct {
+	// SocketName is the name of the tmux server's socket (tmux -L) to use when
+	// not already in tmux.
+	SocketName string `toml:"socket_name"`
+	// SocketPath is the path of the tmux server's socket (tmux -S) to use when
+	// not already in tmux.
+	SocketPath string `toml:"socket_path"`
+	// Servers are the sockets of other tmux servers whose sessions list and
+	// display-menu also include. Each is a socket name (tmux -L), or a socket
+	// path (tmux -S) if it contains a /.
+	Servers []string `toml:"servers"`
+}
+
+// Lock determines how tmux-vcs-sync processes wait for each other.
+type Lock struct {
+	// Timeout is how long a command waits for other tmux-vcs-sync processes
+	// to finish changing the same repository or tmux server.
+	Timeout time.Duration `toml:"timeout"`
+}
+
+// Daemon determines how the daemon reacts to changes.
+type Daemon struct {
+	// OnCheckout is what to do when a repository checks out a different work
+	// unit: switch (the clients that are displaying the repository's sessions
+	// to the work unit's session), or none.
+	OnCheckout string `toml:"on_checkout"`
+	// OnSessionRenamed is what to do when a tmux session is renamed outside of
+	// tmux-vcs-sync: rename (its work unit to match), or none.
+	OnSessionRenamed string `toml:"on_session_renamed"`
+	// MarkStale is whether to mark the tmux sessions whose work units no longer
+	// exist.
+	MarkStale bool `toml:"mark_stale"`
+}
+
+// Repo is a section of settings that only apply to some repositories.
+type Repo struct {
+	// MatchName matches repositories with this name.
+	MatchName string `toml:"match_name,omitempty"`
+	// MatchPath matches repositories whose root directory matches this pattern.
+	// The pattern uses filepath.Match syntax, and may start with ~.
+	MatchPath string `toml:"match_path,omitempty"`
+
+	RepoSettings
+}
+
+// RepoSettings are the settings that can differ between repositories.
+type RepoSettings struct {
+	// Name replaces the name of the repository in tmux session names.
+	Name string `toml:"name,omitempty"`
+}
+
+// Default returns the configuration used when there's no configuration file.
+func Default() *Config {
+	return &Config{
+		Menu: Menu{KeyShortcuts: "0123456789wertyuiopasdfghlzxcvbnm"},
+		Naming: Naming{
+			Qualified:   "{repo}>{work_unit}",
+			Unqualified: "{work_unit}",
+			Qualify:     "auto",
+		},
+		Trace: Trace{RecordAfter: 100 * time.Millisecond},
+		Lock:  Lock{Timeout: 10 * time.Second},
+		Daemon: Daemon{
+			OnCheckout:       "switch",
+			OnSessionRenamed: "rename",
+			MarkStale:        true,
+		},
+	}
+}
+
+// File returns the path of the configuration file. The file might not exist.
+func File() (string, error) {
+	f, err := xdg.ConfigFile(filepath.Join("tmux-vcs-sync", "config.toml"))
+	if err != nil {
+		return "", fmt.Errorf("could not find configuration directory: %w", err)
+	}
+	return f, nil
+}
+
+// Load reads the configuration file at path, if it exists, on top of the
+// default configuration, and then applies overrides from environment
+// variables.
+// Load returns an error if the resulting configuration isn't valid.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+	md, err := toml.DecodeFile(path, cfg)
+	if errors.Is(err, fs.ErrNotExist) {
+		md = toml.MetaData{}
+	} else if err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", path, err)
+	}
+
+	var errs []error
+	for _, k := range md.Undecoded() {
+		errs = append(errs, fmt.Errorf("unknown setting %s", k))
+	}
+	if err := cfg.applyEnv(os.LookupEnv); err != nil {
+		errs = append(errs, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("invalid configuration %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// applyEnv overrides settings with environment variables.
+func (cfg *Config) applyEnv(lookupEnv func(string) (string, bool)) error {
+	var errs []error
+	v := reflect.ValueOf(cfg).Elem()
+	for i := range v.NumField() {
+		section := v.Field(i)
+		if section.Kind() != reflect.Struct {
+			// Per-repository settings can't be set by environment variables.
+			continue
+		}
+		sectionName := tomlKey(v.Type().Field(i))
+		for j := range section.NumField() {
+			env := EnvPrefix + strings.ToUpper(sectionName+"_"+tomlKey(section.Type().Field(j)))
+			s, ok := lookupEnv(env)
+			if !ok {
+				continue
+			}
+			if err := setValue(section.Field(j), s); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", env, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func tomlKey(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("toml"), ",")
+	return name
+}
+
+func setValue(v reflect.Value, s string) error {
+	switch v.Interface().(type) {
+	case time.Duration:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported setting type %s", v.Type())
+		}
+		// Lists are comma-separated.
+		var elems []string
+		if s != "" {
+			elems = strings.Split(s, ",")
+		}
+		v.Set(reflect.ValueOf(elems).Convert(v.Type()))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(n))
+	default:
+		return fmt.Errorf("unsupported setting type %s", v.Type())
+	}
+	return nil
+}
+
+// Validate checks that the settings make sense together.
+func (cfg *Config) Validate() error {
+	var errs []error
+	if cfg.Menu.KeyShortcuts == "" {
+		errs = append(errs, fmt.Errorf("menu.key_shortcuts must not be empty"))
+	}
+	seen := make(map[rune]bool)
+	for _, r := range cfg.Menu.KeyShortcuts {
+		if seen[r] {
+			errs = append(errs, fmt.Errorf("menu.key_shortcuts contains %q more than once", r))
+		}
+		seen[r] = true
+	}
+	if strings.ContainsRune(cfg.Menu.KeyShortcuts, 'q') {
+		errs = append(errs, fmt.Errorf("menu.key_shortcuts must not contain %q, which closes the menu", 'q'))
+	}
+	if cfg.Trace.RecordAfter < 0 {
+		errs = append(errs, fmt.Errorf("trace.record_after must not be negative"))
+	}
+	if cfg.Lock.Timeout < 0 {
+		errs = append(errs, fmt.Errorf("lock.timeout must not be negative"))
+	}
+	if cfg.Tmux.SocketName != "" && cfg.Tmux.SocketPath != "" {
+		errs = append(errs, fmt.Errorf("only one of tmux.socket_name and tmux.socket_path can be set"))
+	}
+	for i, srv := range cfg.Tmux.Servers {
+		if srv == "" {
+			errs = append(errs, fmt.Errorf("tmux.servers #%d must not be empty", i+1))
+		}
+	}
+	if c := cfg.Daemon.OnCheckout; c != "switch" && c != "none" {
+		errs = append(errs, fmt.Errorf("daemon.on_checkout must be switch or none, not %q", c))
+	}
+	if r := cfg.Daemon.OnSessionRenamed; r != "rename" && r != "none" {
+		errs = append(errs, fmt.Errorf("daemon.on_session_renamed must be rename or none, not %q", r))
+	}
+	for i, r := range cfg.Repos {
+		if (r.MatchName == "") == (r.MatchPath == "") {
+			errs = append(errs, fmt.Errorf("repo #%d: exactly one of match_name and match_path must be set", i+1))
+		}
+		if r.MatchPath != "" {
+			if _, err := filepath.Match(r.MatchPath, ""); err != nil {
+				errs = append(errs, fmt.Errorf("repo #%d: match_path %q: %w", i+1, r.MatchPath, err))
+			}
+		}
+		if r.RepoSettings == (RepoSettings{}) {
+			errs = append(errs, fmt.Errorf("repo #%d: doesn't override any settings", i+1))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ForRepo returns the settings for the repository with the given name and root
+// directory.
+func (cfg *Config) ForRepo(name, rootDir string) RepoSettings {
+	var s RepoSettings
+	for _, r := range cfg.Repos {
+		if !r.matches(name, rootDir) {
+			continue
+		}
+		if r.Name != "" {
+			s.Name = r.Name
+		}
+	}
+	return s
+}
+
+func (r Repo) matches(name, rootDir string) bool {
+	if r.MatchName != "" {
+		return r.MatchName == name
+	}
+	pattern := r.MatchPath
+	if rest, ok := strings.CutPrefix(pattern, "~"); ok && (rest == "" || rest[0] == filepath.Separator) {
+		pattern = xdg.Home + rest
+	}
+	ok, err := filepath.Match(filepath.Clean(pattern), filepath.Clean(rootDir))
+	return err == nil && ok
+}
+
+// Encode writes the configuration as TOML.
+func (cfg *Config) Encode(w io.Writer) error {
+	return toml.NewEncoder(w).Encode(cfg)
+}
@@ -0,0 +1,160 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func writeConfig(t *testing.T, s string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte(s), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	path := writeConfig(t, `
+[menu]
+key_shortcuts = "abc"
+
+[naming]
+qualify = "always"
+
+[tmux]
+socket_name = "work"
+
+[lock]
+timeout = "1m"
+
+[daemon]
+on_checkout = "none"
+
+[[repo]]
+match_name = "foo"
+name = "bar"
+`)
+	t.Setenv(EnvPrefix+"TRACE_RECORD_AFTER", "1s")
+	t.Setenv(EnvPrefix+"MENU_KEY_SHORTCUTS", "xyz")
+	t.Setenv(EnvPrefix+"TMUX_SERVERS", "personal,/tmp/shared")
+
+	got, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() = _, %v", err)
+	}
+	want := &Config{
+		Menu: Menu{KeyShortcuts: "xyz"},
+		Naming: Naming{
+			Qualified:   "{repo}>{work_unit}",
+			Unqualified: "{work_unit}",
+			Qualify:     "always",
+		},
+		Trace: Trace{RecordAfter: time.Second},
+		Tmux:  Tmux{SocketName: "work", Servers: []string{"personal", "/tmp/shared"}},
+		Lock:  Lock{Timeout: time.Minute},
+		Daemon: Daemon{
+			OnCheckout:       "none",
+			OnSessionRenamed: "rename",
+			MarkStale:        true,
+		},
+		Repos: []Repo{{MatchName: "foo", RepoSettings: RepoSettings{Name: "bar"}}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Load() diff (-want +got)\n%s", diff)
+	}
+}
+
+func TestLoad_NoFile(t *testing.T) {
+	got, err := Load(filepath.Join(t.TempDir(), "config.toml"))
+	if err != nil {
+		t.Fatalf("Load() = _, %v", err)
+	}
+	if diff := cmp.Diff(Default(), got); diff != "" {
+		t.Errorf("Load() diff (-want +got)\n%s", diff)
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		config string
+		env    map[string]string
+	}{
+		{
+			name:   "UnknownSetting",
+			config: "[menu]\nkeys = \"abc\"",
+		},
+		{
+			name:   "DuplicateKeyShortcut",
+			config: "[menu]\nkey_shortcuts = \"aba\"",
+		},
+		{
+			name:   "ReservedKeyShortcut",
+			config: "[menu]\nkey_shortcuts = \"abq\"",
+		},
+		{
+			name:   "BothSockets",
+			config: "[tmux]\nsocket_name = \"a\"\nsocket_path = \"/tmp/a\"",
+		},
+		{
+			name:   "EmptyServer",
+			config: "[tmux]\nservers = [\"a\", \"\"]",
+		},
+		{
+			name:   "NegativeLockTimeout",
+			config: "[lock]\ntimeout = \"-1s\"",
+		},
+		{
+			name:   "BadDaemonReaction",
+			config: "[daemon]\non_checkout = \"attach\"",
+		},
+		{
+			name:   "RepoWithoutMatch",
+			config: "[[repo]]\nname = \"a\"",
+		},
+		{
+			name:   "RepoWithoutSettings",
+			config: "[[repo]]\nmatch_name = \"a\"",
+		},
+		{
+			name: "BadEnv",
+			env:  map[string]string{EnvPrefix + "TRACE_RECORD_AFTER": "soon"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			if cfg, err := Load(writeConfig(t, tc.config)); err == nil {
+				t.Errorf("Load() = %+v, nil, want error", cfg)
+			}
+		})
+	}
+}
+
+func TestForRepo(t *testing.T) {
+	cfg := &Config{Repos: []Repo{
+		{MatchPath: "/src/*", RepoSettings: RepoSettings{Name: "src"}},
+		{MatchName: "foo", RepoSettings: RepoSettings{Name: "bar"}},
+	}}
+	for _, tc := range []struct {
+		name, rootDir string
+		want          RepoSettings
+	}{
+		{"baz", "/home/baz", RepoSettings{}},
+		{"baz", "/src/baz", RepoSettings{Name: "src"}},
+		{"baz", "/src/baz/", RepoSettings{Name: "src"}},
+		{"foo", "/home/foo", RepoSettings{Name: "bar"}},
+		// Later sections take precedence.
+		{"foo", "/src/foo", RepoSettings{Name: "bar"}},
+	} {
+		if got := cfg.ForRepo(tc.name, tc.rootDir); got != tc.want {
+			t.Errorf("ForRepo(%q, %q) = %+v, want %+v", tc.name, tc.rootDir, got, tc.want)
+		}
+	}
+}
@@ -3,6 +3,7 @@ module github.com/JeffFaer/tmux-vcs-sync/api
 go 1.23
 
 require (
+	github.com/BurntSushi/toml v1.4.0
 	github.com/adrg/xdg v0.4.0
 	github.com/google/go-cmp v0.6.0
 	github.com/kballard/go-shellquote v0.0.0-20180428030007-95032a82bc51
@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"maps"
 	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/JeffFaer/tmux-vcs-sync/api"
 )
@@ -32,6 +34,11 @@ func NewVCS(dir string, repos ...RepoConfig) api.VersionControlSystem {
 		if err := seedRepo(ctx, repo, cfg.WorkUnits); err != nil {
 			panic(err)
 		}
+		maps.Copy(repo.(*fakeRepo).status, cfg.Status)
+		maps.Copy(repo.(*fakeRepo).lastChanged, cfg.LastChanged)
+		for _, wu := range cfg.Merged {
+			repo.(*fakeRepo).merged[wu] = true
+		}
 	}
 
 	return vcs
@@ -44,6 +51,14 @@ type RepoConfig struct {
 	// You must have a DefaultWorkUnitName entry so we know where to start making
 	// work units from.
 	WorkUnits map[string][]string
+	// Status is the status of work units, keyed by work unit. Work units that
+	// aren't in the map have a zero status.
+	Status map[string]api.WorkUnitStatus
+	// LastChanged is when work units last changed, keyed by work unit. Work
+	// units that aren't in the map have a zero time.
+	LastChanged map[string]time.Time
+	// Merged are the work units that have been merged into the trunk.
+	Merged []string
 }
 
 func seedRepo(ctx context.Context, repo api.Repository, workUnits map[string][]string) error {
@@ -91,12 +106,15 @@ func (vcs *fakeVCS) Repository(_ context.Context, dir string) (api.Repository, e
 	}
 
 	repo := &fakeRepo{
-		vcs:       vcs,
-		name:      filepath.Base(dir),
-		dir:       dir,
-		cur:       DefaultWorkUnitName,
-		workUnits: map[string]string{DefaultWorkUnitName: ""},
-		children:  map[string]map[string]bool{DefaultWorkUnitName: make(map[string]bool)},
+		vcs:         vcs,
+		name:        filepath.Base(dir),
+		dir:         dir,
+		cur:         DefaultWorkUnitName,
+		workUnits:   map[string]string{DefaultWorkUnitName: ""},
+		children:    map[string]map[string]bool{DefaultWorkUnitName: make(map[string]bool)},
+		status:      make(map[string]api.WorkUnitStatus),
+		lastChanged: make(map[string]time.Time),
+		merged:      make(map[string]bool),
 	}
 	vcs.repos[dir] = repo
 	return repo, nil
@@ -106,9 +124,12 @@ type fakeRepo struct {
 	vcs       api.VersionControlSystem
 	name, dir string
 
-	cur       string
-	workUnits map[string]string
-	children  map[string]map[string]bool
+	cur         string
+	workUnits   map[string]string
+	children    map[string]map[string]bool
+	status      map[string]api.WorkUnitStatus
+	lastChanged map[string]time.Time
+	merged      map[string]bool
 }
 
 func (repo *fakeRepo) VCS() api.VersionControlSystem {
@@ -175,6 +196,49 @@ func (repo *fakeRepo) Sort(_ context.Context, workUnits []string) error {
 	return nil
 }
 
+func (repo *fakeRepo) Parents(context.Context) (map[string]string, error) {
+	return maps.Clone(repo.workUnits), nil
+}
+
+func (repo *fakeRepo) Status(_ context.Context, workUnitNames []string) (map[string]api.WorkUnitStatus, error) {
+	ret := make(map[string]api.WorkUnitStatus)
+	for _, wu := range workUnitNames {
+		if _, ok := repo.workUnits[wu]; !ok {
+			return nil, fmt.Errorf("work unit %q does not exist", wu)
+		}
+		ret[wu] = repo.status[wu]
+	}
+	return ret, nil
+}
+
+func (repo *fakeRepo) LastChanged(_ context.Context, workUnitName string) (time.Time, error) {
+	if _, ok := repo.workUnits[workUnitName]; !ok {
+		return time.Time{}, fmt.Errorf("work unit %q does not exist", workUnitName)
+	}
+	return repo.lastChanged[workUnitName], nil
+}
+
+func (repo *fakeRepo) Merged(context.Context) ([]string, error) {
+	var ret []string
+	for wu := range repo.merged {
+		ret = append(ret, wu)
+	}
+	slices.Sort(ret)
+	return ret, nil
+}
+
+// Log returns the work unit followed by its ancestors.
+func (repo *fakeRepo) Log(_ context.Context, workUnitName string, n int) ([]string, error) {
+	if _, ok := repo.workUnits[workUnitName]; !ok {
+		return nil, fmt.Errorf("work unit %q does not exist", workUnitName)
+	}
+	var ret []string
+	for wu := workUnitName; wu != "" && len(ret) < n; wu = repo.workUnits[wu] {
+		ret = append(ret, wu)
+	}
+	return ret, nil
+}
+
 func (repo *fakeRepo) New(_ context.Context, workUnitName string) error {
 	return repo.commit(workUnitName, DefaultWorkUnitName)
 }
@@ -209,7 +273,22 @@ func (repo *fakeRepo) Rename(_ context.Context, workUnitName string) error {
 	delete(repo.children[parent], repo.cur)
 	repo.workUnits[workUnitName] = parent
 	repo.children[workUnitName] = children
+	for child := range children {
+		repo.workUnits[child] = workUnitName
+	}
 	repo.children[parent][workUnitName] = true
+	if status, ok := repo.status[repo.cur]; ok {
+		delete(repo.status, repo.cur)
+		repo.status[workUnitName] = status
+	}
+	if t, ok := repo.lastChanged[repo.cur]; ok {
+		delete(repo.lastChanged, repo.cur)
+		repo.lastChanged[workUnitName] = t
+	}
+	if repo.merged[repo.cur] {
+		delete(repo.merged, repo.cur)
+		repo.merged[workUnitName] = true
+	}
 	repo.cur = workUnitName
 	return nil
 }
@@ -226,3 +305,24 @@ func (repo *fakeRepo) Update(_ context.Context, workUnitName string) error {
 	repo.cur = workUnitName
 	return nil
 }
+
+func (repo *fakeRepo) Delete(_ context.Context, workUnitName string) error {
+	parent, ok := repo.workUnits[workUnitName]
+	if !ok {
+		return fmt.Errorf("work unit %q does not exist", workUnitName)
+	}
+	if workUnitName == repo.cur {
+		return fmt.Errorf("work unit %q is the current work unit", workUnitName)
+	}
+	for child := range repo.children[workUnitName] {
+		repo.workUnits[child] = parent
+		repo.children[parent][child] = true
+	}
+	delete(repo.workUnits, workUnitName)
+	delete(repo.children, workUnitName)
+	delete(repo.children[parent], workUnitName)
+	delete(repo.status, workUnitName)
+	delete(repo.lastChanged, workUnitName)
+	delete(repo.merged, workUnitName)
+	return nil
+}
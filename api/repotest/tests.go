@@ -51,8 +51,12 @@ func RepoTests(t *testing.T, ctor func(context.Context, *testing.T, string) (api
 		"Commit":          testCommit,
 		"Rename":          testRename,
 		"Update":          testUpdate,
+		"Delete":          testDelete,
 		"List":            testList,
 		"Sort":            testSort,
+		"Parent":          testParent,
+		"Status":          testStatus,
+		"Log":             testLog,
 	} {
 		t.Run(n, func(t *testing.T) {
 			if opts.Parallel {
@@ -258,6 +262,70 @@ func testUpdate(ctx context.Context, t *testing.T, ctor repoCtor, opts Options)
 	}
 }
 
+func testDelete(ctx context.Context, t *testing.T, ctor repoCtor, opts Options) {
+	for _, tc := range []struct {
+		name string
+
+		workUnits []string
+		delete    string
+
+		wantErr bool
+	}{
+		{
+			name: "Simple",
+
+			workUnits: []string{"abcd", "efgh"},
+			delete:    "abcd",
+		},
+		{
+			name: "Current",
+
+			workUnits: []string{"abcd", "efgh"},
+			delete:    "efgh",
+
+			wantErr: true,
+		},
+		{
+			name: "DoesNotExist",
+
+			workUnits: []string{"efgh"},
+			delete:    "abcd",
+
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := ctor(t)
+			if _, ok := repo.(api.Deleter); !ok {
+				t.Skip("repository isn't an api.Deleter")
+			}
+			for _, wu := range tc.workUnits {
+				if err := repo.New(ctx, wu); err != nil {
+					t.Errorf("repo.New(%q) = %v", wu, err)
+				}
+			}
+			if err := api.Delete(ctx, repo, tc.delete); (err != nil) != tc.wantErr {
+				t.Errorf("repo.Delete(%q) = %v, wantErr %t", tc.delete, err, tc.wantErr)
+			}
+			var remaining []string
+			for _, wu := range tc.workUnits {
+				if tc.wantErr || wu != tc.delete {
+					remaining = append(remaining, wu)
+				}
+			}
+			if err := checkExists(ctx, repo, remaining...); err != nil {
+				t.Error(err)
+			}
+			if tc.wantErr {
+				return
+			}
+			if err := checkNotExists(ctx, repo, tc.delete); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
 func testList(ctx context.Context, t *testing.T, ctor repoCtor, opts Options) {
 	repo := ctor(t)
 	workUnitNames := append([]string{
@@ -384,6 +452,95 @@ func testSort(ctx context.Context, t *testing.T, ctor repoCtor, opts Options) {
 	}
 }
 
+func testParent(ctx context.Context, t *testing.T, ctor repoCtor, opts Options) {
+	repo := ctor(t)
+	if _, ok := repo.(api.Stacker); !ok {
+		t.Skip("repository isn't an api.Stacker")
+	}
+	root, err := repo.Current(ctx)
+	if err != nil {
+		t.Errorf("repo.Current() = _, %v", err)
+	}
+	workUnits := map[string][]string{
+		root:    {"abcd", "efgh"},
+		"abcd":  {"abcd1", "abcd2"},
+		"efgh":  {"efgh1"},
+		"efgh1": {"efgh2"},
+	}
+	if err := seedRepo(ctx, repo, workUnits); err != nil {
+		t.Error(err)
+	}
+
+	want := map[string]string{root: ""}
+	for parent, children := range workUnits {
+		for _, child := range children {
+			want[child] = parent
+		}
+	}
+	if got, err := api.Parents(ctx, repo); err != nil {
+		t.Errorf("repo.Parents() = _, %v", err)
+	} else if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("repo.Parents() diff (-want +got)\n%s", diff)
+	}
+}
+
+func testStatus(ctx context.Context, t *testing.T, ctor repoCtor, opts Options) {
+	repo := ctor(t)
+	if _, ok := repo.(api.StatusChecker); !ok {
+		t.Skip("repository isn't an api.StatusChecker")
+	}
+	workUnits := []string{"abcd", "efgh"}
+	for _, wu := range workUnits {
+		if err := repo.New(ctx, wu); err != nil {
+			t.Errorf("repo.New(%q) = %v", wu, err)
+		}
+	}
+	want := map[string]api.WorkUnitStatus{"abcd": {}, "efgh": {}}
+	if got, err := api.Status(ctx, repo, workUnits); err != nil {
+		t.Errorf("repo.Status(%q) = _, %v", workUnits, err)
+	} else if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("repo.Status(%q) diff (-want +got)\n%s", workUnits, diff)
+	}
+
+	if _, err := api.Status(ctx, repo, []string{"abcd", "wxyz"}); err == nil {
+		t.Errorf("repo.Status(%q) = _, nil, want error", []string{"abcd", "wxyz"})
+	}
+}
+
+func testLog(ctx context.Context, t *testing.T, ctor repoCtor, opts Options) {
+	repo := ctor(t)
+	if _, ok := repo.(api.Logger); !ok {
+		t.Skip("repository isn't an api.Logger")
+	}
+	if err := repo.New(ctx, "abcd"); err != nil {
+		t.Errorf("repo.New(%q) = %v", "abcd", err)
+	}
+	if err := repo.Commit(ctx, "efgh"); err != nil {
+		t.Errorf("repo.Commit(%q) = %v", "efgh", err)
+	}
+
+	parent, err := api.Log(ctx, repo, "abcd", 100)
+	if err != nil {
+		t.Errorf("repo.Log(%q, 100) = _, %v", "abcd", err)
+	}
+	child, err := api.Log(ctx, repo, "efgh", 100)
+	if err != nil {
+		t.Errorf("repo.Log(%q, 100) = _, %v", "efgh", err)
+	}
+	if len(child) <= len(parent) {
+		t.Errorf("repo.Log(%q) has %d changes, but its parent %q has %d", "efgh", len(child), "abcd", len(parent))
+	}
+	if got, err := api.Log(ctx, repo, "efgh", 1); err != nil {
+		t.Errorf("repo.Log(%q, 1) = _, %v", "efgh", err)
+	} else if len(got) != 1 {
+		t.Errorf("repo.Log(%q, 1) = %q, want 1 change", "efgh", got)
+	}
+
+	if _, err := api.Log(ctx, repo, "wxyz", 1); err == nil {
+		t.Errorf("repo.Log(%q, 1) = _, nil, want error", "wxyz")
+	}
+}
+
 func checkExists(ctx context.Context, repo api.Repository, workUnitNames ...string) error {
 	for _, n := range workUnitNames {
 		if ok, err := repo.Exists(ctx, n); err != nil {
@@ -3,6 +3,7 @@ package api
 import (
 	"context"
 	"runtime/trace"
+	"time"
 )
 
 // tracingVersionControlSystem wraps the provided VersionControlSystem so that
@@ -75,3 +76,35 @@ func (repo *tracingRepository) Update(ctx context.Context, workUnitName string)
 	defer repo.startRegions(ctx)()
 	return repo.repo.Update(ctx, workUnitName)
 }
+func (repo *tracingRepository) Delete(ctx context.Context, workUnitName string) error {
+	defer repo.startRegions(ctx)()
+	return Delete(ctx, repo.repo, workUnitName)
+}
+func (repo *tracingRepository) Parents(ctx context.Context) (map[string]string, error) {
+	defer repo.startRegions(ctx)()
+	return Parents(ctx, repo.repo)
+}
+func (repo *tracingRepository) Status(ctx context.Context, workUnitNames []string) (map[string]WorkUnitStatus, error) {
+	defer repo.startRegions(ctx)()
+	return Status(ctx, repo.repo, workUnitNames)
+}
+func (repo *tracingRepository) Log(ctx context.Context, workUnitName string, n int) ([]string, error) {
+	defer repo.startRegions(ctx)()
+	return Log(ctx, repo.repo, workUnitName, n)
+}
+func (repo *tracingRepository) Merged(ctx context.Context) ([]string, error) {
+	defer repo.startRegions(ctx)()
+	return Merged(ctx, repo.repo)
+}
+func (repo *tracingRepository) LastChanged(ctx context.Context, workUnitName string) (time.Time, error) {
+	defer repo.startRegions(ctx)()
+	return LastChanged(ctx, repo.repo, workUnitName)
+}
+func (repo *tracingRepository) InstallCheckoutHook(ctx context.Context, command string, force bool) error {
+	defer repo.startRegions(ctx)()
+	return InstallCheckoutHook(ctx, repo.repo, command, force)
+}
+func (repo *tracingRepository) StateFiles(ctx context.Context) ([]string, error) {
+	defer repo.startRegions(ctx)()
+	return StateFiles(ctx, repo.repo)
+}
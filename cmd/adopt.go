@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/JeffFaer/tmux-vcs-sync/api"
+	"github.com/JeffFaer/tmux-vcs-sync/tmux"
+	"github.com/JeffFaer/tmux-vcs-sync/tmux/state"
+	"github.com/spf13/cobra"
+)
+
+var adoptID string
+
+func init() {
+	adoptCommand.Flags().StringVar(&adoptID, "id", "", "Adopt the tmux session with this ID, as printed by list-menu, instead of the current one. Its repository is found from the directory of its active pane.")
+	rootCmd.AddCommand(adoptCommand)
+}
+
+var adoptCommand = &cobra.Command{
+	Use:   "adopt [name]",
+	Short: "Associate a tmux session that isn't for a work unit with one.",
+	Long: `Associate the current tmux session, which isn't for a work unit, with a work unit of the repository in the current directory. The work unit is created on top of trunk if it doesn't exist yet. Without a name, the repository's current work unit is used.
+
+The session's new windows will start in the repository, and the session is renamed to represent the work unit.`,
+	Args: cobra.RangeArgs(0, 1),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return suggestWorkUnitNames(cmd.Context(), toComplete), 0
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var name string
+		if len(args) > 0 {
+			name = args[0]
+		}
+		return adopt(cmd.Context(), adoptID, name)
+	},
+}
+
+func adopt(ctx context.Context, sessionID, workUnitName string) error {
+	vcs := registered()
+	var srv tmux.Server
+	var sesh tmux.Session
+	var repo api.Repository
+	if sessionID == "" {
+		var err error
+		sesh, err = tmux.CurrentSession()
+		if err != nil {
+			return fmt.Errorf("%w: use --id to adopt a session from outside of tmux", err)
+		}
+		srv = sesh.Server()
+		repo, err = vcs.CurrentRepository(ctx)
+		if err != nil {
+			return err
+		}
+	} else {
+		srv, _ = selectedServer(ctx)
+		var err error
+		sesh, err = sessionByID(ctx, srv, sessionID)
+		if err != nil {
+			return err
+		}
+		repo, err = sessionRepository(ctx, vcs, sesh)
+		if err != nil {
+			return err
+		}
+	}
+	st, err := newState(ctx, srv, vcs)
+	if err != nil {
+		return err
+	}
+	return adoptSession(ctx, st, sesh, repo, workUnitName)
+}
+
+// sessionRepository finds the repository that sesh's active pane is in.
+func sessionRepository(ctx context.Context, vcs api.VersionControlSystems, sesh tmux.Session) (api.Repository, error) {
+	prop, err := sesh.Property(ctx, tmux.SessionPaneDir)
+	if err != nil {
+		return nil, err
+	}
+	dir := tmux.PropertyValue(tmux.SessionPaneDir, prop)
+	repo, err := vcs.MaybeFindRepository(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+	if repo == nil {
+		return nil, fmt.Errorf("tmux session %q isn't in a repository: %s", sesh.ID(), dir)
+	}
+	return repo, nil
+}
+
+// adoptSession associates sesh with workUnitName in repo, creating the work
+// unit if necessary. An empty workUnitName means repo's current work unit.
+func adoptSession(ctx context.Context, st *state.State, sesh tmux.Session, repo api.Repository, workUnitName string) error {
+	if workUnitName == "" {
+		cur, err := repo.Current(ctx)
+		if err != nil {
+			return fmt.Errorf("couldn't check repo's current %s: %w", repo.VCS().WorkUnitName(), err)
+		}
+		workUnitName = cur
+	}
+	// Check everything that AdoptSession would before creating the work unit.
+	isSesh := func(s tmux.Session) bool { return s.ID() == sesh.ID() }
+	if !slices.ContainsFunc(slices.Collect(maps.Values(st.UnknownSessions())), isSesh) {
+		return fmt.Errorf("tmux session %q is already for a work unit", sesh.ID())
+	}
+	if st.Session(repo, workUnitName) != nil {
+		return fmt.Errorf("tmux session %q already exists", st.SessionName(state.NewWorkUnitName(repo, workUnitName)))
+	}
+
+	exists, err := repo.Exists(ctx, workUnitName)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if err := repo.New(ctx, workUnitName); err != nil {
+			return fmt.Errorf("failed to create %s %q: %w", repo.VCS().WorkUnitName(), workUnitName, err)
+		}
+	}
+	return st.AdoptSession(ctx, sesh, repo, workUnitName)
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/JeffFaer/tmux-vcs-sync/api"
+	"github.com/JeffFaer/tmux-vcs-sync/api/repotest"
+	"github.com/JeffFaer/tmux-vcs-sync/tmux"
+	"github.com/JeffFaer/tmux-vcs-sync/tmux/state"
+	"github.com/JeffFaer/tmux-vcs-sync/tmux/tmuxtest"
+)
+
+func TestAdoptSession(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	vcs := api.VersionControlSystems{
+		repotest.NewVCS("testing/", repotest.RepoConfig{
+			Name:      "repo",
+			WorkUnits: map[string][]string{repotest.DefaultWorkUnitName: {"foo"}},
+		}),
+	}
+	// TestDisplayMenu uses small PIDs.
+	srv := tmuxtest.NewServer(1013)
+	foo, err := srv.NewSession(ctx, tmux.NewSessionOptions{Name: "foo", StartDir: "testing/repo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scratch, err := srv.NewSession(ctx, tmux.NewSessionOptions{Name: "scratch", StartDir: "/tmp"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, err := state.New(ctx, srv, vcs)
+	if err != nil {
+		t.Fatalf("state.New() = _, %v", err)
+	}
+
+	repo, err := sessionRepository(ctx, vcs, foo)
+	if err != nil {
+		t.Fatalf("sessionRepository(foo) = _, %v", err)
+	}
+	if _, err := sessionRepository(ctx, vcs, scratch); err == nil {
+		t.Errorf("sessionRepository(scratch) = _, nil, want an error")
+	}
+
+	if err := adoptSession(ctx, st, foo, repo, "bar"); err == nil {
+		t.Errorf("adoptSession(foo, bar) = nil, want an error")
+	}
+	if ok, err := repo.Exists(ctx, "bar"); err != nil || ok {
+		t.Errorf("repo.Exists(bar) = %t, %v, want false since foo couldn't be adopted", ok, err)
+	}
+
+	if err := adoptSession(ctx, st, scratch, repo, "bar"); err != nil {
+		t.Fatalf("adoptSession(scratch, bar) = %v", err)
+	}
+	if ok, err := repo.Exists(ctx, "bar"); err != nil || !ok {
+		t.Errorf("repo.Exists(bar) = %t, %v, want true", ok, err)
+	}
+	if got := st.Session(repo, "bar"); got == nil || got.ID() != scratch.ID() {
+		t.Errorf("st.Session(bar) = %v, want %v", got, scratch)
+	}
+	props, err := scratch.Properties(ctx, tmux.SessionName, tmux.SessionPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tmux.SinglePropertyValue(tmux.SessionName, props); got != "bar" {
+		t.Errorf("scratch's name = %q, want %q", got, "bar")
+	}
+	if got := tmux.SinglePropertyValue(tmux.SessionPath, props); got != "testing/repo" {
+		t.Errorf("scratch's path = %q, want %q", got, "testing/repo")
+	}
+}
@@ -1,35 +1,293 @@
 package cmd
 
 import (
+	"bufio"
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
 
+	"github.com/JeffFaer/go-stdlib-ext/morecmp"
+	"github.com/JeffFaer/go-stdlib-ext/moremaps"
 	"github.com/JeffFaer/tmux-vcs-sync/api"
 	"github.com/JeffFaer/tmux-vcs-sync/tmux"
 	"github.com/JeffFaer/tmux-vcs-sync/tmux/state"
 	"github.com/spf13/cobra"
 )
 
+var (
+	cleanupDryRun bool
+	cleanupYes    bool
+	cleanupMerged bool
+)
+
 func init() {
+	cleanupCommand.Flags().BoolVarP(&cleanupDryRun, "dry-run", "n", false, "Print the sessions that would be deleted without deleting them.")
+	cleanupCommand.Flags().BoolVarP(&cleanupYes, "yes", "y", false, "Delete the sessions without asking for confirmation.")
+	cleanupCommand.Flags().BoolVar(&cleanupMerged, "merged", false, "Delete the work units that were merged into trunk, along with their sessions, instead.")
 	rootCmd.AddCommand(cleanupCommand)
 }
 
 var cleanupCommand = &cobra.Command{
 	Use:   "cleanup",
 	Short: "Delete tmux sessions which appear to be for work units that no longer exist.",
-	Args:  cobra.ExactArgs(0),
+	Long: `Delete tmux sessions which appear to be for work units that no longer exist.
+
+Each session is confirmed before it's deleted, unless --yes is given. Without a terminal to ask for confirmation, --yes or --dry-run is required.
+
+With --merged, delete the work units that were merged into trunk and kill their sessions instead. Trunk and each repository's current work unit are kept.`,
+	Args: cobra.ExactArgs(0),
 	RunE: func(cmd *cobra.Command, _ []string) error {
-		return cleanup(cmd.Context())
+		opts := cleanupOptions{dryRun: cleanupDryRun, yes: cleanupYes}
+		if cleanupMerged {
+			return cleanupMergedWorkUnits(cmd.Context(), opts)
+		}
+		return cleanup(cmd.Context(), opts)
 	},
 }
 
-func cleanup(ctx context.Context) error {
-	srv := tmux.MaybeCurrentServer()
-	if srv == nil {
-		srv = tmux.DefaultServer()
+type cleanupOptions struct {
+	// Only print the sessions that would be deleted.
+	dryRun bool
+	// Don't ask for confirmation.
+	yes bool
+}
+
+func cleanup(ctx context.Context, opts cleanupOptions) error {
+	srv, _ := selectedServer(ctx)
+	st, err := newState(ctx, srv, registered())
+	if err != nil {
+		return err
+	}
+	if !opts.dryRun && !opts.yes && !isTerminal(os.Stdin) {
+		return fmt.Errorf("can't ask for confirmation without a terminal: use --yes or --dry-run")
+	}
+	plan, err := confirmPrune(os.Stdin, os.Stdout, st.PlanPrune(ctx), opts)
+	if err != nil {
+		return err
+	}
+	// Waiting for confirmation might take a while, so only lock once there's
+	// something to delete.
+	if len(plan) == 0 {
+		return nil
+	}
+	if err := lockServer(ctx, srv); err != nil {
+		return err
+	}
+	// Sessions might have been renamed or become live again while waiting for
+	// confirmation, so only kill the ones that are still stale.
+	if st, err = newState(ctx, srv, registered()); err != nil {
+		return err
+	}
+	return st.KillSessions(ctx, stillPlanned(plan, st.PlanPrune(ctx), staleSessionKey))
+}
+
+// stillPlanned returns the items in fresh, a new plan, that were also in
+// confirmed, an older plan. Items are identified by key.
+func stillPlanned[T any, K comparable](confirmed, fresh []T, key func(T) K) []T {
+	keys := make(map[K]bool)
+	for _, c := range confirmed {
+		keys[key(c)] = true
+	}
+	var ret []T
+	for _, f := range fresh {
+		if keys[key(f)] {
+			ret = append(ret, f)
+		}
+	}
+	if n := len(confirmed) - len(ret); n > 0 {
+		slog.Info("Skipping items that changed since they were confirmed.", "count", n)
+	}
+	return ret
+}
+
+type staleSessionID struct {
+	session  string
+	workUnit state.WorkUnitName
+}
+
+func staleSessionKey(s state.StaleSession) staleSessionID {
+	return staleSessionID{s.Session.ID(), s.WorkUnit}
+}
+
+// confirmPrune decides which sessions in plan should actually be killed.
+func confirmPrune(r io.Reader, w io.Writer, plan []state.StaleSession, opts cleanupOptions) ([]state.StaleSession, error) {
+	return confirm(r, w, "sessions", plan, describeStaleSession, opts)
+}
+
+// confirm decides which items in plan should actually be deleted. noun
+// describes all of the items in plan.
+func confirm[T any](r io.Reader, w io.Writer, noun string, plan []T, describe func(T) string, opts cleanupOptions) ([]T, error) {
+	if len(plan) == 0 {
+		_, err := fmt.Fprintf(w, "There are no %s to delete.\n", noun)
+		return nil, err
+	}
+	if opts.dryRun {
+		var b strings.Builder
+		fmt.Fprintf(&b, "Would delete these %s:\n", noun)
+		for _, s := range plan {
+			fmt.Fprintf(&b, "  %s\n", describe(s))
+		}
+		_, err := io.WriteString(w, b.String())
+		return nil, err
+	}
+	if opts.yes {
+		return plan, nil
+	}
+
+	in := bufio.NewReader(r)
+	var confirmed []T
+	for _, s := range plan {
+		if _, err := fmt.Fprintf(w, "Delete %s? [y/N] ", describe(s)); err != nil {
+			return nil, err
+		}
+		line, err := in.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "y", "yes":
+			confirmed = append(confirmed, s)
+		}
+		if errors.Is(err, io.EOF) {
+			// Anything that wasn't answered isn't confirmed.
+			fmt.Fprintln(w)
+			break
+		}
+	}
+	return confirmed, nil
+}
+
+func describeStaleSession(s state.StaleSession) string {
+	return fmt.Sprintf("%s (%s): %q no longer exists in %s", s.SessionName, s.Session.ID(), s.WorkUnit.WorkUnit, s.WorkUnit.Repo)
+}
+
+// A mergedWorkUnit is a work unit that was merged into its repository's trunk.
+type mergedWorkUnit struct {
+	repo     api.Repository
+	workUnit string
+	// session is the work unit's tmux session, if it has one.
+	session tmux.Session
+}
+
+func cleanupMergedWorkUnits(ctx context.Context, opts cleanupOptions) error {
+	vcs := registered()
+	srv, _ := selectedServer(ctx)
+	st, err := newState(ctx, srv, vcs)
+	if err != nil {
+		return err
+	}
+	if !opts.dryRun && !opts.yes && !isTerminal(os.Stdin) {
+		return fmt.Errorf("can't ask for confirmation without a terminal: use --yes or --dry-run")
+	}
+	plan, err := planMergedRepositories(ctx, st, vcs)
+	if err != nil {
+		return err
+	}
+	describe := func(wu mergedWorkUnit) string {
+		return describeMergedWorkUnit(st, wu)
+	}
+	plan, err = confirm(os.Stdin, os.Stdout, "work units", plan, describe, opts)
+	if err != nil {
+		return err
+	}
+	// Waiting for confirmation might take a while, so only lock once there's
+	// something to delete.
+	if len(plan) == 0 {
+		return nil
+	}
+	if err := lockServer(ctx, srv); err != nil {
+		return err
+	}
+	var planned []api.Repository
+	for _, wu := range plan {
+		planned = append(planned, wu.repo)
 	}
-	st, err := state.New(ctx, srv, api.Registered())
+	if err := lockRepos(ctx, planned...); err != nil {
+		return err
+	}
+	// Work units might have gained commits, or sessions might have changed,
+	// while waiting for confirmation. Only delete the ones that are still
+	// merged.
+	if st, err = newState(ctx, srv, vcs); err != nil {
+		return err
+	}
+	fresh, err := planMergedRepositories(ctx, st, vcs)
 	if err != nil {
 		return err
 	}
-	return st.PruneSessions(ctx)
+	plan = stillPlanned(plan, fresh, func(wu mergedWorkUnit) state.WorkUnitName {
+		return state.NewWorkUnitName(wu.repo, wu.workUnit)
+	})
+	for _, wu := range plan {
+		if err := api.Delete(ctx, wu.repo, wu.workUnit); err != nil {
+			return fmt.Errorf("could not delete %q in %s: %w", wu.workUnit, wu.repo.Name(), err)
+		}
+		if wu.session != nil {
+			if err := st.KillSession(ctx, wu.repo, wu.workUnit); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// planMergedRepositories plans which merged work units to delete in all of the
+// repositories st knows about, as well as the current one.
+func planMergedRepositories(ctx context.Context, st *state.State, vcs api.VersionControlSystems) ([]mergedWorkUnit, error) {
+	repos := st.Repositories()
+	addCurrentRepository(ctx, vcs, repos)
+	return planMerged(ctx, st, repos)
+}
+
+// planMerged finds the work units in repos that were merged into trunk, other
+// than each repository's current work unit. Work units with the current tmux
+// session are last so that it isn't killed before the others.
+func planMerged(ctx context.Context, st *state.State, repos map[state.RepoName]api.Repository) ([]mergedWorkUnit, error) {
+	var plan []mergedWorkUnit
+	for _, n := range moremaps.SortedKeysFunc(repos, repoNameCmp) {
+		repo := repos[n]
+		merged, err := api.Merged(ctx, repo)
+		if errors.Is(err, errors.ErrUnsupported) {
+			slog.Info("Repository can't find merged work units.", "repo", n, "error", err)
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("could not find merged work units in %s: %w", repo.Name(), err)
+		}
+		cur, err := repo.Current(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("could not determine current work unit in %s: %w", repo.Name(), err)
+		}
+		for _, wu := range merged {
+			if wu == cur {
+				continue
+			}
+			plan = append(plan, mergedWorkUnit{repo, wu, st.Session(repo, wu)})
+		}
+	}
+	if curSesh := tmux.MaybeCurrentSession(); curSesh != nil {
+		isCurrent := func(wu mergedWorkUnit) bool {
+			return wu.session != nil && tmux.SameSession(ctx, curSesh, wu.session)
+		}
+		slices.SortStableFunc(plan, morecmp.ComparingFunc(isCurrent, morecmp.FalseFirst()))
+	}
+	return plan, nil
+}
+
+func describeMergedWorkUnit(st *state.State, wu mergedWorkUnit) string {
+	desc := fmt.Sprintf("%q in %s", wu.workUnit, st.RepoLabel(state.NewRepoName(wu.repo)))
+	if wu.session != nil {
+		desc += fmt.Sprintf(" and its session %s (%s)", st.SessionName(state.NewWorkUnitName(wu.repo, wu.workUnit)), wu.session.ID())
+	}
+	return desc
+}
+
+// isTerminal determines whether f is a terminal.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
 }
@@ -0,0 +1,191 @@
+package cmd
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JeffFaer/tmux-vcs-sync/api"
+	"github.com/JeffFaer/tmux-vcs-sync/api/repotest"
+	"github.com/JeffFaer/tmux-vcs-sync/tmux"
+	"github.com/JeffFaer/tmux-vcs-sync/tmux/state"
+	"github.com/JeffFaer/tmux-vcs-sync/tmux/tmuxtest"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestConfirmPrune(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	vcs := api.VersionControlSystems{
+		repotest.NewVCS("testing/", repotest.RepoConfig{
+			Name:      "repo",
+			WorkUnits: map[string][]string{repotest.DefaultWorkUnitName: {"foo"}},
+		}),
+	}
+	// TestDisplayMenu uses small PIDs.
+	srv := tmuxtest.NewServer(1005)
+	for _, name := range []string{"foo", "gone1", "gone2", "gone3"} {
+		if _, err := srv.NewSession(ctx, tmux.NewSessionOptions{Name: name, StartDir: "testing/repo"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	st, err := state.New(ctx, srv, vcs)
+	if err != nil {
+		t.Fatalf("state.New() = _, %v", err)
+	}
+	plan := st.PlanPrune(ctx)
+
+	for _, tc := range []struct {
+		name    string
+		opts    cleanupOptions
+		input   string
+		want    []string
+		wantOut string
+	}{
+		{
+			name: "dry run",
+			opts: cleanupOptions{dryRun: true},
+			wantOut: `Would delete these sessions:
+  gone1 (1005#1): "gone1" no longer exists in repo
+  gone2 (1005#2): "gone2" no longer exists in repo
+  gone3 (1005#3): "gone3" no longer exists in repo
+`,
+		},
+		{
+			name: "yes",
+			opts: cleanupOptions{yes: true},
+			want: []string{"gone1", "gone2", "gone3"},
+		},
+		{
+			name:  "interactive",
+			input: "y\nn\nYes\n",
+			want:  []string{"gone1", "gone3"},
+		},
+		{
+			name:  "interactive EOF",
+			input: "y",
+			want:  []string{"gone1"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var out strings.Builder
+			confirmed, err := confirmPrune(strings.NewReader(tc.input), &out, plan, tc.opts)
+			if err != nil {
+				t.Fatalf("confirmPrune() = _, %v", err)
+			}
+			var got []string
+			for _, s := range confirmed {
+				got = append(got, s.SessionName)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("confirmPrune() diff (-want +got)\n%s", diff)
+			}
+			if tc.wantOut != "" {
+				if diff := cmp.Diff(tc.wantOut, out.String()); diff != "" {
+					t.Errorf("confirmPrune() output diff (-want +got)\n%s", diff)
+				}
+			}
+		})
+	}
+
+	// Nothing has been killed yet.
+	sessions, err := srv.ListSessions(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(sessions.Sessions()); n != 4 {
+		t.Errorf("tmux has %d sessions, want 4", n)
+	}
+}
+
+func TestPlanMerged(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	vcs := api.VersionControlSystems{
+		repotest.NewVCS("testing/", repotest.RepoConfig{
+			Name:      "repo",
+			WorkUnits: map[string][]string{repotest.DefaultWorkUnitName: {"foo", "bar", "baz"}},
+			// baz is the current work unit.
+			Merged: []string{"foo", "bar", "baz"},
+		}, repotest.RepoConfig{
+			Name:      "other",
+			WorkUnits: map[string][]string{repotest.DefaultWorkUnitName: {"qux"}},
+		}),
+	}
+	// TestDisplayMenu uses small PIDs.
+	srv := tmuxtest.NewServer(1006)
+	for _, opts := range []tmux.NewSessionOptions{
+		{Name: "repo>foo", StartDir: "testing/repo"},
+		{Name: "repo>baz", StartDir: "testing/repo"},
+		{Name: "other>qux", StartDir: "testing/other"},
+	} {
+		if _, err := srv.NewSession(ctx, opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	st, err := state.New(ctx, srv, vcs)
+	if err != nil {
+		t.Fatalf("state.New() = _, %v", err)
+	}
+
+	plan, err := planMerged(ctx, st, st.Repositories())
+	if err != nil {
+		t.Fatalf("planMerged() = _, %v", err)
+	}
+	var got []string
+	for _, wu := range plan {
+		got = append(got, describeMergedWorkUnit(st, wu))
+	}
+	want := []string{
+		`"bar" in repo`,
+		`"foo" in repo and its session repo>foo (1006#0)`,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("planMerged() diff (-want +got)\n%s", diff)
+	}
+}
+
+func TestStillPlanned(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	vcs := api.VersionControlSystems{
+		repotest.NewVCS("testing/", repotest.RepoConfig{
+			Name:      "repo",
+			WorkUnits: map[string][]string{repotest.DefaultWorkUnitName: {"foo"}},
+		}),
+	}
+	// TestDisplayMenu uses small PIDs.
+	srv := tmuxtest.NewServer(1021)
+	for _, name := range []string{"foo", "gone1", "gone2"} {
+		if _, err := srv.NewSession(ctx, tmux.NewSessionOptions{Name: name, StartDir: "testing/repo"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	st, err := state.New(ctx, srv, vcs)
+	if err != nil {
+		t.Fatalf("state.New() = _, %v", err)
+	}
+	confirmed := st.PlanPrune(ctx)
+
+	// gone1 is created while waiting for confirmation.
+	repo, err := vcs.MaybeFindRepository(ctx, "testing/repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Commit(ctx, "gone1"); err != nil {
+		t.Fatal(err)
+	}
+	st, err = state.New(ctx, srv, vcs)
+	if err != nil {
+		t.Fatalf("state.New() = _, %v", err)
+	}
+
+	var got []string
+	for _, s := range stillPlanned(confirmed, st.PlanPrune(ctx), staleSessionKey) {
+		got = append(got, s.SessionName)
+	}
+	if diff := cmp.Diff([]string{"gone2"}, got); diff != "" {
+		t.Errorf("stillPlanned() diff (-want +got)\n%s", diff)
+	}
+}
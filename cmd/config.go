@@ -0,0 +1,297 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/JeffFaer/tmux-vcs-sync/api"
+	"github.com/JeffFaer/tmux-vcs-sync/api/config"
+	"github.com/JeffFaer/tmux-vcs-sync/tmux"
+	"github.com/JeffFaer/tmux-vcs-sync/tmux/state"
+	"github.com/spf13/cobra"
+)
+
+// cfg is the effective configuration. It's loaded before any command runs.
+var cfg = config.Default()
+
+func init() {
+	configCommand.AddCommand(configValidateCommand, configShowCommand)
+	rootCmd.AddCommand(configCommand)
+}
+
+var configCommand = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the configuration file.",
+	Long: fmt.Sprintf(`Inspect the configuration file.
+
+The configuration file is config.toml in tmux-vcs-sync's configuration directory. Any setting outside of a [[repo]] section can be overridden by an environment variable named %s<SECTION>_<KEY>.
+e.g. %sMENU_KEY_SHORTCUTS`, config.EnvPrefix, config.EnvPrefix),
+}
+
+var configValidateCommand = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the configuration file for errors.",
+	Args:  cobra.ExactArgs(0),
+	RunE: func(*cobra.Command, []string) error {
+		path, err := config.File()
+		if err != nil {
+			return err
+		}
+		if _, err := loadConfigFile(path); err != nil {
+			return err
+		}
+		fmt.Printf("%s is valid.\n", path)
+		return nil
+	},
+}
+
+var configShowCommand = &cobra.Command{
+	Use:   "show",
+	Short: "Print the effective configuration, after applying defaults and environment variables.",
+	Args:  cobra.ExactArgs(0),
+	RunE: func(*cobra.Command, []string) error {
+		path, err := config.File()
+		if err != nil {
+			return err
+		}
+		c, err := loadConfigFile(path)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("# %s\n", path)
+		return c.Encode(os.Stdout)
+	},
+}
+
+// loadConfig loads the configuration file and applies it to the rest of the
+// package.
+// The config and doctor commands are allowed to run with an invalid
+// configuration file, since they're how the file gets fixed.
+func loadConfig(cmd *cobra.Command) error {
+	path, err := config.File()
+	if err == nil {
+		var c *config.Config
+		if c, err = loadConfigFile(path); err == nil {
+			cfg = c
+		}
+	}
+	if err != nil {
+		if isRepairCommand(cmd) {
+			slog.Debug("Ignoring configuration error.", "error", err)
+			return nil
+		}
+		return err
+	}
+	keyShortcuts = strings.Split(cfg.Menu.KeyShortcuts, "")
+	recordAfter = cfg.Trace.RecordAfter
+	return nil
+}
+
+// loadConfigFile loads the configuration file at path, and also checks the
+// settings that only this package understands.
+func loadConfigFile(path string) (*config.Config, error) {
+	c, err := config.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := sessionNaming(c).Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration %s: naming: %w", path, err)
+	}
+	return c, nil
+}
+
+// sessionNaming is the Naming described by c.
+func sessionNaming(c *config.Config) state.Naming {
+	return state.Naming{
+		Qualified:   c.Naming.Qualified,
+		Unqualified: c.Naming.Unqualified,
+		Qualify:     state.Qualify(c.Naming.Qualify),
+	}
+}
+
+// newState creates a state.State that names sessions according to the
+// configuration file.
+func newState(ctx context.Context, srv tmux.Server, vcs api.VersionControlSystems) (*state.State, error) {
+	return state.New(ctx, srv, vcs, state.WithNaming(sessionNaming(cfg)))
+}
+
+// isRepairCommand determines whether cmd is one of the commands that help fix
+// a broken setup: the config command or one of its subcommands, or the doctor
+// command.
+func isRepairCommand(cmd *cobra.Command) bool {
+	for ; cmd != nil; cmd = cmd.Parent() {
+		if cmd == configCommand || cmd == doctorCommand {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultServer is the tmux server to use when not already in tmux.
+func defaultServer(opts ...tmux.ServerOption) tmux.Server {
+	switch {
+	case cfg.Tmux.SocketName != "":
+		opts = append(opts, tmux.NamedServerSocket(cfg.Tmux.SocketName))
+	case cfg.Tmux.SocketPath != "":
+		opts = append(opts, tmux.ServerSocketPath(cfg.Tmux.SocketPath))
+	}
+	return tmux.DefaultServer(opts...)
+}
+
+// flaggedServer returns the tmux server given by --socket-name or
+// --socket-path, or nil if neither was given.
+func flaggedServer(opts ...tmux.ServerOption) tmux.Server {
+	switch {
+	case socketName != "":
+		return tmux.NewServer(append(opts, tmux.NamedServerSocket(socketName))...)
+	case socketPath != "":
+		return tmux.NewServer(append(opts, tmux.ServerSocketPath(socketPath))...)
+	}
+	return nil
+}
+
+// selectedServer returns the tmux server that commands operate on: the one
+// given by --socket-name or --socket-path, or else the current tmux server, or
+// else the default server. isCurrent is whether this program is running within
+// the returned server.
+func selectedServer(ctx context.Context, opts ...tmux.ServerOption) (srv tmux.Server, isCurrent bool) {
+	cur := tmux.MaybeCurrentServer(opts...)
+	if srv := flaggedServer(opts...); srv != nil {
+		return srv, cur != nil && tmux.SameServer(ctx, srv, cur)
+	}
+	if cur != nil {
+		return cur, true
+	}
+	return defaultServer(opts...), false
+}
+
+// currentSession returns the tmux session that this program is running within,
+// if it belongs to srv.
+func currentSession(ctx context.Context, srv tmux.Server) tmux.Session {
+	sesh := tmux.MaybeCurrentSession()
+	if sesh == nil {
+		return nil
+	}
+	if flaggedServer() != nil && !tmux.SameServer(ctx, srv, sesh.Server()) {
+		return nil
+	}
+	return sesh
+}
+
+// isSocketPath determines whether socket, from tmux.servers, is a socket path
+// instead of a socket name.
+func isSocketPath(socket string) bool {
+	return strings.ContainsRune(socket, '/')
+}
+
+// socketArgs are the flags that make this tool use the tmux server with the
+// given socket from tmux.servers.
+func socketArgs(socket string) []string {
+	if isSocketPath(socket) {
+		return []string{"--socket-path", socket}
+	}
+	return []string{"--socket-name", socket}
+}
+
+// otherServer is one of the tmux servers from tmux.servers.
+type otherServer struct {
+	socket string
+	srv    tmux.Server
+}
+
+// otherServers returns the tmux servers from tmux.servers that are running,
+// other than srv.
+func otherServers(ctx context.Context, srv tmux.Server, opts ...tmux.ServerOption) []otherServer {
+	var ret []otherServer
+	for _, socket := range cfg.Tmux.Servers {
+		opt := tmux.NamedServerSocket(socket)
+		if isSocketPath(socket) {
+			opt = tmux.ServerSocketPath(socket)
+		}
+		other := tmux.NewServer(append(opts, opt)...)
+		if _, err := other.PID(ctx); err != nil {
+			slog.Info("Skipping tmux server that isn't running.", "server", other, "error", err)
+			continue
+		}
+		if tmux.SameServer(ctx, srv, other) || slices.ContainsFunc(ret, func(o otherServer) bool { return tmux.SameServer(ctx, o.srv, other) }) {
+			continue
+		}
+		ret = append(ret, otherServer{socket, other})
+	}
+	return ret
+}
+
+// registered returns the registered VersionControlSystems, with any
+// per-repository settings from the configuration file applied to the
+// repositories they find.
+func registered() api.VersionControlSystems {
+	all := api.Registered()
+	if len(cfg.Repos) == 0 {
+		return all
+	}
+	for i, vcs := range all {
+		all[i] = configuredVCS{vcs}
+	}
+	return all
+}
+
+type configuredVCS struct {
+	api.VersionControlSystem
+}
+
+func (vcs configuredVCS) Repository(ctx context.Context, dir string) (api.Repository, error) {
+	repo, err := vcs.VersionControlSystem.Repository(ctx, dir)
+	if repo == nil || err != nil {
+		return repo, err
+	}
+	if s := cfg.ForRepo(repo.Name(), repo.RootDir()); s.Name != "" {
+		slog.Debug("Renaming repository.", "repo", repo.Name(), "name", s.Name)
+		return renamedRepository{repo, s.Name}, nil
+	}
+	return repo, nil
+}
+
+// renamedRepository is a repository with a name from the configuration file.
+type renamedRepository struct {
+	api.Repository
+	name string
+}
+
+func (repo renamedRepository) Name() string { return repo.name }
+
+func (repo renamedRepository) Merged(ctx context.Context) ([]string, error) {
+	return api.Merged(ctx, repo.Repository)
+}
+
+func (repo renamedRepository) LastChanged(ctx context.Context, workUnitName string) (time.Time, error) {
+	return api.LastChanged(ctx, repo.Repository, workUnitName)
+}
+
+func (repo renamedRepository) InstallCheckoutHook(ctx context.Context, command string, force bool) error {
+	return api.InstallCheckoutHook(ctx, repo.Repository, command, force)
+}
+
+func (repo renamedRepository) StateFiles(ctx context.Context) ([]string, error) {
+	return api.StateFiles(ctx, repo.Repository)
+}
+
+func (repo renamedRepository) Delete(ctx context.Context, workUnitName string) error {
+	return api.Delete(ctx, repo.Repository, workUnitName)
+}
+
+func (repo renamedRepository) Parents(ctx context.Context) (map[string]string, error) {
+	return api.Parents(ctx, repo.Repository)
+}
+
+func (repo renamedRepository) Status(ctx context.Context, workUnitNames []string) (map[string]api.WorkUnitStatus, error) {
+	return api.Status(ctx, repo.Repository, workUnitNames)
+}
+
+func (repo renamedRepository) Log(ctx context.Context, workUnitName string, n int) ([]string, error) {
+	return api.Log(ctx, repo.Repository, workUnitName, n)
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/JeffFaer/tmux-vcs-sync/api"
+	"github.com/JeffFaer/tmux-vcs-sync/api/config"
+	"github.com/JeffFaer/tmux-vcs-sync/api/repotest"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRenamedRepository(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	defer func(old *config.Config) { cfg = old }(cfg)
+	cfg = config.Default()
+	cfg.Repos = []config.Repo{{MatchName: "repo", RepoSettings: config.RepoSettings{Name: "renamed"}}}
+
+	vcs := configuredVCS{repotest.NewVCS("testing/", repotest.RepoConfig{
+		Name:      "repo",
+		WorkUnits: map[string][]string{repotest.DefaultWorkUnitName: {"foo"}},
+	})}
+	repo, err := vcs.Repository(ctx, "testing/repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := repo.Name(); got != "renamed" {
+		t.Errorf("repo.Name() = %q, want %q", got, "renamed")
+	}
+
+	// Renaming the repository doesn't hide what else it can do.
+	parents, err := api.Parents(ctx, repo)
+	if err != nil {
+		t.Errorf("api.Parents() = _, %v", err)
+	}
+	want := map[string]string{repotest.DefaultWorkUnitName: "", "foo": repotest.DefaultWorkUnitName}
+	if diff := cmp.Diff(want, parents); diff != "" {
+		t.Errorf("api.Parents() diff (-want +got)\n%s", diff)
+	}
+	if _, err := api.Status(ctx, repo, []string{"foo"}); err != nil {
+		t.Errorf("api.Status(foo) = _, %v", err)
+	}
+	if _, err := api.Log(ctx, repo, "foo", 1); err != nil {
+		t.Errorf("api.Log(foo) = _, %v", err)
+	}
+	if err := repo.Update(ctx, repotest.DefaultWorkUnitName); err != nil {
+		t.Fatal(err)
+	}
+	if err := api.Delete(ctx, repo, "foo"); err != nil {
+		t.Errorf("api.Delete(foo) = %v", err)
+	}
+	if ok, err := repo.Exists(ctx, "foo"); err != nil || ok {
+		t.Errorf("repo.Exists(foo) = %t, %v, want false", ok, err)
+	}
+}
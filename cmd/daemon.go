@@ -0,0 +1,313 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/JeffFaer/tmux-vcs-sync/api"
+	"github.com/JeffFaer/tmux-vcs-sync/api/config"
+	"github.com/JeffFaer/tmux-vcs-sync/tmux"
+	"github.com/JeffFaer/tmux-vcs-sync/tmux/state"
+	"github.com/JeffFaer/tmux-vcs-sync/watch"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// staleOption is the tmux user option that the daemon sets on sessions
+	// whose work units no longer exist.
+	staleOption = "tmux-vcs-sync-stale"
+	// debounce is how long the daemon waits for a repository to stop changing
+	// before reacting to it. A single checkout changes several files, and
+	// tmux-vcs-sync's own commands need a moment to update tmux after they
+	// update a repository.
+	debounce = 250 * time.Millisecond
+)
+
+func init() {
+	rootCmd.AddCommand(daemonCommand)
+}
+
+var daemonCommand = &cobra.Command{
+	Use:   "daemon",
+	Short: "Keep tmux in sync with repositories in the background.",
+	Long: `Keep tmux in sync with repositories in the background, until the tmux server exits.
+
+The daemon watches the repositories of the tmux server's sessions for checkouts, and the tmux server for sessions that are created, closed, or renamed. How it reacts is configured in the [daemon] section of the configuration file:
+
+- When a repository checks out a different work unit, the clients that are displaying its sessions switch to the work unit's session.
+- When a session is renamed with tmux's rename-session, its work unit is renamed to match.
+- Sessions whose work units no longer exist get the @` + staleOption + ` option, which can be used in formats like status-left.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		return runDaemon(ctx)
+	},
+}
+
+func runDaemon(ctx context.Context) error {
+	srv, _ := selectedServer(ctx)
+	notifications, err := srv.Watch(ctx)
+	if err != nil {
+		return fmt.Errorf("could not watch tmux server: %w", err)
+	}
+	w, err := watch.New()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	d := newDaemon(srv, registered(), cfg.Daemon, w)
+	if err := d.refresh(ctx); err != nil {
+		return err
+	}
+	// The daemon runs until the tmux server exits, which is always slow enough
+	// to record a trace.
+	if err := stopTrace(); err != nil {
+		return err
+	}
+
+	pending := make(map[string]api.Repository)
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case n, ok := <-notifications:
+			if !ok {
+				slog.Info("tmux server exited.")
+				return nil
+			}
+			if err := d.notified(ctx, n); err != nil {
+				slog.Warn("Could not react to tmux.", "notification", n.Name, "error", err)
+			}
+		case path, ok := <-w.Events():
+			if !ok {
+				return nil
+			}
+			if repo := d.repos[path]; repo != nil {
+				pending[repo.RootDir()] = repo
+				timer.Reset(debounce)
+			}
+		case err := <-w.Errors():
+			return err
+		case <-timer.C:
+			for _, repo := range pending {
+				if err := d.repoChanged(ctx, repo); err != nil {
+					slog.Warn("Could not react to repository.", "repo", repo.Name(), "error", err)
+				}
+			}
+			clear(pending)
+		}
+	}
+}
+
+// daemon is the state of the daemon command between changes.
+type daemon struct {
+	srv     tmux.Server
+	vcs     api.VersionControlSystems
+	cfg     config.Daemon
+	watcher *watch.Watcher
+
+	// st is refreshed whenever tmux or a repository changes.
+	st *state.State
+	// repos are the watched repositories, keyed by their state files.
+	repos map[string]api.Repository
+	// current is the last known current work unit of each repository, keyed by
+	// root directory.
+	current map[string]string
+	// stale are the IDs of the sessions that are marked as stale.
+	stale map[string]bool
+}
+
+// newDaemon creates a daemon. watcher may be nil, in which case repositories
+// aren't watched.
+func newDaemon(srv tmux.Server, vcs api.VersionControlSystems, cfg config.Daemon, watcher *watch.Watcher) *daemon {
+	return &daemon{
+		srv:     srv,
+		vcs:     vcs,
+		cfg:     cfg,
+		watcher: watcher,
+		repos:   make(map[string]api.Repository),
+		current: make(map[string]string),
+		stale:   make(map[string]bool),
+	}
+}
+
+// refresh updates the daemon's idea of the tmux server and starts watching any
+// new repositories.
+func (d *daemon) refresh(ctx context.Context) error {
+	st, err := newState(ctx, d.srv, d.vcs)
+	if err != nil {
+		return err
+	}
+	d.st = st
+	for _, repo := range st.Repositories() {
+		d.watch(ctx, repo)
+	}
+	return d.markStale(ctx)
+}
+
+// watch starts watching repo, if it isn't already being watched.
+func (d *daemon) watch(ctx context.Context, repo api.Repository) {
+	if _, ok := d.current[repo.RootDir()]; ok {
+		return
+	}
+	logger := slog.With("repo", repo.Name(), "dir", repo.RootDir())
+	cur, err := repo.Current(ctx)
+	if err != nil {
+		logger.Warn("Could not determine current work unit.", "error", err)
+	}
+	d.current[repo.RootDir()] = cur
+	if d.watcher == nil {
+		return
+	}
+
+	files, err := api.StateFiles(ctx, repo)
+	if err != nil {
+		logger.Warn("Can't watch repository.", "error", err)
+		return
+	}
+	for _, f := range files {
+		if err := d.watcher.Add(f); err != nil {
+			logger.Warn("Can't watch repository file.", "file", f, "error", err)
+			continue
+		}
+		d.repos[f] = repo
+	}
+	logger.Info("Watching repository.")
+}
+
+// notified reacts to a change in the tmux server.
+func (d *daemon) notified(ctx context.Context, n tmux.Notification) error {
+	switch n.Name {
+	case "sessions-changed":
+		// A session was created or closed.
+		return d.refresh(ctx)
+	case "session-renamed":
+		id, _, _ := strings.Cut(n.Args, " ")
+		return d.sessionRenamed(ctx, id)
+	}
+	return nil
+}
+
+// sessionRenamed reacts to the tmux session with the given ID being renamed.
+func (d *daemon) sessionRenamed(ctx context.Context, id string) error {
+	if err := d.refresh(ctx); err != nil {
+		return err
+	}
+	if d.cfg.OnSessionRenamed != "rename" {
+		return nil
+	}
+	sesh, err := sessionByID(ctx, d.srv, id)
+	if err != nil {
+		return err
+	}
+	if err := syncRenamedSession(ctx, d.st, sesh); err != nil {
+		return err
+	}
+	return d.refresh(ctx)
+}
+
+// repoChanged reacts to a change in repo.
+func (d *daemon) repoChanged(ctx context.Context, repo api.Repository) error {
+	cur, err := repo.Current(ctx)
+	if err != nil {
+		// The repository might be in the middle of an operation, like a rebase.
+		slog.Info("Could not determine current work unit.", "repo", repo.Name(), "error", err)
+		return nil
+	}
+	old := d.current[repo.RootDir()]
+	d.current[repo.RootDir()] = cur
+	if err := d.refresh(ctx); err != nil {
+		return err
+	}
+	if cur == old || d.cfg.OnCheckout != "switch" {
+		return nil
+	}
+	slog.Info("Repository checked out a different work unit.", "repo", repo.Name(), "old", old, "new", cur)
+	return d.switchClients(ctx, repo, cur)
+}
+
+// switchClients switches the clients that are displaying one of repo's
+// sessions to workUnitName's session, creating it if necessary.
+func (d *daemon) switchClients(ctx context.Context, repo api.Repository, workUnitName string) error {
+	clients, err := d.srv.ListClients(ctx)
+	if err != nil {
+		return err
+	}
+	var target tmux.Session
+	var errs []error
+	for _, c := range clients {
+		id, err := c.Property(ctx, tmux.ClientSessionID)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		sesh, err := sessionByID(ctx, d.srv, id)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		r, wu, err := d.st.WorkUnit(ctx, sesh)
+		if err != nil || r.RootDir() != repo.RootDir() || wu == workUnitName {
+			continue
+		}
+
+		if target == nil {
+			target = d.st.Session(repo, workUnitName)
+		}
+		if target == nil {
+			target, err = d.st.NewSession(ctx, repo, workUnitName)
+			if err != nil {
+				return errors.Join(append(errs, err)...)
+			}
+		}
+		if err := c.Switch(ctx, target); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// markStale updates which sessions are marked as stale.
+func (d *daemon) markStale(ctx context.Context) error {
+	if !d.cfg.MarkStale {
+		return nil
+	}
+	stale := make(map[string]bool)
+	var errs []error
+	for _, sesh := range d.st.StaleSessions(ctx) {
+		stale[sesh.ID()] = true
+		if d.stale[sesh.ID()] {
+			continue
+		}
+		slog.Info("Marking tmux session as stale.", "session_id", sesh.ID())
+		if err := sesh.SetUserOption(ctx, staleOption, "1"); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for id := range d.stale {
+		if stale[id] {
+			continue
+		}
+		sesh, err := sessionByID(ctx, d.srv, id)
+		if err != nil {
+			// The session was closed.
+			continue
+		}
+		if err := sesh.SetUserOption(ctx, staleOption, ""); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	d.stale = stale
+	return errors.Join(errs...)
+}
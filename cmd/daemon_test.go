@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/JeffFaer/tmux-vcs-sync/api"
+	"github.com/JeffFaer/tmux-vcs-sync/api/config"
+	"github.com/JeffFaer/tmux-vcs-sync/api/repotest"
+	"github.com/JeffFaer/tmux-vcs-sync/tmux"
+	"github.com/JeffFaer/tmux-vcs-sync/tmux/tmuxtest"
+)
+
+func TestDaemon(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	vcs := api.VersionControlSystems{
+		repotest.NewVCS("testing/", repotest.RepoConfig{
+			Name:      "repo",
+			WorkUnits: map[string][]string{repotest.DefaultWorkUnitName: {"foo", "bar"}},
+		}),
+	}
+	repo, err := vcs.MaybeFindRepository(ctx, "testing/repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Update(ctx, "foo"); err != nil {
+		t.Fatal(err)
+	}
+	// TestDisplayMenu uses small PIDs.
+	srv := tmuxtest.NewServer(1016)
+	foo, err := srv.NewSession(ctx, tmux.NewSessionOptions{Name: "foo", StartDir: "testing/repo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := srv.NewClient(foo.(*tmuxtest.Session))
+	d := newDaemon(srv, vcs, config.Default().Daemon, nil)
+	if err := d.refresh(ctx); err != nil {
+		t.Fatalf("d.refresh() = %v", err)
+	}
+	stale := func(sesh tmux.Session) string {
+		t.Helper()
+		opt := tmux.UserOption(staleOption)
+		v, err := sesh.Property(ctx, opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tmux.PropertyValue(opt, v)
+	}
+
+	// Checking out bar switches the client to a new session for it.
+	if err := repo.Update(ctx, "bar"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.repoChanged(ctx, repo); err != nil {
+		t.Fatalf("d.repoChanged() = %v", err)
+	}
+	bar := d.st.Session(repo, "bar")
+	if bar == nil {
+		t.Fatalf("d.st.Session(bar) = nil, want a new session")
+	}
+	if client.Session.ID() != bar.ID() {
+		t.Errorf("Client is displaying %q, want %q", client.Session.ID(), bar.ID())
+	}
+
+	// Deleting foo marks its session as stale.
+	if err := api.Delete(ctx, repo, "foo"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.repoChanged(ctx, repo); err != nil {
+		t.Fatalf("d.repoChanged() = %v", err)
+	}
+	if got := stale(foo); got != "1" {
+		t.Errorf("foo's @%s = %q, want %q", staleOption, got, "1")
+	}
+	if got := stale(bar); got != "" {
+		t.Errorf("bar's @%s = %q, want %q", staleOption, got, "")
+	}
+	if client.Session.ID() != bar.ID() {
+		t.Errorf("Client is displaying %q, want %q since the current work unit didn't change", client.Session.ID(), bar.ID())
+	}
+
+	// Renaming bar's session renames bar.
+	if err := bar.Rename(ctx, "baz"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.notified(ctx, tmux.Notification{Name: "session-renamed", Args: bar.ID() + " baz"}); err != nil {
+		t.Fatalf("d.notified(session-renamed) = %v", err)
+	}
+	if ok, err := repo.Exists(ctx, "baz"); err != nil || !ok {
+		t.Errorf("repo.Exists(baz) = %t, %v, want true", ok, err)
+	}
+}
@@ -4,20 +4,37 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
+	"os"
 	"slices"
 	"strings"
 
 	"github.com/JeffFaer/go-stdlib-ext/morecmp"
 	"github.com/JeffFaer/go-stdlib-ext/moremaps"
 	"github.com/JeffFaer/tmux-vcs-sync/api"
+	"github.com/JeffFaer/tmux-vcs-sync/api/config"
 	"github.com/JeffFaer/tmux-vcs-sync/tmux"
 	"github.com/JeffFaer/tmux-vcs-sync/tmux/state"
+	"github.com/kballard/go-shellquote"
 	"github.com/spf13/cobra"
 )
 
-var keyShortcuts = strings.Split("0123456789wertyuiopasdfghlzxcvbnm", "")
+// keyShortcuts are the keys assigned to menu entries, in order. They can be
+// changed in the configuration file.
+var keyShortcuts = strings.Split(config.Default().Menu.KeyShortcuts, "")
+
+// unknownGroup is the ID of the menu group for sessions that don't belong to
+// any repository.
+const unknownGroup = "unknown"
+
+var (
+	menuGroup   string
+	menuActions bool
+)
 
 func init() {
+	displayMenuCommand.Flags().StringVar(&menuGroup, "group", "", "Only display the sessions in this group.")
+	displayMenuCommand.Flags().BoolVar(&menuActions, "actions", false, "Instead of switching to the selected session, open a menu of actions for it.")
 	rootCmd.AddCommand(displayMenuCommand)
 }
 
@@ -27,12 +44,32 @@ var displayMenuCommand = &cobra.Command{
 	Short:  "Run tmux display-menu to switch to a new session.",
 	Args:   cobra.ExactArgs(0),
 	RunE: func(cmd *cobra.Command, _ []string) error {
-		return displayMenu(cmd.Context())
+		return displayMenu(cmd.Context(), menuOptions{group: menuGroup, actions: menuActions})
 	},
 }
 
-func displayMenu(ctx context.Context) error {
-	curSesh, err := tmux.CurrentSession()
+type menuOptions struct {
+	// Only display the sessions in this group.
+	group string
+	// Open a menu of actions for each session instead of switching to it.
+	actions bool
+}
+
+func (opts menuOptions) args() []string {
+	args := []string{"display-menu"}
+	if opts.group != "" {
+		args = append(args, "--group", opts.group)
+	}
+	if opts.actions {
+		args = append(args, "--actions")
+	}
+	return args
+}
+
+func displayMenu(ctx context.Context, opts menuOptions) error {
+	// Building the menu needs quite a few tmux commands. Send them all over the
+	// same connection.
+	curSesh, err := tmux.CurrentSession(tmux.ControlMode())
 	if err != nil {
 		return err
 	}
@@ -42,7 +79,16 @@ func displayMenu(ctx context.Context) error {
 		return err
 	}
 
-	menu, err := createMenu(ctx, curSesh, api.Registered())
+	others := otherServers(ctx, curSesh.Server(), tmux.ControlMode())
+	menu, err := createMenu(ctx, curSesh, others, registered(), opts)
+	if err := curSesh.Server().Close(); err != nil {
+		slog.Warn("Could not close tmux connection.", "error", err)
+	}
+	for _, o := range others {
+		if err := o.srv.Close(); err != nil {
+			slog.Warn("Could not close tmux connection.", "server", o.srv, "error", err)
+		}
+	}
 	if err != nil {
 		return err
 	}
@@ -53,11 +99,175 @@ func displayMenu(ctx context.Context) error {
 	return errors.Join(curClient.DisplayMenu(ctx, menu), err)
 }
 
-func createMenu(ctx context.Context, curSesh tmux.Session, vcs api.VersionControlSystems) ([]tmux.MenuElement, error) {
-	st, err := state.New(ctx, curSesh.Server(), vcs)
+type menuSession struct {
+	name          string
+	id            string
+	current       bool
+	unknownToRepo bool
+	// server is the socket of the tmux server from tmux.servers that the session
+	// belongs to, if it doesn't belong to the current tmux server.
+	server string
+
+	// The repository and work unit this session represents, if any.
+	repo     api.Repository
+	workUnit string
+	status   api.WorkUnitStatus
+	// tree draws the session's position in its repository's stack of work units.
+	tree  string
+	depth int
+}
+
+// marker distinguishes the current session and sessions whose work units don't
+// exist.
+func (sesh menuSession) marker() string {
+	switch {
+	case sesh.current:
+		return "*"
+	case sesh.unknownToRepo:
+		return "?"
+	default:
+		return " "
+	}
+}
+
+// displayName is the name of the session in the menu, decorated with its
+// position in the stack and the status of its work unit.
+func (sesh menuSession) displayName() string {
+	name := sesh.tree + sesh.name
+	if sesh.status.Ahead > 0 {
+		name += fmt.Sprintf(" ↑%d", sesh.status.Ahead)
+	}
+	if sesh.status.Behind > 0 {
+		name += fmt.Sprintf(" ↓%d", sesh.status.Behind)
+	}
+	if sesh.status.Dirty {
+		name += " !"
+	}
+	return name
+}
+
+// sessionGroup is a set of sessions that are displayed together in the menu.
+type sessionGroup struct {
+	// id identifies the group for display-menu --group.
+	id string
+	// title is a human-friendly name for the group.
+	title    string
+	sessions []menuSession
+}
+
+// createMenu creates the entries for display-menu. The sessions of the other
+// tmux servers are included after the current server's.
+func createMenu(ctx context.Context, curSesh tmux.Session, others []otherServer, vcs api.VersionControlSystems, opts menuOptions) ([]tmux.MenuElement, error) {
+	groups, err := sessionGroups(ctx, curSesh.Server(), curSesh, vcs)
 	if err != nil {
 		return nil, err
 	}
+	return tmuxMenu(append(groups, otherSessionGroups(ctx, others, vcs)...), opts)
+}
+
+// tmuxMenu renders groups as entries for display-menu.
+// If opts.group is non-empty, only the sessions in that group are included.
+// Otherwise, all sessions are included unless there are more sessions than
+// keyShortcuts. In that case, groups other than the current session's are
+// collapsed into entries that open a separate menu for that group.
+func tmuxMenu(groups []sessionGroup, opts menuOptions) ([]tmux.MenuElement, error) {
+	if opts.group != "" {
+		i := slices.IndexFunc(groups, func(g sessionGroup) bool { return g.id == opts.group })
+		if i < 0 {
+			return nil, fmt.Errorf("no sessions in group %q", opts.group)
+		}
+		return menuEntries(keyShortcuts, groups[i].sessions, opts.actions), nil
+	}
+
+	var n int
+	for _, g := range groups {
+		n += len(g.sessions)
+	}
+	var menu []tmux.MenuElement
+	keys := keyShortcuts
+	for i, g := range groups {
+		if i > 0 {
+			menu = append(menu, tmux.MenuSpacer{})
+		}
+		if n <= len(keyShortcuts) || hasCurrentSession(g) {
+			menu = append(menu, menuEntries(keys, g.sessions, opts.actions)...)
+			keys = keys[min(len(keys), len(g.sessions)):]
+			continue
+		}
+		var key string
+		if len(keys) > 0 {
+			key = keys[0]
+			keys = keys[1:]
+		}
+		groupOpts := opts
+		groupOpts.group = g.id
+		menu = append(menu, tmux.MenuEntry{
+			Name:    fmt.Sprintf(" %s (%d)...", g.title, len(g.sessions)),
+			Key:     key,
+			Command: callbackCommand(groupOpts.args()...),
+		})
+	}
+	return menu, nil
+}
+
+// menuEntries creates menu entries for the given sessions, using keys for
+// their shortcuts. If there are more sessions than keys, the remaining sessions
+// won't have a shortcut, but they can still be selected with the arrow keys.
+// Selecting an entry switches to its session, or opens a menu of actions for
+// the session if actions is true.
+func menuEntries(keys []string, sessions []menuSession, actions bool) []tmux.MenuElement {
+	var menu []tmux.MenuElement
+	for _, sesh := range sessions {
+		name := sesh.marker() + sesh.displayName()
+		var key string
+		if len(keys) > 0 {
+			key = keys[0]
+			keys = keys[1:]
+		}
+		if sesh.current {
+			key = "q"
+		}
+		if sesh.server != "" {
+			// The session can't be switched to, or acted on, from this server.
+			menu = append(menu, tmux.MenuEntry{
+				Name:    name,
+				Key:     key,
+				Command: otherServerCommand(sesh),
+			})
+			continue
+		}
+		if actions {
+			menu = append(menu, tmux.Submenu{
+				Name:     name,
+				Key:      key,
+				Title:    sesh.name,
+				Elements: actionMenu(sesh),
+			})
+			continue
+		}
+		menu = append(menu, tmux.MenuEntry{
+			Name: name,
+			Key:  key,
+			// TODO: Should this be a `run-shell tmux-vcs-sync update` instead?
+			Command: fmt.Sprintf("switch-client -t %s", sesh.id),
+		})
+	}
+	return menu
+}
+
+func hasCurrentSession(g sessionGroup) bool {
+	return slices.ContainsFunc(g.sessions, func(s menuSession) bool { return s.current })
+}
+
+// sessionGroups groups the sessions in srv by repository.
+// The group containing curSesh, if any, is first, followed by each repository
+// and then any sessions that don't belong to a repository.
+func sessionGroups(ctx context.Context, srv tmux.Server, curSesh tmux.Session, vcs api.VersionControlSystems) ([]sessionGroup, error) {
+	st, err := newState(ctx, srv, vcs)
+	if err != nil {
+		return nil, err
+	}
+	isCurrent := func(sesh tmux.Session) bool { return curSesh != nil && sesh.ID() == curSesh.ID() }
 
 	sessions := st.Sessions()
 	sessionsByRepo := make(map[state.RepoName]map[string]tmux.Session)
@@ -68,16 +278,9 @@ func createMenu(ctx context.Context, curSesh tmux.Session, vcs api.VersionContro
 		sessionsByRepo[n.RepoName][n.WorkUnit] = sesh
 	}
 
-	repoCmp := morecmp.Comparing(func(n state.RepoName) string { return n.VCS }).
-		AndThen(morecmp.Comparing(func(n state.RepoName) string { return n.Repo }))
-	repoNames := moremaps.SortedKeysFunc(sessionsByRepo, repoCmp)
+	repoNames := moremaps.SortedKeysFunc(sessionsByRepo, repoNameCmp)
 
-	type session struct {
-		name          string
-		id            string
-		unknownToRepo bool
-	}
-	var orderedSessions [][]session
+	var groups []sessionGroup
 	repos := st.Repositories()
 	for _, n := range repoNames {
 		repo := repos[n]
@@ -99,66 +302,184 @@ func createMenu(ctx context.Context, curSesh tmux.Session, vcs api.VersionContro
 		if err := repo.Sort(ctx, workUnits); err != nil {
 			return nil, err
 		}
-		var group []session
-		for _, wu := range workUnits {
-			sesh := sessions[wu]
-			n := state.NewWorkUnitName(repo, wu)
-			group = append(group, session{name: st.SessionName(n), id: sesh.ID()})
+		stack, statuses := decorate(ctx, repo, workUnits)
+		group := sessionGroup{id: fmt.Sprintf("%s:%s:%s", n.VCS, n.Repo, n.Dir), title: st.RepoLabel(n)}
+		for _, node := range stack {
+			sesh := sessions[node.workUnit]
+			n := state.NewWorkUnitName(repo, node.workUnit)
+			group.sessions = append(group.sessions, menuSession{name: st.SessionName(n), id: sesh.ID(), current: isCurrent(sesh), repo: repo, workUnit: node.workUnit, status: statuses[node.workUnit], tree: node.tree, depth: node.depth})
 		}
 		for _, wu := range moremaps.SortedKeys(sessions) {
 			if !exists[wu] {
 				sesh := sessions[wu]
 				n := state.NewWorkUnitName(repo, wu)
-				group = append(group, session{name: st.SessionName(n), id: sesh.ID(), unknownToRepo: true})
+				group.sessions = append(group.sessions, menuSession{name: st.SessionName(n), id: sesh.ID(), current: isCurrent(sesh), unknownToRepo: true, repo: repo, workUnit: wu})
 			}
 		}
-		orderedSessions = append(orderedSessions, group)
+		groups = append(groups, group)
 	}
 
 	unknownSessions := st.UnknownSessions()
 	if len(unknownSessions) > 0 {
-		var group []session
+		group := sessionGroup{id: unknownGroup, title: "other"}
 		for _, n := range moremaps.SortedKeys(unknownSessions) {
-			group = append(group, session{name: n, id: unknownSessions[n].ID()})
+			group.sessions = append(group.sessions, menuSession{name: n, id: unknownSessions[n].ID(), current: isCurrent(unknownSessions[n])})
 		}
-		orderedSessions = append(orderedSessions, group)
+		groups = append(groups, group)
 	}
 
-	hasCurrentSession := func(group []session) bool {
-		for _, s := range group {
-			if s.id == curSesh.ID() {
-				return true
+	slices.SortStableFunc(groups, morecmp.ComparingFunc(hasCurrentSession, morecmp.TrueFirst()))
+	return groups, nil
+}
+
+// otherSessionGroups groups the sessions of each of the other tmux servers by
+// repository, like sessionGroups. The groups' IDs and titles say which server
+// they belong to.
+func otherSessionGroups(ctx context.Context, others []otherServer, vcs api.VersionControlSystems) []sessionGroup {
+	var ret []sessionGroup
+	for _, o := range others {
+		groups, err := sessionGroups(ctx, o.srv, nil, vcs)
+		if err != nil {
+			slog.Warn("Skipping tmux server.", "server", o.socket, "error", err)
+			continue
+		}
+		for _, g := range groups {
+			g.id = fmt.Sprintf("%s@%s", g.id, o.socket)
+			g.title = fmt.Sprintf("%s (%s)", g.title, o.socket)
+			for i := range g.sessions {
+				g.sessions[i].server = o.socket
 			}
+			ret = append(ret, g)
 		}
-		return false
 	}
-	slices.SortStableFunc(orderedSessions, morecmp.ComparingFunc(hasCurrentSession, morecmp.TrueFirst()))
+	return ret
+}
 
-	var menu []tmux.MenuElement
-	keys := keyShortcuts
-	for i, group := range orderedSessions {
-		if i > 0 {
-			menu = append(menu, tmux.MenuSpacer{})
+// otherServerCommand creates a tmux command that attaches to sesh, which
+// belongs to another tmux server, in a popup.
+func otherServerCommand(sesh menuSession) string {
+	args := append(socketArgs(sesh.server), "update", "--id", sesh.id)
+	return tmux.FormatCommand("display-popup", "-E", "-w", "90%", "-h", "90%", shellCommand(args...))
+}
+
+// repoNameCmp orders repositories by name, and then clones of the same
+// repository by directory.
+var repoNameCmp = morecmp.Comparing(func(n state.RepoName) string { return n.VCS }).
+	AndThen(morecmp.Comparing(func(n state.RepoName) string { return n.Repo })).
+	AndThen(morecmp.Comparing(func(n state.RepoName) string { return n.Dir }))
+
+// decorate arranges the topologically sorted workUnits into a tree and finds
+// their statuses. Those are only decorations, so if they can't be determined
+// the work units are left in a flat list without statuses.
+func decorate(ctx context.Context, repo api.Repository, workUnits []string) ([]treeNode, map[string]api.WorkUnitStatus) {
+	logErr := func(msg string, err error) {
+		if errors.Is(err, errors.ErrUnsupported) {
+			slog.Debug(msg, "repo", repo.Name(), "error", err)
+		} else {
+			slog.Warn(msg, "repo", repo.Name(), "error", err)
 		}
-		for _, sesh := range group {
-			name := sesh.name
-			key := keys[0]
-			keys = keys[1:]
-			if sesh.id == curSesh.ID() {
-				key = "q"
-				name = "*" + name
-			} else if sesh.unknownToRepo {
-				name = "?" + name
+	}
+
+	var stack []treeNode
+	parents, err := api.Parents(ctx, repo)
+	if err == nil {
+		stack, err = workUnitTree(repo, parents, workUnits)
+	}
+	if err != nil {
+		logErr("Could not arrange work units into a tree.", err)
+		stack = nil
+		for _, wu := range workUnits {
+			stack = append(stack, treeNode{workUnit: wu})
+		}
+	}
+	statuses, err := api.Status(ctx, repo, workUnits)
+	if err != nil {
+		logErr("Could not determine the status of work units.", err)
+	}
+	return stack, statuses
+}
+
+type treeNode struct {
+	workUnit string
+	tree     string
+	depth    int
+}
+
+// workUnitTree arranges the topologically sorted workUnits into a tree based on
+// parents, in depth-first order. A work unit's parent in the tree is its
+// closest ancestor in workUnits, so work units whose parents don't have sessions
+// are still attached to the rest of the stack.
+func workUnitTree(repo api.Repository, parents map[string]string, workUnits []string) ([]treeNode, error) {
+	included := make(map[string]bool)
+	for _, wu := range workUnits {
+		included[wu] = true
+	}
+	parent := func(wu string) (string, error) {
+		p, ok := parents[wu]
+		if !ok {
+			return "", fmt.Errorf("could not determine parent of %s %q", repo.VCS().WorkUnitName(), wu)
+		}
+		return p, nil
+	}
+
+	var roots []string
+	children := make(map[string][]string)
+	for _, wu := range workUnits {
+		seen := map[string]bool{wu: true}
+		p, err := parent(wu)
+		for ; err == nil && p != "" && !included[p]; p, err = parent(p) {
+			if seen[p] {
+				return nil, fmt.Errorf("%s %q has a cyclic ancestry", repo.VCS().WorkUnitName(), wu)
+			}
+			seen[p] = true
+		}
+		if err != nil {
+			return nil, err
+		}
+		if p == "" {
+			roots = append(roots, wu)
+		} else {
+			children[p] = append(children[p], wu)
+		}
+	}
+
+	var ret []treeNode
+	var visit func(wu, indent, branch string, depth int)
+	visit = func(wu, indent, branch string, depth int) {
+		ret = append(ret, treeNode{workUnit: wu, tree: indent + branch, depth: depth})
+		switch branch {
+		case "├─ ":
+			indent += "│  "
+		case "└─ ":
+			indent += "   "
+		}
+		for i, child := range children[wu] {
+			if i == len(children[wu])-1 {
+				visit(child, indent, "└─ ", depth+1)
 			} else {
-				name = " " + name
+				visit(child, indent, "├─ ", depth+1)
 			}
-			menu = append(menu, tmux.MenuEntry{
-				Name: name,
-				Key:  key,
-				// TODO: Should this be a `run-shell tmux-vcs-sync update` instead?
-				Command: fmt.Sprintf("switch-client -t %s", sesh.id),
-			})
 		}
 	}
-	return menu, nil
+	for _, wu := range roots {
+		visit(wu, "", "", 0)
+	}
+	return ret, nil
+}
+
+// callbackCommand creates a tmux command that executes this tool with the
+// given arguments.
+func callbackCommand(args ...string) string {
+	return tmux.FormatCommand("run-shell", shellCommand(args...))
+}
+
+// shellCommand creates a shell command that executes this tool with the given
+// arguments.
+func shellCommand(args ...string) string {
+	exe, err := os.Executable()
+	if err != nil {
+		slog.Warn("Could not determine path to executable.", "error", err)
+		exe = rootCmd.Name()
+	}
+	return shellquote.Join(append([]string{exe}, args...)...)
 }
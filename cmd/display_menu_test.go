@@ -2,6 +2,9 @@ package cmd
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"testing"
 	"time"
 
@@ -14,12 +17,47 @@ import (
 )
 
 func TestDisplayMenu(t *testing.T) {
+	// manyWorkUnits are more work units than there are keyShortcuts.
+	var manyWorkUnits []string
+	for i := range len(keyShortcuts) + 7 {
+		manyWorkUnits = append(manyWorkUnits, fmt.Sprintf("wu%02d", i))
+	}
+	manySessions := func(dir string) []tmux.NewSessionOptions {
+		var ret []tmux.NewSessionOptions
+		for _, wu := range manyWorkUnits {
+			ret = append(ret, tmux.NewSessionOptions{Name: wu, StartDir: dir})
+		}
+		return ret
+	}
+	// manyEntries are the menu entries for manyWorkUnits, using whatever keys are
+	// left after the first skip keys are used. If hasParent, the entries are drawn
+	// as children of the entry before them.
+	manyEntries := func(prefix string, skip int, hasParent bool) []tmux.MenuElement {
+		var ret []tmux.MenuElement
+		for i, wu := range manyWorkUnits {
+			var key string
+			if j := skip + i; j < len(keyShortcuts) {
+				key = keyShortcuts[j]
+			}
+			var tree string
+			if hasParent && i == len(manyWorkUnits)-1 {
+				tree = "└─ "
+			} else if hasParent {
+				tree = "├─ "
+			}
+			ret = append(ret, tmux.MenuEntry{Name: " " + tree + prefix + wu, Key: key})
+		}
+		return ret
+	}
+
 	for i, tc := range []struct {
 		name string
 
 		sessions []tmux.NewSessionOptions
 		current  tmux.NewSessionOptions
 		vcs      api.VersionControlSystems
+		group    string
+		actions  bool
 
 		want []tmux.MenuElement
 	}{
@@ -74,9 +112,47 @@ func TestDisplayMenu(t *testing.T) {
 
 			want: []tmux.MenuElement{
 				tmux.MenuEntry{Name: "*" + repotest.DefaultWorkUnitName, Key: "q"},
-				tmux.MenuEntry{Name: " z", Key: "1"},
-				tmux.MenuEntry{Name: " y", Key: "2"},
-				tmux.MenuEntry{Name: " x", Key: "3"},
+				tmux.MenuEntry{Name: " └─ z", Key: "1"},
+				tmux.MenuEntry{Name: "    └─ y", Key: "2"},
+				tmux.MenuEntry{Name: "       └─ x", Key: "3"},
+			},
+		},
+		{
+			name: "SingleRepo_Stack",
+
+			sessions: []tmux.NewSessionOptions{
+				{Name: "a", StartDir: "testing/repo"},
+				{Name: "a1", StartDir: "testing/repo"},
+				{Name: "a2", StartDir: "testing/repo"},
+				{Name: "b1", StartDir: "testing/repo"},
+				{Name: "c", StartDir: "testing/repo"},
+			},
+			current: tmux.NewSessionOptions{Name: repotest.DefaultWorkUnitName, StartDir: "testing/repo"},
+			vcs: api.VersionControlSystems{
+				repotest.NewVCS("testing/", repotest.RepoConfig{
+					Name: "repo",
+					// b doesn't have a session, so b1 is drawn as a child of root.
+					WorkUnits: map[string][]string{
+						repotest.DefaultWorkUnitName: {"a", "b", "c"},
+						"a":                          {"a1", "a2"},
+						"b":                          {"b1"},
+					},
+					Status: map[string]api.WorkUnitStatus{
+						repotest.DefaultWorkUnitName: {Dirty: true},
+						"a1":                         {Ahead: 2},
+						"b1":                         {Behind: 1},
+						"c":                          {Ahead: 1, Behind: 3, Dirty: true},
+					},
+				}),
+			},
+
+			want: []tmux.MenuElement{
+				tmux.MenuEntry{Name: "*" + repotest.DefaultWorkUnitName + " !", Key: "q"},
+				tmux.MenuEntry{Name: " ├─ a", Key: "1"},
+				tmux.MenuEntry{Name: " │  ├─ a1 ↑2", Key: "2"},
+				tmux.MenuEntry{Name: " │  └─ a2", Key: "3"},
+				tmux.MenuEntry{Name: " ├─ b1 ↓1", Key: "4"},
+				tmux.MenuEntry{Name: " └─ c ↑1 ↓3 !", Key: "5"},
 			},
 		},
 		{
@@ -176,6 +252,92 @@ func TestDisplayMenu(t *testing.T) {
 				tmux.MenuEntry{Name: " repo2>foo", Key: "8"},
 			},
 		},
+		{
+			name: "Actions",
+
+			sessions: []tmux.NewSessionOptions{
+				{Name: "foo", StartDir: "testing/repo"},
+				{Name: "bar", StartDir: "someOtherDir"},
+			},
+			current: tmux.NewSessionOptions{Name: repotest.DefaultWorkUnitName, StartDir: "testing/repo"},
+			vcs: api.VersionControlSystems{
+				repotest.NewVCS("testing/", repotest.RepoConfig{
+					Name:      "repo",
+					WorkUnits: map[string][]string{repotest.DefaultWorkUnitName: {"foo"}},
+				}),
+			},
+			actions: true,
+
+			want: []tmux.MenuElement{
+				tmux.Submenu{Name: "*" + repotest.DefaultWorkUnitName, Key: "q", Title: repotest.DefaultWorkUnitName},
+				tmux.Submenu{Name: " └─ foo", Key: "1", Title: "foo"},
+				tmux.MenuSpacer{},
+				tmux.Submenu{Name: " bar", Key: "2", Title: "bar"},
+			},
+		},
+		{
+			name: "ManySessions_SingleRepo",
+
+			sessions: manySessions("testing/repo"),
+			current:  tmux.NewSessionOptions{Name: repotest.DefaultWorkUnitName, StartDir: "testing/repo"},
+			vcs: api.VersionControlSystems{
+				repotest.NewVCS("testing/", repotest.RepoConfig{
+					Name:      "repo",
+					WorkUnits: map[string][]string{repotest.DefaultWorkUnitName: manyWorkUnits},
+				}),
+			},
+
+			want: append([]tmux.MenuElement{
+				tmux.MenuEntry{Name: "*" + repotest.DefaultWorkUnitName, Key: "q"},
+			}, manyEntries("", 1, true)...),
+		},
+		{
+			name: "ManySessions_MultipleRepos",
+
+			sessions: append([]tmux.NewSessionOptions{
+				{Name: "bar", StartDir: "testing/repo1"},
+				{Name: "foo", StartDir: "someOtherDir"},
+			}, manySessions("testing/repo2")...),
+			current: tmux.NewSessionOptions{Name: "foo", StartDir: "testing/repo1"},
+			vcs: api.VersionControlSystems{
+				repotest.NewVCS("testing/", repotest.RepoConfig{
+					Name:      "repo1",
+					WorkUnits: map[string][]string{repotest.DefaultWorkUnitName: {"foo", "bar"}},
+				}, repotest.RepoConfig{
+					Name:      "repo2",
+					WorkUnits: map[string][]string{repotest.DefaultWorkUnitName: manyWorkUnits},
+				}),
+			},
+
+			want: []tmux.MenuElement{
+				tmux.MenuEntry{Name: " repo1>bar", Key: "0"},
+				tmux.MenuEntry{Name: "*repo1>foo", Key: "q"},
+				tmux.MenuSpacer{},
+				tmux.MenuEntry{Name: fmt.Sprintf(" repo2 (%d)...", len(manyWorkUnits)), Key: "2"},
+				tmux.MenuSpacer{},
+				tmux.MenuEntry{Name: " other (1)...", Key: "3"},
+			},
+		},
+		{
+			name: "ManySessions_Group",
+
+			sessions: append([]tmux.NewSessionOptions{
+				{Name: "bar", StartDir: "testing/repo1"},
+			}, manySessions("testing/repo2")...),
+			current: tmux.NewSessionOptions{Name: "foo", StartDir: "testing/repo1"},
+			vcs: api.VersionControlSystems{
+				repotest.NewVCS("testing/", repotest.RepoConfig{
+					Name:      "repo1",
+					WorkUnits: map[string][]string{repotest.DefaultWorkUnitName: {"foo", "bar"}},
+				}, repotest.RepoConfig{
+					Name:      "repo2",
+					WorkUnits: map[string][]string{repotest.DefaultWorkUnitName: manyWorkUnits},
+				}),
+			},
+			group: "fake(testing/):repo2:testing/repo2",
+
+			want: manyEntries("repo2>", 0, false),
+		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
 			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -192,14 +354,163 @@ func TestDisplayMenu(t *testing.T) {
 				t.Errorf("tmux.NewSession(%#v) = _, %v", tc.current, err)
 			}
 
-			got, err := createMenu(ctx, current, tc.vcs)
+			got, err := createMenu(ctx, current, nil, tc.vcs, menuOptions{group: tc.group, actions: tc.actions})
 			if err != nil {
 				t.Errorf("createMenu() = _, %v", err)
 			}
 
-			if diff := cmp.Diff(tc.want, got, cmpopts.IgnoreFields(tmux.MenuEntry{}, "Command")); diff != "" {
+			if diff := cmp.Diff(tc.want, got, cmpopts.IgnoreFields(tmux.MenuEntry{}, "Command"), cmpopts.IgnoreFields(tmux.Submenu{}, "Elements")); diff != "" {
 				t.Errorf("createMenu() diff (-want +got)\n%s", diff)
 			}
 		})
 	}
 }
+
+func TestCreateMenu_OtherServers(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	vcs := api.VersionControlSystems{
+		repotest.NewVCS("testing/", repotest.RepoConfig{
+			Name:      "repo",
+			WorkUnits: map[string][]string{repotest.DefaultWorkUnitName: {"foo"}},
+		}),
+	}
+	srv := tmuxtest.NewServer(1017)
+	current, err := srv.NewSession(ctx, tmux.NewSessionOptions{Name: repotest.DefaultWorkUnitName, StartDir: "testing/repo"})
+	if err != nil {
+		t.Fatalf("tmux.NewSession() = _, %v", err)
+	}
+	other := tmuxtest.NewServer(1018)
+	for _, opts := range []tmux.NewSessionOptions{
+		{Name: "foo", StartDir: "testing/repo"},
+		{Name: "bar", StartDir: "someOtherDir"},
+	} {
+		if _, err := other.NewSession(ctx, opts); err != nil {
+			t.Fatalf("tmux.NewSession(%#v) = _, %v", opts, err)
+		}
+	}
+
+	// Servers that fail are skipped.
+	others := []otherServer{{"broken", brokenServer{other}}, {"personal", other}}
+	got, err := createMenu(ctx, current, others, vcs, menuOptions{actions: true})
+	if err != nil {
+		t.Fatalf("createMenu() = _, %v", err)
+	}
+	// Sessions on other servers can't be acted on.
+	want := []tmux.MenuElement{
+		tmux.Submenu{Name: "*" + repotest.DefaultWorkUnitName, Key: "q", Title: repotest.DefaultWorkUnitName},
+		tmux.MenuSpacer{},
+		tmux.MenuEntry{Name: " foo", Key: keyShortcuts[1]},
+		tmux.MenuSpacer{},
+		tmux.MenuEntry{Name: " bar", Key: keyShortcuts[2]},
+	}
+	if diff := cmp.Diff(want, got, cmpopts.IgnoreFields(tmux.MenuEntry{}, "Command"), cmpopts.IgnoreFields(tmux.Submenu{}, "Elements")); diff != "" {
+		t.Errorf("createMenu() diff (-want +got)\n%s", diff)
+	}
+	if e, ok := got[2].(tmux.MenuEntry); ok && !strings.Contains(e.Command, "--socket-name personal update --id") {
+		t.Errorf("createMenu()[2].Command = %q, want it to attach to the session on the other server", e.Command)
+	}
+}
+
+// brokenServer is a tmux server that can't list its sessions.
+type brokenServer struct {
+	tmux.Server
+}
+
+func (brokenServer) ListSessions(context.Context) (tmux.Sessions, error) {
+	return nil, errors.New("server exited unexpectedly")
+}
+
+// basicRepository only implements the methods that every api.Repository has.
+type basicRepository struct {
+	api.Repository
+}
+
+func TestDecorate_Unsupported(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	vcs := repotest.NewVCS("testing/", repotest.RepoConfig{
+		Name:      "repo",
+		WorkUnits: map[string][]string{repotest.DefaultWorkUnitName: {"foo"}},
+		Status:    map[string]api.WorkUnitStatus{"foo": {Dirty: true}},
+	})
+	repo, err := vcs.Repository(ctx, "testing/repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	workUnits := []string{repotest.DefaultWorkUnitName, "foo"}
+	stack, statuses := decorate(ctx, basicRepository{repo}, workUnits)
+	want := []treeNode{{workUnit: repotest.DefaultWorkUnitName}, {workUnit: "foo"}}
+	if diff := cmp.Diff(want, stack, cmp.AllowUnexported(treeNode{})); diff != "" {
+		t.Errorf("decorate() stack diff (-want +got)\n%s", diff)
+	}
+	if len(statuses) != 0 {
+		t.Errorf("decorate() statuses = %v, want none", statuses)
+	}
+}
+
+func TestActionMenu(t *testing.T) {
+	ctx := context.Background()
+	vcs := repotest.NewVCS("testing/", repotest.RepoConfig{
+		Name:      "repo",
+		WorkUnits: map[string][]string{repotest.DefaultWorkUnitName: {"foo"}},
+	})
+	repo, err := vcs.Repository(ctx, "testing/repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actions := func(current, noWorkUnit, adoptable bool) []tmux.MenuElement {
+		return []tmux.MenuElement{
+			tmux.MenuEntry{Name: "Switch", Key: "s", Disabled: current},
+			tmux.MenuEntry{Name: "Rename work unit", Key: "r", Disabled: noWorkUnit},
+			tmux.MenuEntry{Name: "New child work unit", Key: "c", Disabled: noWorkUnit},
+			tmux.MenuEntry{Name: "Delete work unit", Key: "d", Disabled: noWorkUnit},
+			tmux.MenuEntry{Name: "Adopt into a work unit", Key: "a", Disabled: !adoptable},
+			tmux.MenuSpacer{},
+			tmux.MenuEntry{Name: "Open in new window", Key: "w"},
+			tmux.MenuEntry{Name: "Kill session", Key: "x"},
+		}
+	}
+	for _, tc := range []struct {
+		name string
+		sesh menuSession
+
+		want []tmux.MenuElement
+	}{
+		{
+			name: "WorkUnit",
+			sesh: menuSession{name: "foo", id: "$1", repo: repo, workUnit: "foo"},
+			want: actions(false, false, false),
+		},
+		{
+			name: "Current",
+			sesh: menuSession{name: "foo", id: "$1", current: true, repo: repo, workUnit: "foo"},
+			want: actions(true, false, false),
+		},
+		{
+			name: "UnknownToRepo",
+			sesh: menuSession{name: "bar", id: "$1", repo: repo, workUnit: "bar", unknownToRepo: true},
+			want: actions(false, true, false),
+		},
+		{
+			name: "NoRepository",
+			sesh: menuSession{name: "bar", id: "$1"},
+			want: actions(false, true, true),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := actionMenu(tc.sesh)
+			if diff := cmp.Diff(tc.want, got, cmpopts.IgnoreFields(tmux.MenuEntry{}, "Command")); diff != "" {
+				t.Errorf("actionMenu() diff (-want +got)\n%s", diff)
+			}
+			// The user's input shouldn't outlive the action. The command is quoted
+			// inside of command-prompt's template.
+			unset := strings.ReplaceAll(tmux.FormatCommand("set-environment", "-gu", menuInputEnv), `"`, `\"`)
+			if e := got[1].(tmux.MenuEntry); !strings.Contains(e.Command, unset) {
+				t.Errorf("actionMenu()[1].Command = %q, want it to unset %s", e.Command, menuInputEnv)
+			}
+		})
+	}
+}
@@ -0,0 +1,288 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/JeffFaer/go-stdlib-ext/moremaps"
+	"github.com/JeffFaer/tmux-vcs-sync/api"
+	"github.com/JeffFaer/tmux-vcs-sync/api/config"
+	"github.com/JeffFaer/tmux-vcs-sync/tmux"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(doctorCommand)
+}
+
+var doctorCommand = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check for common problems with this tool's setup.",
+	Long: `Check the configuration file, VCS libraries, tmux, shell and tmux hooks, and tmux sessions for common problems, and suggest how to fix them.
+
+Each check passes, warns about something that might be a problem, or fails. The command fails if any check fails.`,
+	Args: cobra.ExactArgs(0),
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		return doctor(cmd.Context(), os.Stdout)
+	},
+}
+
+type checkStatus int
+
+const (
+	checkPass checkStatus = iota
+	checkWarn
+	checkFail
+)
+
+func (s checkStatus) String() string {
+	switch s {
+	case checkPass:
+		return "PASS"
+	case checkWarn:
+		return "WARN"
+	case checkFail:
+		return "FAIL"
+	}
+	return fmt.Sprintf("checkStatus(%d)", int(s))
+}
+
+type checkResult struct {
+	status  checkStatus
+	name    string
+	message string
+	// hint explains how to fix a warning or failure.
+	hint string
+}
+
+func doctor(ctx context.Context, w io.Writer) error {
+	var results []checkResult
+	results = append(results, checkConfig())
+	results = append(results, checkPlugins(ctx)...)
+
+	srv, _ := selectedServer(ctx)
+	results = append(results, checkTmux(ctx, srv))
+	results = append(results, checkEnvironment(ctx))
+	if home, err := os.UserHomeDir(); err != nil {
+		results = append(results, checkResult{status: checkWarn, name: "hooks", message: fmt.Sprintf("Could not find home directory: %v", err)})
+	} else {
+		results = append(results, checkShellHook(home), checkTmuxHook(home))
+	}
+	if len(api.Registered()) > 0 {
+		results = append(results, checkSessions(ctx, srv, registered())...)
+	}
+
+	if err := writeChecks(w, results); err != nil {
+		return err
+	}
+	if n := countFailures(results); n > 0 {
+		return fmt.Errorf("%d of %d checks failed", n, len(results))
+	}
+	return nil
+}
+
+func countFailures(results []checkResult) int {
+	var n int
+	for _, r := range results {
+		if r.status == checkFail {
+			n++
+		}
+	}
+	return n
+}
+
+func writeChecks(w io.Writer, results []checkResult) error {
+	var b strings.Builder
+	for _, r := range results {
+		fmt.Fprintf(&b, "[%s] %s: %s\n", r.status, r.name, r.message)
+		if r.hint != "" && r.status != checkPass {
+			fmt.Fprintf(&b, "       %s\n", r.hint)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func checkConfig() checkResult {
+	path, err := config.File()
+	if err != nil {
+		return checkResult{status: checkFail, name: "config", message: err.Error()}
+	}
+	if _, err := loadConfigFile(path); err != nil {
+		return checkResult{status: checkFail, name: "config", message: err.Error(), hint: "Fix the file, and then check it with tmux-vcs-sync config validate."}
+	}
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		return checkResult{status: checkPass, name: "config", message: fmt.Sprintf("%s doesn't exist, so the defaults are used.", path)}
+	}
+	return checkResult{status: checkPass, name: "config", message: fmt.Sprintf("%s is valid.", path)}
+}
+
+func checkPlugins(ctx context.Context) []checkResult {
+	const installHint = "Build and install a VCS library, e.g. by running mage install in this project's git directory."
+	dir, files, err := pluginFiles()
+	if err != nil {
+		return []checkResult{{status: checkFail, name: "plugins", message: err.Error(), hint: installHint}}
+	}
+	if len(files) == 0 {
+		return []checkResult{{status: checkFail, name: "plugins", message: fmt.Sprintf("There are no VCS libraries in %s.", dir), hint: installHint}}
+	}
+	var results []checkResult
+	for _, path := range files {
+		if err := loadPlugin(ctx, path); err != nil {
+			results = append(results, checkResult{status: checkFail, name: "plugins", message: fmt.Sprintf("Could not load %s: %v", path, err), hint: "Rebuild the library with the same version of Go and the same dependencies as tmux-vcs-sync."})
+		} else {
+			results = append(results, checkResult{status: checkPass, name: "plugins", message: fmt.Sprintf("Loaded %s.", path)})
+		}
+	}
+	if len(api.Registered()) == 0 {
+		results = append(results, checkResult{status: checkFail, name: "plugins", message: "No VCS libraries registered a VCS.", hint: installHint})
+	}
+	return results
+}
+
+func checkTmux(ctx context.Context, srv tmux.Server) checkResult {
+	if _, err := exec.LookPath("tmux"); err != nil {
+		return checkResult{status: checkFail, name: "tmux", message: err.Error(), hint: "Install tmux."}
+	}
+	v, err := srv.Version(ctx)
+	if err != nil {
+		return checkResult{status: checkFail, name: "tmux", message: err.Error()}
+	}
+	return checkTmuxVersion(v)
+}
+
+func checkTmuxVersion(v tmux.Version) checkResult {
+	unsupported := v.Unsupported()
+	if len(unsupported) == 0 {
+		return checkResult{status: checkPass, name: "tmux", message: fmt.Sprintf("tmux %s supports every feature.", v)}
+	}
+	var features []string
+	want := v
+	for _, err := range unsupported {
+		features = append(features, err.Feature)
+		if !want.AtLeast(err.Want) {
+			want = err.Want
+		}
+	}
+	return checkResult{
+		status:  checkWarn,
+		name:    "tmux",
+		message: fmt.Sprintf("tmux %s doesn't support %s, so some commands won't work.", v, strings.Join(features, ", ")),
+		hint:    fmt.Sprintf("Upgrade to tmux %s or newer.", want),
+	}
+}
+
+func checkEnvironment(ctx context.Context) checkResult {
+	if os.Getenv("TMUX") == "" {
+		return checkResult{status: checkWarn, name: "$TMUX", message: "Not running in tmux.", hint: "Run doctor in tmux to check that this tool can find the current tmux session."}
+	}
+	env, err := tmux.CurrentEnvironment()
+	if err != nil {
+		return checkResult{status: checkFail, name: "$TMUX", message: err.Error(), hint: "tmux sets $TMUX for every shell it starts. Don't set it yourself."}
+	}
+	if pid, err := tmux.MaybeCurrentServer().PID(ctx); err != nil {
+		return checkResult{status: checkFail, name: "$TMUX", message: fmt.Sprintf("Could not reach the tmux server at %s: %v", env.SocketPath, err), hint: "$TMUX might be stale. Start a new shell in tmux."}
+	} else if pid != env.PID {
+		return checkResult{status: checkWarn, name: "$TMUX", message: fmt.Sprintf("$TMUX says the server's PID is %d, but it's %d.", env.PID, pid), hint: "$TMUX might be stale. Start a new shell in tmux."}
+	}
+	return checkResult{status: checkPass, name: "$TMUX", message: fmt.Sprintf("Running in session %s of the tmux server at %s.", env.SessionID, env.SocketPath)}
+}
+
+// shellFiles are the files, relative to the home directory, that shells run
+// when they start.
+var shellFiles = []string{".bashrc", ".bash_profile", ".profile", ".zshrc", ".config/fish/config.fish"}
+
+// tmuxFiles are tmux's configuration files, relative to the home directory.
+var tmuxFiles = []string{".tmux.conf", ".config/tmux/tmux.conf"}
+
+func checkShellHook(home string) checkResult {
+	if f, ok := findHook(home, shellFiles, "update"); ok {
+		return checkResult{status: checkPass, name: "shell hook", message: fmt.Sprintf("Found update in %s.", f)}
+	}
+	return checkResult{
+		status:  checkWarn,
+		name:    "shell hook",
+		message: fmt.Sprintf("Could not find update in any of %s.", strings.Join(shellFiles, ", ")),
+		hint:    `Run tmux-vcs-sync update before each shell command. See "How do I make it run before terminal commands?" in the README.`,
+	}
+}
+
+func checkTmuxHook(home string) checkResult {
+	if f, ok := findHook(home, tmuxFiles, ""); ok {
+		return checkResult{status: checkPass, name: "tmux hook", message: fmt.Sprintf("Found tmux-vcs-sync in %s.", f)}
+	}
+	return checkResult{
+		status:  checkWarn,
+		name:    "tmux hook",
+		message: fmt.Sprintf("Could not find tmux-vcs-sync in any of %s.", strings.Join(tmuxFiles, ", ")),
+		hint:    `Bind a key to switch sessions, e.g. bind S run-shell "tmux-vcs-sync display-menu".`,
+	}
+}
+
+// findHook looks for a line that runs this tool in any of files, relative to
+// home. If subcommand is set, the line must also run that subcommand.
+func findHook(home string, files []string, subcommand string) (string, bool) {
+	names := []string{"tmux-vcs-sync"}
+	if exe, err := os.Executable(); err == nil && !slices.Contains(names, filepath.Base(exe)) {
+		names = append(names, filepath.Base(exe))
+	}
+	for _, f := range files {
+		b, err := os.ReadFile(filepath.Join(home, f))
+		if err != nil {
+			continue
+		}
+		for _, line := range bytes.Split(b, []byte("\n")) {
+			if subcommand != "" && !bytes.Contains(line, []byte(subcommand)) {
+				continue
+			}
+			for _, n := range names {
+				if bytes.Contains(line, []byte(n)) {
+					return f, true
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+func checkSessions(ctx context.Context, srv tmux.Server, vcs api.VersionControlSystems) []checkResult {
+	st, err := newState(ctx, srv, vcs)
+	if err != nil {
+		return []checkResult{{status: checkFail, name: "sessions", message: err.Error()}}
+	}
+	var results []checkResult
+	if mismatched := st.MismatchedSessions(); len(mismatched) > 0 {
+		results = append(results, checkResult{
+			status:  checkWarn,
+			name:    "sessions",
+			message: fmt.Sprintf("These sessions are in a repository, but their names don't match the naming templates: %s", strings.Join(moremaps.SortedKeys(mismatched), ", ")),
+			hint:    "If the templates changed, rename the sessions with tmux-vcs-sync migrate-names.",
+		})
+	}
+	if stale := st.StaleSessions(ctx); len(stale) > 0 {
+		var names []string
+		for n := range stale {
+			names = append(names, st.SessionName(n))
+		}
+		slices.Sort(names)
+		results = append(results, checkResult{
+			status:  checkWarn,
+			name:    "sessions",
+			message: fmt.Sprintf("These sessions' work units no longer exist: %s", strings.Join(names, ", ")),
+			hint:    "Kill them with tmux-vcs-sync cleanup.",
+		})
+	}
+	if len(results) == 0 {
+		results = append(results, checkResult{status: checkPass, name: "sessions", message: fmt.Sprintf("Found %d sessions for %d repositories.", len(st.Sessions()), len(st.Repositories()))})
+	}
+	return results
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/JeffFaer/tmux-vcs-sync/api"
+	"github.com/JeffFaer/tmux-vcs-sync/api/repotest"
+	"github.com/JeffFaer/tmux-vcs-sync/tmux"
+	"github.com/JeffFaer/tmux-vcs-sync/tmux/tmuxtest"
+)
+
+func TestCheckSessions(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	vcs := api.VersionControlSystems{
+		repotest.NewVCS("testing/", repotest.RepoConfig{
+			Name:      "repo",
+			WorkUnits: map[string][]string{repotest.DefaultWorkUnitName: {"foo"}},
+		}),
+	}
+	// TestDisplayMenu uses small PIDs.
+	srv := tmuxtest.NewServer(1004)
+	if _, err := srv.NewSession(ctx, tmux.NewSessionOptions{Name: "foo", StartDir: "testing/repo"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := checkSessions(ctx, srv, vcs); len(got) != 1 || got[0].status != checkPass {
+		t.Errorf("checkSessions() = %+v, want a single pass", got)
+	}
+
+	if _, err := srv.NewSession(ctx, tmux.NewSessionOptions{Name: "gone", StartDir: "testing/repo"}); err != nil {
+		t.Fatal(err)
+	}
+	got := checkSessions(ctx, srv, vcs)
+	if len(got) != 1 || got[0].status != checkWarn || got[0].hint == "" {
+		t.Errorf("checkSessions() with a stale session = %+v, want a single warning with a hint", got)
+	}
+}
+
+func TestCheckHooks(t *testing.T) {
+	home := t.TempDir()
+	if got := checkShellHook(home); got.status != checkWarn {
+		t.Errorf("checkShellHook() without a hook = %+v, want a warning", got)
+	}
+	if got := checkTmuxHook(home); got.status != checkWarn {
+		t.Errorf("checkTmuxHook() without a hook = %+v, want a warning", got)
+	}
+
+	for f, content := range map[string]string{
+		".bashrc":                "alias tvs=tmux-vcs-sync\nif tmux-vcs-sync update --fail-noop; then\n",
+		".config/tmux/tmux.conf": `bind S run-shell "tmux-vcs-sync display-menu"`,
+	} {
+		path := filepath.Join(home, f)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := checkShellHook(home); got.status != checkPass {
+		t.Errorf("checkShellHook() = %+v, want a pass", got)
+	}
+	if got := checkTmuxHook(home); got.status != checkPass {
+		t.Errorf("checkTmuxHook() = %+v, want a pass", got)
+	}
+}
+
+func TestCheckTmuxVersion(t *testing.T) {
+	if got := checkTmuxVersion(tmuxtest.Version); got.status != checkPass {
+		t.Errorf("checkTmuxVersion(%v) = %+v, want a pass", tmuxtest.Version, got)
+	}
+	v := tmux.Version{Major: 3, Minor: 1}
+	got := checkTmuxVersion(v)
+	if got.status != checkWarn {
+		t.Errorf("checkTmuxVersion(%v) = %+v, want a warning", v, got)
+	}
+	if want := "Upgrade to tmux 3.3 or newer."; got.hint != want {
+		t.Errorf("checkTmuxVersion(%v).hint = %q, want %q", v, got.hint, want)
+	}
+}
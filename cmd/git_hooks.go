@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+
+	"github.com/JeffFaer/tmux-vcs-sync/api"
+	"github.com/JeffFaer/tmux-vcs-sync/tmux"
+	"github.com/JeffFaer/tmux-vcs-sync/tmux/state"
+	"github.com/spf13/cobra"
+)
+
+// checkoutHookCommand is run by the checkout hook. It shouldn't make checkouts
+// fail.
+const checkoutHookCommand = "tmux-vcs-sync checked-out || true"
+
+var gitHooksForce bool
+
+func init() {
+	gitHooksInstallCommand.Flags().BoolVar(&gitHooksForce, "force", false, "Replace an existing hook that wasn't installed by tmux-vcs-sync.")
+	gitHooksCommand.AddCommand(gitHooksInstallCommand)
+	rootCmd.AddCommand(gitHooksCommand)
+	rootCmd.AddCommand(checkedOutCommand)
+}
+
+var gitHooksCommand = &cobra.Command{
+	Use:   "git-hooks",
+	Short: "Manage VCS hooks that keep tmux in sync with checkouts made outside of tmux-vcs-sync.",
+}
+
+var gitHooksInstallCommand = &cobra.Command{
+	Use:   "install",
+	Short: "Install a hook in the current repository that follows checkouts made outside of tmux-vcs-sync.",
+	Long: `Install a hook in the current repository that follows checkouts made outside of tmux-vcs-sync. e.g. a git post-checkout hook.
+
+After you check out a work unit from within tmux, the tmux client is switched to that work unit's session, which is created if it doesn't exist yet. Checkouts made by tmux-vcs-sync itself are ignored.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return installGitHooks(cmd.Context())
+	},
+}
+
+var checkedOutCommand = &cobra.Command{
+	Use:    "checked-out",
+	Hidden: true,
+	Short:  "Switch tmux to the current work unit after it was checked out outside of tmux-vcs-sync.",
+	Args:   cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return checkedOut(cmd.Context())
+	},
+}
+
+func installGitHooks(ctx context.Context) error {
+	repo, err := registered().CurrentRepository(ctx)
+	if err != nil {
+		return err
+	}
+	if err := api.InstallCheckoutHook(ctx, repo, checkoutHookCommand, gitHooksForce); err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return fmt.Errorf("there's already a hook (use --force to replace it): %w", err)
+		}
+		return err
+	}
+	fmt.Printf("Installed checkout hook in %s.\n", repo.RootDir())
+	return nil
+}
+
+func checkedOut(ctx context.Context) error {
+	cur := tmux.MaybeCurrentSession()
+	if cur == nil {
+		slog.Info("Not in tmux, so there's no session to switch.")
+		return nil
+	}
+	vcs := registered()
+	repo, err := vcs.CurrentRepository(ctx)
+	if err != nil {
+		return err
+	}
+	workUnitName, err := repo.Current(ctx)
+	if err != nil {
+		return fmt.Errorf("couldn't check repo's current %s: %w", repo.VCS().WorkUnitName(), err)
+	}
+	st, err := newState(ctx, cur.Server(), vcs)
+	if err != nil {
+		return err
+	}
+	return followCheckout(ctx, st, cur, repo, workUnitName)
+}
+
+// followCheckout switches from the cur tmux session to the session for
+// workUnitName, creating it if necessary.
+func followCheckout(ctx context.Context, st *state.State, cur tmux.Session, repo api.Repository, workUnitName string) error {
+	sesh := st.Session(repo, workUnitName)
+	if sesh != nil && tmux.SameSession(ctx, cur, sesh) {
+		slog.Info("Already in the tmux session for the work unit.")
+		return nil
+	}
+	if sesh == nil {
+		var err error
+		sesh, err = st.NewSession(ctx, repo, workUnitName)
+		if err != nil {
+			return err
+		}
+	}
+	return st.Server().AttachOrSwitch(ctx, sesh)
+}
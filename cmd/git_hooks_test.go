@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/JeffFaer/tmux-vcs-sync/api"
+	"github.com/JeffFaer/tmux-vcs-sync/api/repotest"
+	"github.com/JeffFaer/tmux-vcs-sync/tmux"
+	"github.com/JeffFaer/tmux-vcs-sync/tmux/state"
+	"github.com/JeffFaer/tmux-vcs-sync/tmux/tmuxtest"
+)
+
+func TestFollowCheckout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	vcs := api.VersionControlSystems{
+		repotest.NewVCS("testing/", repotest.RepoConfig{
+			Name:      "repo",
+			WorkUnits: map[string][]string{repotest.DefaultWorkUnitName: {"foo", "bar"}},
+		}),
+	}
+	// TestDisplayMenu uses small PIDs.
+	srv := tmuxtest.NewServer(1015)
+	foo, err := srv.NewSession(ctx, tmux.NewSessionOptions{Name: "foo", StartDir: "testing/repo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, err := state.New(ctx, srv, vcs)
+	if err != nil {
+		t.Fatalf("state.New() = _, %v", err)
+	}
+	repo, err := vcs.MaybeFindRepository(ctx, "testing/repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv.CurrentSession = nil
+	if err := followCheckout(ctx, st, foo, repo, "foo"); err != nil {
+		t.Errorf("followCheckout(foo) = %v", err)
+	}
+	if srv.CurrentSession != nil {
+		t.Errorf("followCheckout(foo) switched sessions, want no switch since foo is already the current session")
+	}
+
+	if err := followCheckout(ctx, st, foo, repo, "bar"); err != nil {
+		t.Fatalf("followCheckout(bar) = %v", err)
+	}
+	bar := st.Session(repo, "bar")
+	if bar == nil {
+		t.Fatalf("st.Session(bar) = nil, want a new session")
+	}
+	if srv.CurrentSession == nil || srv.CurrentSession.ID() != bar.ID() {
+		t.Errorf("Current session = %v, want %v", srv.CurrentSession, bar)
+	}
+
+	if err := followCheckout(ctx, st, bar, repo, "foo"); err != nil {
+		t.Fatalf("followCheckout(foo) = %v", err)
+	}
+	if srv.CurrentSession == nil || srv.CurrentSession.ID() != foo.ID() {
+		t.Errorf("Current session = %v, want %v", srv.CurrentSession, foo)
+	}
+}
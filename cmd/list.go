@@ -0,0 +1,413 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/JeffFaer/go-stdlib-ext/morecmp"
+	"github.com/JeffFaer/go-stdlib-ext/moremaps"
+	"github.com/JeffFaer/tmux-vcs-sync/api"
+	"github.com/JeffFaer/tmux-vcs-sync/tmux"
+	"github.com/JeffFaer/tmux-vcs-sync/tmux/state"
+	"github.com/spf13/cobra"
+)
+
+// listSorts are the orders that list can sort work units in.
+var listSorts = []string{"topology", "name", "activity"}
+
+var (
+	listJSON bool
+	listRepo string
+	listSort string
+)
+
+func init() {
+	listCommand.Flags().BoolVar(&listJSON, "json", false, "Print the list as JSON.")
+	listCommand.Flags().StringVar(&listRepo, "repo", "", "Only list the repository with this name, label, or root directory.")
+	listCommand.Flags().StringVar(&listSort, "sort", "topology", "The order to list work units in: "+strings.Join(listSorts, ", ")+".")
+	rootCmd.AddCommand(listCommand)
+}
+
+var listCommand = &cobra.Command{
+	Use:   "list",
+	Short: "List repositories, their work units, and their tmux sessions.",
+	Long: `List every repository that has a tmux session, along with the repository in the current directory. Each repository's work units are listed along with their tmux sessions, if any.
+
+Sessions whose work units no longer exist are marked with ?, and sessions that don't belong to any repository are listed under "other". The current session is marked with *.
+
+The sessions of the other tmux servers in tmux.servers are listed after the rest. With --repo, other servers without a matching repository are left out.
+
+Sort orders:
+  topology: Ancestors before their descendants.
+  name:     Alphabetically.
+  activity: Most recently active sessions first, and then work units without sessions alphabetically.`,
+	Args: cobra.ExactArgs(0),
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		if !slices.Contains(listSorts, listSort) {
+			return fmt.Errorf("unknown sort order %q", listSort)
+		}
+		ctx := cmd.Context()
+		vcs := registered()
+		st, _, err := currentState(ctx, vcs)
+		if err != nil {
+			return err
+		}
+		repos := st.Repositories()
+		addCurrentRepository(ctx, vcs, repos)
+		opts := listOptions{repo: listRepo, sort: listSort}
+		l, err := listAll(ctx, st, repos, currentSession(ctx, st.Server()), opts)
+		if err != nil {
+			return err
+		}
+		l.others = listOthers(ctx, otherServers(ctx, st.Server()), vcs, opts)
+		if listJSON {
+			return writeJSONList(os.Stdout, l)
+		}
+		return writeList(os.Stdout, l)
+	},
+}
+
+type listOptions struct {
+	// Only list the repository with this name, label, or root directory.
+	repo string
+	// One of listSorts.
+	sort string
+}
+
+type listing struct {
+	// server is the socket of the tmux server that the sessions belong to. It's
+	// only set for the servers in tmux.servers.
+	server string
+	repos  []listedRepo
+	// unknown are the sessions that don't belong to any repository.
+	unknown []listedSession
+	// others are the listings of the other tmux servers in tmux.servers.
+	others []listing
+}
+
+type listedRepo struct {
+	name      state.RepoName
+	label     string
+	workUnits []listedWorkUnit
+	// orphaned are the sessions whose work units no longer exist.
+	orphaned []listedSession
+}
+
+type listedWorkUnit struct {
+	name string
+	// session is nil if the work unit doesn't have a session.
+	session *listedSession
+}
+
+type listedSession struct {
+	id, name string
+	workUnit string
+	current  bool
+	activity time.Time
+}
+
+// listAll lists the work units and sessions of repos.
+func listAll(ctx context.Context, st *state.State, repos map[state.RepoName]api.Repository, curSesh tmux.Session, opts listOptions) (listing, error) {
+	activity, err := sessionActivity(ctx, st.Server())
+	if err != nil {
+		return listing{}, err
+	}
+	newSession := func(name string, sesh tmux.Session, wu string) *listedSession {
+		return &listedSession{
+			id:       sesh.ID(),
+			name:     name,
+			workUnit: wu,
+			current:  curSesh != nil && sesh.ID() == curSesh.ID(),
+			activity: activity[sesh.ID()],
+		}
+	}
+
+	sessions := make(map[state.RepoName]map[string]tmux.Session)
+	for n, sesh := range st.Sessions() {
+		if sessions[n.RepoName] == nil {
+			sessions[n.RepoName] = make(map[string]tmux.Session)
+		}
+		sessions[n.RepoName][n.WorkUnit] = sesh
+	}
+
+	var l listing
+	for _, n := range moremaps.SortedKeysFunc(repos, repoNameCmp) {
+		repo := repos[n]
+		label := st.RepoLabel(n)
+		if opts.repo != "" && !matchesRepo(opts.repo, n, label) {
+			continue
+		}
+		workUnits, err := repo.List(ctx, "")
+		if err != nil {
+			return listing{}, fmt.Errorf("could not list %s %ss: %w", n.Repo, repo.VCS().WorkUnitName(), err)
+		}
+		sortWorkUnits(ctx, repo, workUnits, opts.sort)
+
+		lr := listedRepo{name: n, label: label}
+		exists := make(map[string]bool)
+		for _, wu := range workUnits {
+			exists[wu] = true
+			lwu := listedWorkUnit{name: wu}
+			if sesh := sessions[n][wu]; sesh != nil {
+				lwu.session = newSession(st.SessionName(state.NewWorkUnitName(repo, wu)), sesh, wu)
+			}
+			lr.workUnits = append(lr.workUnits, lwu)
+		}
+		for _, wu := range moremaps.SortedKeys(sessions[n]) {
+			if !exists[wu] {
+				lr.orphaned = append(lr.orphaned, *newSession(st.SessionName(state.NewWorkUnitName(repo, wu)), sessions[n][wu], wu))
+			}
+		}
+		if opts.sort == "activity" {
+			slices.SortStableFunc(lr.workUnits, morecmp.ComparingFunc(func(wu listedWorkUnit) *listedSession { return wu.session }, byActivity))
+			slices.SortStableFunc(lr.orphaned, sessionsByActivity)
+		}
+		l.repos = append(l.repos, lr)
+	}
+	if opts.repo != "" {
+		if len(l.repos) == 0 {
+			return listing{}, fmt.Errorf("no repository matches %q", opts.repo)
+		}
+		return l, nil
+	}
+
+	unknown := st.UnknownSessions()
+	for _, name := range moremaps.SortedKeys(unknown) {
+		l.unknown = append(l.unknown, *newSession(name, unknown[name], ""))
+	}
+	if opts.sort == "activity" {
+		slices.SortStableFunc(l.unknown, sessionsByActivity)
+	}
+	return l, nil
+}
+
+// listOthers lists the work units and sessions of each of the other tmux
+// servers. Servers without a repository that matches opts.repo are left out.
+func listOthers(ctx context.Context, others []otherServer, vcs api.VersionControlSystems, opts listOptions) []listing {
+	var ret []listing
+	for _, o := range others {
+		st, err := newState(ctx, o.srv, vcs)
+		if err != nil {
+			slog.Warn("Skipping tmux server.", "server", o.socket, "error", err)
+			continue
+		}
+		repos := st.Repositories()
+		matched := opts.repo == ""
+		for n := range repos {
+			matched = matched || matchesRepo(opts.repo, n, st.RepoLabel(n))
+		}
+		if !matched {
+			continue
+		}
+		// The current session belongs to the main server.
+		l, err := listAll(ctx, st, repos, nil, opts)
+		if err != nil {
+			slog.Warn("Skipping tmux server.", "server", o.socket, "error", err)
+			continue
+		}
+		l.server = o.socket
+		ret = append(ret, l)
+	}
+	return ret
+}
+
+// byActivity orders sessions with the most recent activity first, and then
+// work units without sessions.
+func byActivity(a, b *listedSession) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	}
+	return b.activity.Compare(a.activity)
+}
+
+func sessionsByActivity(a, b listedSession) int {
+	return byActivity(&a, &b)
+}
+
+func matchesRepo(query string, n state.RepoName, label string) bool {
+	if query == n.Repo || query == label {
+		return true
+	}
+	abs, err := filepath.Abs(query)
+	return err == nil && abs == filepath.Clean(n.Dir)
+}
+
+// sortWorkUnits sorts workUnits alphabetically, and then topologically if
+// requested.
+func sortWorkUnits(ctx context.Context, repo api.Repository, workUnits []string, order string) {
+	slices.Sort(workUnits)
+	if order != "topology" {
+		return
+	}
+	// Sort might leave its argument in a partially sorted state if it fails.
+	sorted := slices.Clone(workUnits)
+	if err := repo.Sort(ctx, sorted); err != nil {
+		slog.Warn("Could not sort work units.", "repo", state.NewRepoName(repo), "error", err)
+		return
+	}
+	copy(workUnits, sorted)
+}
+
+// sessionActivity determines when each session in srv was last active, keyed
+// by session ID.
+func sessionActivity(ctx context.Context, srv tmux.Server) (map[string]time.Time, error) {
+	sessions, err := srv.ListSessions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ret := make(map[string]time.Time)
+	if len(sessions.Sessions()) == 0 {
+		return ret, nil
+	}
+	props, err := sessions.Property(ctx, tmux.SessionActivity)
+	if err != nil {
+		return nil, err
+	}
+	for sesh, prop := range props {
+		v := tmux.PropertyValue(tmux.SessionActivity, prop)
+		sec, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			slog.Warn("Could not parse session activity.", "session", sesh.ID(), "activity", v, "error", err)
+			continue
+		}
+		ret[sesh.ID()] = time.Unix(sec, 0)
+	}
+	return ret, nil
+}
+
+func (sesh listedSession) marker() string {
+	if sesh.current {
+		return "*"
+	}
+	return " "
+}
+
+func writeList(w io.Writer, l listing) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	writeListing(tw, l)
+	empty := len(l.repos) == 0 && len(l.unknown) == 0
+	for _, other := range l.others {
+		if !empty {
+			fmt.Fprintln(tw)
+		}
+		fmt.Fprintf(tw, "== tmux server %s ==\n", other.server)
+		writeListing(tw, other)
+		empty = false
+	}
+	return tw.Flush()
+}
+
+func writeListing(tw io.Writer, l listing) {
+	for i, repo := range l.repos {
+		if i > 0 {
+			fmt.Fprintln(tw)
+		}
+		fmt.Fprintf(tw, "%s (%s, %s)\n", repo.label, repo.name.VCS, repo.name.Dir)
+		for _, wu := range repo.workUnits {
+			if wu.session == nil {
+				fmt.Fprintf(tw, "   %s\t-\n", wu.name)
+			} else {
+				fmt.Fprintf(tw, " %s %s\t%s (%s)\n", wu.session.marker(), wu.name, wu.session.name, wu.session.id)
+			}
+		}
+		for _, sesh := range repo.orphaned {
+			fmt.Fprintf(tw, " %s %s\t%s (%s) ?\n", sesh.marker(), sesh.workUnit, sesh.name, sesh.id)
+		}
+	}
+	if len(l.unknown) > 0 {
+		if len(l.repos) > 0 {
+			fmt.Fprintln(tw)
+		}
+		fmt.Fprintln(tw, "other")
+		for _, sesh := range l.unknown {
+			fmt.Fprintf(tw, " %s -\t%s (%s)\n", sesh.marker(), sesh.name, sesh.id)
+		}
+	}
+}
+
+type jsonList struct {
+	Server          string            `json:"server,omitempty"`
+	Repos           []jsonListRepo    `json:"repos"`
+	UnknownSessions []jsonListSession `json:"unknown_sessions"`
+	OtherServers    []jsonList        `json:"other_servers,omitempty"`
+}
+
+type jsonListRepo struct {
+	VCS              string             `json:"vcs"`
+	Repo             string             `json:"repo"`
+	RepoDir          string             `json:"repo_dir"`
+	Label            string             `json:"label"`
+	WorkUnits        []jsonListWorkUnit `json:"work_units"`
+	OrphanedSessions []jsonListSession  `json:"orphaned_sessions"`
+}
+
+type jsonListWorkUnit struct {
+	WorkUnit string           `json:"work_unit"`
+	Session  *jsonListSession `json:"session,omitempty"`
+}
+
+type jsonListSession struct {
+	ID       string    `json:"id"`
+	Name     string    `json:"name"`
+	WorkUnit string    `json:"work_unit,omitempty"`
+	Current  bool      `json:"current"`
+	Activity time.Time `json:"activity"`
+}
+
+func newJSONListSession(sesh listedSession) jsonListSession {
+	return jsonListSession{ID: sesh.id, Name: sesh.name, WorkUnit: sesh.workUnit, Current: sesh.current, Activity: sesh.activity}
+}
+
+func writeJSONList(w io.Writer, l listing) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(newJSONList(l))
+}
+
+func newJSONList(l listing) jsonList {
+	out := jsonList{Server: l.server, Repos: make([]jsonListRepo, 0, len(l.repos)), UnknownSessions: make([]jsonListSession, 0, len(l.unknown))}
+	for _, repo := range l.repos {
+		jr := jsonListRepo{
+			VCS:              repo.name.VCS,
+			Repo:             repo.name.Repo,
+			RepoDir:          repo.name.Dir,
+			Label:            repo.label,
+			WorkUnits:        make([]jsonListWorkUnit, 0, len(repo.workUnits)),
+			OrphanedSessions: make([]jsonListSession, 0, len(repo.orphaned)),
+		}
+		for _, wu := range repo.workUnits {
+			jwu := jsonListWorkUnit{WorkUnit: wu.name}
+			if wu.session != nil {
+				s := newJSONListSession(*wu.session)
+				jwu.Session = &s
+			}
+			jr.WorkUnits = append(jr.WorkUnits, jwu)
+		}
+		for _, sesh := range repo.orphaned {
+			jr.OrphanedSessions = append(jr.OrphanedSessions, newJSONListSession(sesh))
+		}
+		out.Repos = append(out.Repos, jr)
+	}
+	for _, sesh := range l.unknown {
+		out.UnknownSessions = append(out.UnknownSessions, newJSONListSession(sesh))
+	}
+	for _, other := range l.others {
+		out.OtherServers = append(out.OtherServers, newJSONList(other))
+	}
+	return out
+}
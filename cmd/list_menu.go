@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/JeffFaer/tmux-vcs-sync/tmux"
+	"github.com/spf13/cobra"
+)
+
+// menuFormats are the formats that list-menu can print the menu in.
+var menuFormats = map[string]func(io.Writer, []sessionGroup) error{
+	"tmux": writeTmuxMenu,
+	"fzf":  writeFzfMenu,
+	"json": writeJSONMenu,
+}
+
+var listMenuFormat string
+
+func init() {
+	listMenuCommand.Flags().StringVar(&listMenuFormat, "format", "tmux", "The format to print the menu in: tmux, fzf, or json.")
+	rootCmd.AddCommand(listMenuCommand)
+}
+
+var listMenuCommand = &cobra.Command{
+	Use:   "list-menu",
+	Short: "Print the sessions that display-menu would display.",
+	Long: `Print the sessions that display-menu would display, with the same grouping, ordering, and markers, so that they can be displayed by other tools.
+
+Formats:
+  tmux: A display-menu command that can be run with tmux source-file.
+  fzf:  One session per line: its ID, a tab, and then its name as display-menu would display it.
+        e.g. tmux-vcs-sync update --id "$(tmux-vcs-sync list-menu --format=fzf | fzf --delimiter='\t' --with-nth=2.. | cut -f1)"
+  json: An array of groups, each of which contains an array of sessions.
+
+The ID of each session can be given to update --id. Unlike display-menu, the sessions of the other tmux servers in tmux.servers aren't included, since their IDs would be ambiguous.`,
+	Args: cobra.ExactArgs(0),
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		return listMenu(cmd.Context(), os.Stdout, listMenuFormat)
+	},
+}
+
+func listMenu(ctx context.Context, w io.Writer, format string) error {
+	write, ok := menuFormats[format]
+	if !ok {
+		return fmt.Errorf("unknown menu format %q", format)
+	}
+	// list-menu might be used outside of tmux, in which case there's no current
+	// session.
+	srv, _ := selectedServer(ctx, tmux.ControlMode())
+	curSesh := currentSession(ctx, srv)
+	groups, err := sessionGroups(ctx, srv, curSesh, registered())
+	if err := srv.Close(); err != nil {
+		slog.Warn("Could not close tmux connection.", "error", err)
+	}
+	if err != nil {
+		return err
+	}
+	return write(w, groups)
+}
+
+func writeTmuxMenu(w io.Writer, groups []sessionGroup) error {
+	menu, err := tmuxMenu(groups, menuOptions{})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, tmux.FormatCommand(append([]string{"display-menu", "--"}, tmux.MenuArgs(menu)...)...))
+	return err
+}
+
+func writeFzfMenu(w io.Writer, groups []sessionGroup) error {
+	var b strings.Builder
+	for _, g := range groups {
+		for _, sesh := range g.sessions {
+			fmt.Fprintf(&b, "%s\t%s%s\n", sesh.id, sesh.marker(), sesh.displayName())
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+type jsonMenuGroup struct {
+	ID       string            `json:"id"`
+	Title    string            `json:"title"`
+	Sessions []jsonMenuSession `json:"sessions"`
+}
+
+type jsonMenuSession struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	Current       bool   `json:"current"`
+	UnknownToRepo bool   `json:"unknown_to_repo"`
+
+	// These are only set if the session belongs to a repository.
+	VCS      string              `json:"vcs,omitempty"`
+	Repo     string              `json:"repo,omitempty"`
+	RepoDir  string              `json:"repo_dir,omitempty"`
+	WorkUnit string              `json:"work_unit,omitempty"`
+	Depth    int                 `json:"depth"`
+	Status   *jsonWorkUnitStatus `json:"status,omitempty"`
+}
+
+type jsonWorkUnitStatus struct {
+	Dirty  bool `json:"dirty"`
+	Ahead  int  `json:"ahead"`
+	Behind int  `json:"behind"`
+}
+
+func writeJSONMenu(w io.Writer, groups []sessionGroup) error {
+	out := make([]jsonMenuGroup, 0, len(groups))
+	for _, g := range groups {
+		jg := jsonMenuGroup{ID: g.id, Title: g.title, Sessions: make([]jsonMenuSession, 0, len(g.sessions))}
+		for _, sesh := range g.sessions {
+			js := jsonMenuSession{
+				ID:            sesh.id,
+				Name:          sesh.name,
+				Current:       sesh.current,
+				UnknownToRepo: sesh.unknownToRepo,
+				WorkUnit:      sesh.workUnit,
+				Depth:         sesh.depth,
+			}
+			if sesh.repo != nil {
+				js.VCS = sesh.repo.VCS().Name()
+				js.Repo = sesh.repo.Name()
+				js.RepoDir = sesh.repo.RootDir()
+				if !sesh.unknownToRepo {
+					js.Status = &jsonWorkUnitStatus{Dirty: sesh.status.Dirty, Ahead: sesh.status.Ahead, Behind: sesh.status.Behind}
+				}
+			}
+			jg.Sessions = append(jg.Sessions, js)
+		}
+		out = append(out, jg)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
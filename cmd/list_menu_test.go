@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JeffFaer/tmux-vcs-sync/api"
+	"github.com/JeffFaer/tmux-vcs-sync/api/repotest"
+	"github.com/JeffFaer/tmux-vcs-sync/tmux"
+	"github.com/JeffFaer/tmux-vcs-sync/tmux/tmuxtest"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestListMenu(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	vcs := api.VersionControlSystems{
+		repotest.NewVCS("testing/", repotest.RepoConfig{
+			Name:      "repo",
+			WorkUnits: map[string][]string{repotest.DefaultWorkUnitName: {"foo"}},
+			Status:    map[string]api.WorkUnitStatus{"foo": {Dirty: true, Ahead: 1}},
+		}),
+	}
+	// TestDisplayMenu uses small PIDs.
+	srv := tmuxtest.NewServer(1001)
+	var ids []string
+	for _, opts := range []tmux.NewSessionOptions{
+		{Name: repotest.DefaultWorkUnitName, StartDir: "testing/repo"},
+		{Name: "foo", StartDir: "testing/repo"},
+		{Name: "bar", StartDir: "someOtherDir"},
+	} {
+		sesh, err := srv.NewSession(ctx, opts)
+		if err != nil {
+			t.Fatalf("tmux.NewSession(%#v) = _, %v", opts, err)
+		}
+		ids = append(ids, sesh.ID())
+	}
+	current, err := srv.NewSession(ctx, tmux.NewSessionOptions{Name: "baz", StartDir: "someOtherDir"})
+	if err != nil {
+		t.Fatalf("tmux.NewSession() = _, %v", err)
+	}
+	groups, err := sessionGroups(ctx, srv, current, vcs)
+	if err != nil {
+		t.Fatalf("sessionGroups() = _, %v", err)
+	}
+
+	t.Run("fzf", func(t *testing.T) {
+		var b strings.Builder
+		if err := writeFzfMenu(&b, groups); err != nil {
+			t.Fatalf("writeFzfMenu() = %v", err)
+		}
+		want := strings.Join([]string{
+			ids[2] + "\t bar",
+			current.ID() + "\t*baz",
+			ids[0] + "\t " + repotest.DefaultWorkUnitName,
+			ids[1] + "\t └─ foo ↑1 !",
+		}, "\n") + "\n"
+		if diff := cmp.Diff(want, b.String()); diff != "" {
+			t.Errorf("writeFzfMenu() diff (-want +got)\n%s", diff)
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		var b strings.Builder
+		if err := writeJSONMenu(&b, groups); err != nil {
+			t.Fatalf("writeJSONMenu() = %v", err)
+		}
+		var got []jsonMenuGroup
+		if err := json.Unmarshal([]byte(b.String()), &got); err != nil {
+			t.Fatalf("json.Unmarshal(%q) = %v", b.String(), err)
+		}
+		want := []jsonMenuGroup{
+			{
+				ID:    unknownGroup,
+				Title: "other",
+				Sessions: []jsonMenuSession{
+					{ID: ids[2], Name: "bar"},
+					{ID: current.ID(), Name: "baz", Current: true},
+				},
+			},
+			{
+				ID:    "fake(testing/):repo:testing/repo",
+				Title: "repo",
+				Sessions: []jsonMenuSession{
+					{ID: ids[0], Name: repotest.DefaultWorkUnitName, VCS: "fake(testing/)", Repo: "repo", RepoDir: "testing/repo", WorkUnit: repotest.DefaultWorkUnitName, Status: &jsonWorkUnitStatus{}},
+					{ID: ids[1], Name: "foo", VCS: "fake(testing/)", Repo: "repo", RepoDir: "testing/repo", WorkUnit: "foo", Depth: 1, Status: &jsonWorkUnitStatus{Dirty: true, Ahead: 1}},
+				},
+			},
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("writeJSONMenu() diff (-want +got)\n%s", diff)
+		}
+	})
+
+	t.Run("tmux", func(t *testing.T) {
+		var b strings.Builder
+		if err := writeTmuxMenu(&b, groups); err != nil {
+			t.Fatalf("writeTmuxMenu() = %v", err)
+		}
+		if got := b.String(); !strings.HasPrefix(got, `"display-menu" "--" " bar" "0" `) {
+			t.Errorf("writeTmuxMenu() = %q, want a display-menu command", got)
+		}
+	})
+}
@@ -0,0 +1,209 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JeffFaer/tmux-vcs-sync/api"
+	"github.com/JeffFaer/tmux-vcs-sync/api/repotest"
+	"github.com/JeffFaer/tmux-vcs-sync/tmux"
+	"github.com/JeffFaer/tmux-vcs-sync/tmux/state"
+	"github.com/JeffFaer/tmux-vcs-sync/tmux/tmuxtest"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestListAll(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	vcs := api.VersionControlSystems{
+		repotest.NewVCS("testing/", repotest.RepoConfig{
+			Name: "repo",
+			WorkUnits: map[string][]string{
+				repotest.DefaultWorkUnitName: {"b", "c"},
+				"b":                          {"a"},
+			},
+		}),
+	}
+	// TestDisplayMenu uses small PIDs.
+	srv := tmuxtest.NewServer(1003)
+	sessions := make(map[string]tmux.Session)
+	for _, opts := range []tmux.NewSessionOptions{
+		{Name: "c", StartDir: "testing/repo"},
+		{Name: "a", StartDir: "testing/repo"},
+		{Name: "gone", StartDir: "testing/repo"},
+		{Name: "other", StartDir: "someOtherDir"},
+	} {
+		sesh, err := srv.NewSession(ctx, opts)
+		if err != nil {
+			t.Fatalf("tmux.NewSession(%#v) = _, %v", opts, err)
+		}
+		sessions[opts.Name] = sesh
+	}
+	if err := srv.AttachOrSwitch(ctx, sessions["c"]); err != nil {
+		t.Fatal(err)
+	}
+	st, err := state.New(ctx, srv, vcs)
+	if err != nil {
+		t.Fatalf("state.New() = _, %v", err)
+	}
+	repos := st.Repositories()
+
+	for _, tc := range []struct {
+		opts listOptions
+		want []string
+	}{
+		{
+			opts: listOptions{sort: "topology"},
+			want: []string{
+				"repo (fake(testing/), testing/repo)",
+				"   " + repotest.DefaultWorkUnitName + "  -",
+				"   b     -",
+				"   a     a (1003#1)",
+				" * c     c (1003#0)",
+				"   gone  gone (1003#2) ?",
+				"",
+				"other",
+				"   -  other (1003#3)",
+			},
+		},
+		{
+			opts: listOptions{sort: "name"},
+			want: []string{
+				"repo (fake(testing/), testing/repo)",
+				"   a     a (1003#1)",
+				"   b     -",
+				" * c     c (1003#0)",
+				"   " + repotest.DefaultWorkUnitName + "  -",
+				"   gone  gone (1003#2) ?",
+				"",
+				"other",
+				"   -  other (1003#3)",
+			},
+		},
+		{
+			opts: listOptions{sort: "activity", repo: "repo"},
+			want: []string{
+				"repo (fake(testing/), testing/repo)",
+				" * c     c (1003#0)",
+				"   a     a (1003#1)",
+				"   b     -",
+				"   " + repotest.DefaultWorkUnitName + "  -",
+				"   gone  gone (1003#2) ?",
+			},
+		},
+	} {
+		t.Run(tc.opts.sort, func(t *testing.T) {
+			l, err := listAll(ctx, st, repos, sessions["c"], tc.opts)
+			if err != nil {
+				t.Fatalf("listAll(%+v) = _, %v", tc.opts, err)
+			}
+			var b strings.Builder
+			if err := writeList(&b, l); err != nil {
+				t.Fatalf("writeList() = %v", err)
+			}
+			want := strings.Join(tc.want, "\n") + "\n"
+			if diff := cmp.Diff(want, b.String()); diff != "" {
+				t.Errorf("writeList() diff (-want +got)\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("json", func(t *testing.T) {
+		l, err := listAll(ctx, st, repos, sessions["c"], listOptions{sort: "name", repo: "repo"})
+		if err != nil {
+			t.Fatalf("listAll() = _, %v", err)
+		}
+		var b strings.Builder
+		if err := writeJSONList(&b, l); err != nil {
+			t.Fatalf("writeJSONList() = %v", err)
+		}
+		var got jsonList
+		if err := json.Unmarshal([]byte(b.String()), &got); err != nil {
+			t.Fatalf("json.Unmarshal(%q) = %v", b.String(), err)
+		}
+		session := func(name string, activity int64, current bool) *jsonListSession {
+			return &jsonListSession{ID: sessions[name].ID(), Name: name, WorkUnit: name, Current: current, Activity: time.Unix(activity, 0)}
+		}
+		want := jsonList{
+			Repos: []jsonListRepo{{
+				VCS:     "fake(testing/)",
+				Repo:    "repo",
+				RepoDir: "testing/repo",
+				Label:   "repo",
+				WorkUnits: []jsonListWorkUnit{
+					{WorkUnit: "a", Session: session("a", 2, false)},
+					{WorkUnit: "b"},
+					{WorkUnit: "c", Session: session("c", 5, true)},
+					{WorkUnit: repotest.DefaultWorkUnitName},
+				},
+				OrphanedSessions: []jsonListSession{*session("gone", 3, false)},
+			}},
+			UnknownSessions: []jsonListSession{},
+		}
+		if diff := cmp.Diff(want, got, cmpopts.EquateApproxTime(0)); diff != "" {
+			t.Errorf("writeJSONList() diff (-want +got)\n%s", diff)
+		}
+	})
+
+	t.Run("unknown repo", func(t *testing.T) {
+		if l, err := listAll(ctx, st, repos, nil, listOptions{sort: "name", repo: "nope"}); err == nil {
+			t.Errorf("listAll() = %+v, nil, want an error", l)
+		}
+	})
+}
+
+func TestListOthers(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	vcs := api.VersionControlSystems{
+		repotest.NewVCS("testing/", repotest.RepoConfig{
+			Name:      "repo",
+			WorkUnits: map[string][]string{repotest.DefaultWorkUnitName: {"foo"}},
+		}),
+	}
+	srv := tmuxtest.NewServer(1019)
+	sessions := make(map[string]tmux.Session)
+	for _, opts := range []tmux.NewSessionOptions{
+		{Name: "foo", StartDir: "testing/repo"},
+		{Name: "bar", StartDir: "someOtherDir"},
+	} {
+		sesh, err := srv.NewSession(ctx, opts)
+		if err != nil {
+			t.Fatalf("tmux.NewSession(%#v) = _, %v", opts, err)
+		}
+		sessions[opts.Name] = sesh
+	}
+	// Servers that fail are skipped.
+	others := []otherServer{{"broken", brokenServer{srv}}, {"personal", srv}}
+
+	t.Run("all", func(t *testing.T) {
+		var l listing
+		l.others = listOthers(ctx, others, vcs, listOptions{sort: "name"})
+		var b strings.Builder
+		if err := writeList(&b, l); err != nil {
+			t.Fatalf("writeList() = %v", err)
+		}
+		want := strings.Join([]string{
+			"== tmux server personal ==",
+			"repo (fake(testing/), testing/repo)",
+			"   foo   foo (" + sessions["foo"].ID() + ")",
+			"   " + repotest.DefaultWorkUnitName + "  -",
+			"",
+			"other",
+			"   -  bar (" + sessions["bar"].ID() + ")",
+		}, "\n") + "\n"
+		if diff := cmp.Diff(want, b.String()); diff != "" {
+			t.Errorf("writeList() diff (-want +got)\n%s", diff)
+		}
+	})
+
+	t.Run("unknown repo", func(t *testing.T) {
+		if got := listOthers(ctx, others, vcs, listOptions{sort: "name", repo: "nope"}); len(got) != 0 {
+			t.Errorf("listOthers() = %+v, want no listings", got)
+		}
+	})
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/JeffFaer/tmux-vcs-sync/api"
+	"github.com/JeffFaer/tmux-vcs-sync/api/config"
+	"github.com/JeffFaer/tmux-vcs-sync/lock"
+	"github.com/JeffFaer/tmux-vcs-sync/tmux"
+)
+
+// locks are the locks held by this command, so that several tmux-vcs-sync
+// processes don't change the same repository or tmux server at once. e.g. when
+// preexec hooks run update in several panes at the same time.
+// A tmux server is always locked before any repositories, and repositories are
+// locked at most once per command, which prevents deadlocks.
+var locks *lock.Locks
+
+// lockServer waits for other tmux-vcs-sync processes to finish changing srv.
+// The lock is keyed on srv's socket, which, unlike its PID, is the same before
+// and after the server starts.
+func lockServer(ctx context.Context, srv tmux.Server) error {
+	return acquireLocks(ctx, "server:"+srv.SocketPath())
+}
+
+// lockRepos waits for other tmux-vcs-sync processes to finish changing repos.
+func lockRepos(ctx context.Context, repos ...api.Repository) error {
+	var keys []string
+	for _, repo := range repos {
+		keys = append(keys, "repo:"+repo.RootDir())
+	}
+	return acquireLocks(ctx, keys...)
+}
+
+func acquireLocks(ctx context.Context, keys ...string) error {
+	if locks == nil {
+		dir, err := config.LockDir()
+		if err != nil {
+			return err
+		}
+		locks = lock.New(dir, cfg.Lock.Timeout)
+	}
+	return locks.Acquire(ctx, keys...)
+}
+
+// unlock releases the locks held by this command. Commands that attach to tmux
+// need to do so before they attach, since attaching lasts until the client
+// detaches.
+func unlock() error {
+	if locks == nil {
+		return nil
+	}
+	return locks.Release()
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/JeffFaer/tmux-vcs-sync/api"
+	"github.com/JeffFaer/tmux-vcs-sync/api/repotest"
+	"github.com/JeffFaer/tmux-vcs-sync/lock"
+	"github.com/JeffFaer/tmux-vcs-sync/tmux/state"
+	"github.com/JeffFaer/tmux-vcs-sync/tmux/tmuxtest"
+)
+
+func TestUpdateToWorkUnit_Locked(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	vcs := api.VersionControlSystems{
+		repotest.NewVCS("testing/", repotest.RepoConfig{
+			Name:      "repo",
+			WorkUnits: map[string][]string{repotest.DefaultWorkUnitName: {"foo", "bar"}},
+		}),
+	}
+	// TestDisplayMenu uses small PIDs.
+	srv := tmuxtest.NewServer(1020)
+	st, err := state.New(ctx, srv, vcs)
+	if err != nil {
+		t.Fatalf("state.New() = _, %v", err)
+	}
+	repo, err := vcs.MaybeFindRepository(ctx, "testing/repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	locks = lock.New(dir, 50*time.Millisecond)
+	defer func() { locks = nil }()
+	// other is another tmux-vcs-sync process that's changing the same
+	// repository.
+	other := lock.New(dir, time.Second)
+	if err := other.Acquire(ctx, "repo:"+repo.RootDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := updateToWorkUnit(ctx, st, true, repo, "foo"); !errors.Is(err, lock.ErrBusy) {
+		t.Errorf("updateToWorkUnit(foo) while locked = %v, want %v", err, lock.ErrBusy)
+	}
+	if cur, err := repo.Current(ctx); err != nil || cur == "foo" {
+		t.Errorf("repo.Current() = %q, %v, want the repository to be unchanged", cur, err)
+	}
+	if srv.CurrentSession != nil {
+		t.Errorf("Current session = %v, want no switch", srv.CurrentSession)
+	}
+
+	// The other process finishes while this one is waiting for it.
+	if err := unlock(); err != nil {
+		t.Fatal(err)
+	}
+	locks = lock.New(dir, time.Second)
+	released := make(chan struct{})
+	go func() {
+		defer close(released)
+		time.Sleep(50 * time.Millisecond)
+		if err := other.Release(); err != nil {
+			t.Errorf("other.Release() = %v", err)
+		}
+	}()
+	if err := updateToWorkUnit(ctx, st, true, repo, "foo"); err != nil {
+		t.Fatalf("updateToWorkUnit(foo) = %v", err)
+	}
+	if cur, err := repo.Current(ctx); err != nil || cur != "foo" {
+		t.Errorf("repo.Current() = %q, %v, want %q", cur, err, "foo")
+	}
+	foo := st.Session(repo, "foo")
+	if foo == nil || srv.CurrentSession == nil || srv.CurrentSession.ID() != foo.ID() {
+		t.Errorf("Current session = %v, want %v", srv.CurrentSession, foo)
+	}
+
+	// Attaching released the locks, so other processes can continue.
+	<-released
+	if err := other.Acquire(ctx, "repo:"+repo.RootDir()); err != nil {
+		t.Errorf("other.Acquire() after updateToWorkUnit = %v", err)
+	}
+	if err := other.Release(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,243 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/JeffFaer/tmux-vcs-sync/api"
+	"github.com/JeffFaer/tmux-vcs-sync/tmux"
+	"github.com/spf13/cobra"
+)
+
+// menuInputEnv is used to pass text the user typed into a tmux command-prompt
+// to menu-action. Passing it through tmux's environment avoids needing to quote
+// it for the shell that run-shell uses.
+const menuInputEnv = "TMUX_VCS_SYNC_INPUT"
+
+func init() {
+	rootCmd.AddCommand(menuActionCommand)
+}
+
+var menuActionCommand = &cobra.Command{
+	Use:       "menu-action {rename|child|delete|adopt} session-id",
+	Hidden:    true,
+	Short:     "Perform an action on a session that was selected from display-menu --actions.",
+	Args:      cobra.ExactArgs(2),
+	ValidArgs: []string{"rename", "child", "delete", "adopt"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return menuAction(cmd.Context(), args[0], args[1])
+	},
+}
+
+// actionMenu creates a menu of actions that can be performed on sesh.
+func actionMenu(sesh menuSession) []tmux.MenuElement {
+	// VCS actions only make sense if the session has a work unit.
+	noWorkUnit := sesh.repo == nil || sesh.unknownToRepo
+	workUnitName := "work unit"
+	if sesh.repo != nil {
+		workUnitName = sesh.repo.VCS().WorkUnitName()
+	}
+	prompt := func(prompt, initial, action string) string {
+		args := []string{"command-prompt", "-p", prompt}
+		if initial != "" {
+			args = append(args, "-I", initial)
+		}
+		// %%% is replaced with the user's input, escaped for tmux's double quotes.
+		// run-shell waits for menu-action to finish, so the input can be unset
+		// afterwards. Otherwise, it would be inherited by new panes.
+		template := strings.Join([]string{
+			tmux.FormatCommand("set-environment", "-g", menuInputEnv, "%%%"),
+			callbackCommand("menu-action", action, sesh.id),
+			tmux.FormatCommand("set-environment", "-gu", menuInputEnv),
+		}, " ; ")
+		return tmux.FormatCommand(append(args, template)...)
+	}
+	confirm := func(prompt, cmd string) string {
+		return tmux.FormatCommand("confirm-before", "-p", prompt+" (y/n)", cmd)
+	}
+
+	return []tmux.MenuElement{
+		tmux.MenuEntry{
+			Name:     "Switch",
+			Key:      "s",
+			Command:  tmux.FormatCommand("switch-client", "-t", sesh.id),
+			Disabled: sesh.current,
+		},
+		tmux.MenuEntry{
+			Name:     fmt.Sprintf("Rename %s", workUnitName),
+			Key:      "r",
+			Command:  prompt("New name:", sesh.workUnit, "rename"),
+			Disabled: noWorkUnit,
+		},
+		tmux.MenuEntry{
+			Name:     fmt.Sprintf("New child %s", workUnitName),
+			Key:      "c",
+			Command:  prompt("Name:", "", "child"),
+			Disabled: noWorkUnit,
+		},
+		tmux.MenuEntry{
+			Name:     fmt.Sprintf("Delete %s", workUnitName),
+			Key:      "d",
+			Command:  confirm(fmt.Sprintf("Delete %s %s?", workUnitName, sesh.workUnit), callbackCommand("menu-action", "delete", sesh.id)),
+			Disabled: noWorkUnit,
+		},
+		tmux.MenuEntry{
+			Name: "Adopt into a work unit",
+			Key:  "a",
+			// An empty name adopts the session into the current work unit of the
+			// repository it's in.
+			Command:  prompt("Work unit:", "", "adopt"),
+			Disabled: sesh.repo != nil,
+		},
+		tmux.MenuSpacer{},
+		tmux.MenuEntry{
+			Name:    "Open in new window",
+			Key:     "w",
+			Command: tmux.FormatCommand("switch-client", "-t", sesh.id) + " ; " + tmux.FormatCommand("new-window", "-t", sesh.id+":"),
+		},
+		tmux.MenuEntry{
+			Name:    "Kill session",
+			Key:     "x",
+			Command: confirm(fmt.Sprintf("Kill session %s?", sesh.name), tmux.FormatCommand("kill-session", "-t", sesh.id)),
+		},
+	}
+}
+
+func menuAction(ctx context.Context, action, sessionID string) error {
+	srv, err := tmux.CurrentServer()
+	if err != nil {
+		return err
+	}
+	st, err := newState(ctx, srv, registered())
+	if err != nil {
+		return err
+	}
+	sesh, err := sessionByID(ctx, srv, sessionID)
+	if err != nil {
+		return err
+	}
+	if action == "adopt" {
+		// The session isn't for a work unit yet.
+		repo, err := sessionRepository(ctx, registered(), sesh)
+		if err != nil {
+			return err
+		}
+		return adoptSession(ctx, st, sesh, repo, os.Getenv(menuInputEnv))
+	}
+	repo, workUnitName, err := st.WorkUnit(ctx, sesh)
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "rename":
+		newName := os.Getenv(menuInputEnv)
+		if newName == "" {
+			return fmt.Errorf("no new name given")
+		}
+		if err := renameWorkUnit(ctx, repo, workUnitName, newName); err != nil {
+			return err
+		}
+		return st.RenameSession(ctx, repo, workUnitName, newName)
+	case "child":
+		name := os.Getenv(menuInputEnv)
+		if name == "" {
+			return fmt.Errorf("no name given")
+		}
+		prev, err := repo.Current(ctx)
+		if err != nil {
+			return fmt.Errorf("couldn't check repo's current %s: %w", repo.VCS().WorkUnitName(), err)
+		}
+		if err := updateRepository(ctx, repo, workUnitName); err != nil {
+			return err
+		}
+		if err := repo.Commit(ctx, name); err != nil {
+			err = fmt.Errorf("failed to create %s %q: %w", repo.VCS().WorkUnitName(), name, err)
+			return errors.Join(err, restoreWorkUnit(ctx, repo, prev))
+		}
+		// The repository stays on the child as long as tmux switches to its
+		// session too.
+		child, err := st.NewSession(ctx, repo, name)
+		if err == nil {
+			err = srv.AttachOrSwitch(ctx, child)
+		}
+		if err != nil {
+			return errors.Join(err, restoreWorkUnit(ctx, repo, prev))
+		}
+		return nil
+	case "delete":
+		if err := api.Delete(ctx, repo, workUnitName); err != nil {
+			return fmt.Errorf("could not delete %s %q: %w", repo.VCS().WorkUnitName(), workUnitName, err)
+		}
+		return st.KillSession(ctx, repo, workUnitName)
+	default:
+		return fmt.Errorf("unknown action %q", action)
+	}
+}
+
+// updateRepository updates repo to workUnitName if it's not already the
+// current work unit.
+func updateRepository(ctx context.Context, repo api.Repository, workUnitName string) error {
+	cur, err := repo.Current(ctx)
+	if err != nil {
+		return fmt.Errorf("couldn't check repo's current %s: %w", repo.VCS().WorkUnitName(), err)
+	}
+	if cur == workUnitName {
+		return nil
+	}
+	return repo.Update(ctx, workUnitName)
+}
+
+// renameWorkUnit renames workUnitName in repo to newName. Repositories can only
+// rename their current work unit, so workUnitName is checked out for the rename
+// if it isn't already, and the previous work unit is checked back out
+// afterwards. Otherwise, renaming a session other than the attached one would
+// move the repository away from the attached session's work unit.
+func renameWorkUnit(ctx context.Context, repo api.Repository, workUnitName, newName string) error {
+	cur, err := repo.Current(ctx)
+	if err != nil {
+		return fmt.Errorf("couldn't check repo's current %s: %w", repo.VCS().WorkUnitName(), err)
+	}
+	if cur != workUnitName {
+		if err := repo.Update(ctx, workUnitName); err != nil {
+			return err
+		}
+	}
+	if err := repo.Rename(ctx, newName); err != nil {
+		err = fmt.Errorf("could not rename %s %q to %q: %w", repo.VCS().WorkUnitName(), workUnitName, newName, err)
+		if cur != workUnitName {
+			err = errors.Join(err, restoreWorkUnit(ctx, repo, cur))
+		}
+		return err
+	}
+	if cur != workUnitName {
+		return restoreWorkUnit(ctx, repo, cur)
+	}
+	return nil
+}
+
+// restoreWorkUnit checks workUnitName back out in repo after another work unit
+// was temporarily checked out.
+func restoreWorkUnit(ctx context.Context, repo api.Repository, workUnitName string) error {
+	if err := repo.Update(ctx, workUnitName); err != nil {
+		return fmt.Errorf("could not check %s %q back out: %w", repo.VCS().WorkUnitName(), workUnitName, err)
+	}
+	return nil
+}
+
+// sessionByID finds the tmux session in srv with the given ID.
+func sessionByID(ctx context.Context, srv tmux.Server, id string) (tmux.Session, error) {
+	sessions, err := srv.ListSessions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, sesh := range sessions.Sessions() {
+		if sesh.ID() == id {
+			return sesh, nil
+		}
+	}
+	return nil, fmt.Errorf("tmux session %q does not exist", id)
+}
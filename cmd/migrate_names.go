@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/JeffFaer/tmux-vcs-sync/tmux/state"
+	"github.com/spf13/cobra"
+)
+
+var (
+	migrateFrom        = state.DefaultNaming
+	migrateFromQualify string
+)
+
+func init() {
+	migrateNamesCommand.Flags().StringVar(&migrateFrom.Qualified, "from-qualified", migrateFrom.Qualified, "The qualified naming template that sessions were named with.")
+	migrateNamesCommand.Flags().StringVar(&migrateFrom.Unqualified, "from-unqualified", migrateFrom.Unqualified, "The unqualified naming template that sessions were named with.")
+	migrateNamesCommand.Flags().StringVar(&migrateFromQualify, "from-qualify", string(migrateFrom.Qualify), "When sessions were given qualified names: auto, always, or never.")
+	rootCmd.AddCommand(migrateNamesCommand)
+}
+
+var migrateNamesCommand = &cobra.Command{
+	Use:   "migrate-names",
+	Short: "Rename tmux sessions after the naming templates change.",
+	Long: `Rename tmux sessions that were named with different naming templates so that they match the configured naming templates.
+
+The --from flags describe how the sessions were named. They default to tmux-vcs-sync's default naming. Sessions that already match the configured naming templates are left alone.`,
+	Args: cobra.ExactArgs(0),
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		migrateFrom.Qualify = state.Qualify(migrateFromQualify)
+		return migrateNames(cmd.Context(), migrateFrom)
+	},
+}
+
+func migrateNames(ctx context.Context, from state.Naming) error {
+	if err := from.Validate(); err != nil {
+		return fmt.Errorf("invalid --from naming: %w", err)
+	}
+	srv, _ := selectedServer(ctx)
+	st, err := newState(ctx, srv, registered())
+	if err != nil {
+		return err
+	}
+	return st.MigrateNames(ctx, from)
+}
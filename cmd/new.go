@@ -7,8 +7,6 @@ import (
 	"log/slog"
 
 	"github.com/JeffFaer/tmux-vcs-sync/api"
-	"github.com/JeffFaer/tmux-vcs-sync/tmux"
-	"github.com/JeffFaer/tmux-vcs-sync/tmux/state"
 	"github.com/spf13/cobra"
 )
 
@@ -28,17 +26,19 @@ var newCommand = &cobra.Command{
 type workUnitCtor func(api.Repository, context.Context, string) error
 
 func newWorkUnit(ctx context.Context, workUnitName string, ctor workUnitCtor) error {
-	vcs := api.Registered()
+	vcs := registered()
 	repo, err := vcs.CurrentRepository(ctx)
 	if err != nil {
 		return err
 	}
-	srv := tmux.MaybeCurrentServer()
-	hasCurrentServer := srv != nil
-	if srv == nil {
-		srv = tmux.DefaultServer()
+	srv, hasCurrentServer := selectedServer(ctx)
+	if err := lockServer(ctx, srv); err != nil {
+		return err
+	}
+	if err := lockRepos(ctx, repo); err != nil {
+		return err
 	}
-	state, err := state.New(ctx, srv, vcs)
+	state, err := newState(ctx, srv, vcs)
 	if err != nil {
 		return err
 	}
@@ -58,9 +58,10 @@ func newWorkUnit(ctx context.Context, workUnitName string, ctor workUnitCtor) er
 	if err != nil {
 		return err
 	}
+	err = unlock()
 	if !hasCurrentServer {
 		// Attaching to a session hangs until the client is detached.
-		err = stopTrace()
+		err = errors.Join(err, stopTrace())
 	}
 	if err1 := srv.AttachOrSwitch(ctx, sesh); err1 != nil {
 		err = errors.Join(err, fmt.Errorf("failed to attach to newly created session %q: %w", sesh.ID(), err))
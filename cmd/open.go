@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/JeffFaer/go-stdlib-ext/moremaps"
+	"github.com/JeffFaer/tmux-vcs-sync/api"
+	"github.com/JeffFaer/tmux-vcs-sync/tmux/state"
+	"github.com/spf13/cobra"
+)
+
+var (
+	openAll   bool
+	openStack bool
+	openSince time.Duration
+)
+
+func init() {
+	openCommand.Flags().BoolVar(&openAll, "all", false, "Open every work unit in the current repository.")
+	openCommand.Flags().BoolVar(&openStack, "stack", false, "Open the current work unit along with its ancestors and descendants, other than trunk.")
+	openCommand.Flags().DurationVar(&openSince, "since", 0, "Only open work units that changed within this long, e.g. 168h.")
+	rootCmd.AddCommand(openCommand)
+}
+
+var openCommand = &cobra.Command{
+	Use:   "open [--all | --stack | name...]",
+	Short: "Create tmux sessions for existing work units in the current repository.",
+	Long: `Create detached tmux sessions for existing work units in the current repository, without updating the repository to point at any of them.
+
+Work units that already have sessions are skipped.`,
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return suggestWorkUnitNames(cmd.Context(), toComplete), 0
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts := openOptions{all: openAll, stack: openStack, names: args}
+		if openSince > 0 {
+			opts.changedAfter = time.Now().Add(-openSince)
+		}
+		if err := opts.validate(); err != nil {
+			return err
+		}
+		return openSessions(cmd.Context(), os.Stdout, opts)
+	},
+}
+
+type openOptions struct {
+	// Open every work unit.
+	all bool
+	// Open the current work unit's stack.
+	stack bool
+	// Open these work units.
+	names []string
+	// If set, only open work units that changed after this time.
+	changedAfter time.Time
+}
+
+func (opts openOptions) validate() error {
+	n := 0
+	for _, b := range []bool{opts.all, opts.stack, len(opts.names) > 0} {
+		if b {
+			n++
+		}
+	}
+	if n != 1 {
+		return fmt.Errorf("exactly one of --all, --stack, or work unit names is required")
+	}
+	return nil
+}
+
+func openSessions(ctx context.Context, w io.Writer, opts openOptions) error {
+	vcs := registered()
+	repo, err := vcs.CurrentRepository(ctx)
+	if err != nil {
+		return err
+	}
+	srv, _ := selectedServer(ctx)
+	st, err := newState(ctx, srv, vcs)
+	if err != nil {
+		return err
+	}
+	return openWorkUnits(ctx, w, st, repo, opts)
+}
+
+// openWorkUnits creates sessions for the work units in repo chosen by opts, and
+// reports what it did to w.
+func openWorkUnits(ctx context.Context, w io.Writer, st *state.State, repo api.Repository, opts openOptions) error {
+	wus, err := chooseWorkUnits(ctx, repo, opts)
+	if err != nil {
+		return err
+	}
+	if !opts.changedAfter.IsZero() {
+		wus, err = changedAfter(ctx, repo, wus, opts.changedAfter)
+		if err != nil {
+			return err
+		}
+	}
+	sortWorkUnits(ctx, repo, wus, "topology")
+
+	var b strings.Builder
+	defer func() { io.WriteString(w, b.String()) }()
+	if len(wus) == 0 {
+		fmt.Fprintf(&b, "There are no %ss to open.\n", repo.VCS().WorkUnitName())
+	}
+	for _, wu := range wus {
+		if st.Session(repo, wu) != nil {
+			fmt.Fprintf(&b, "%s already has a session.\n", st.SessionName(state.NewWorkUnitName(repo, wu)))
+			continue
+		}
+		if _, err := st.NewSession(ctx, repo, wu); err != nil {
+			return err
+		}
+		// The session's name might have changed to include its repository.
+		fmt.Fprintf(&b, "Opened %s.\n", st.SessionName(state.NewWorkUnitName(repo, wu)))
+	}
+	return nil
+}
+
+func chooseWorkUnits(ctx context.Context, repo api.Repository, opts openOptions) ([]string, error) {
+	switch {
+	case opts.all:
+		return repo.List(ctx, "")
+	case opts.stack:
+		return stackWorkUnits(ctx, repo)
+	}
+	var missing []string
+	for _, wu := range opts.names {
+		if ok, err := repo.Exists(ctx, wu); err != nil {
+			return nil, err
+		} else if !ok {
+			missing = append(missing, wu)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%s %q does not exist in %s", repo.VCS().WorkUnitName(), strings.Join(missing, `", "`), repo.Name())
+	}
+	wus := slices.Clone(opts.names)
+	slices.Sort(wus)
+	return slices.Compact(wus), nil
+}
+
+// stackWorkUnits finds repo's current work unit, along with its ancestors and
+// descendants. The trunk is only included if it's the current work unit.
+func stackWorkUnits(ctx context.Context, repo api.Repository) ([]string, error) {
+	cur, err := repo.Current(ctx)
+	if err != nil {
+		return nil, err
+	}
+	all, err := repo.List(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	parents, err := api.Parents(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+	children := make(map[string][]string)
+	for _, wu := range all {
+		p, ok := parents[wu]
+		if !ok {
+			return nil, fmt.Errorf("could not determine parent of %s %q", repo.VCS().WorkUnitName(), wu)
+		}
+		children[p] = append(children[p], wu)
+	}
+
+	stack := map[string]bool{cur: true}
+	// Work units without parents are the trunk.
+	for p := parents[cur]; p != "" && parents[p] != "" && !stack[p]; p = parents[p] {
+		stack[p] = true
+	}
+	for queue := []string{cur}; len(queue) > 0; queue = queue[1:] {
+		for _, c := range children[queue[0]] {
+			if !stack[c] {
+				stack[c] = true
+				queue = append(queue, c)
+			}
+		}
+	}
+	return moremaps.SortedKeys(stack), nil
+}
+
+// changedAfter filters wus down to the work units that changed after t.
+func changedAfter(ctx context.Context, repo api.Repository, wus []string, t time.Time) ([]string, error) {
+	var ret []string
+	for _, wu := range wus {
+		changed, err := api.LastChanged(ctx, repo, wu)
+		if errors.Is(err, errors.ErrUnsupported) {
+			return nil, fmt.Errorf("can't use --since: %w", err)
+		} else if err != nil {
+			return nil, err
+		}
+		if changed.After(t) {
+			ret = append(ret, wu)
+		}
+	}
+	return ret, nil
+}
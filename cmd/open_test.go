@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JeffFaer/tmux-vcs-sync/api"
+	"github.com/JeffFaer/tmux-vcs-sync/api/repotest"
+	"github.com/JeffFaer/tmux-vcs-sync/tmux/state"
+	"github.com/JeffFaer/tmux-vcs-sync/tmux/tmuxtest"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestOpenWorkUnits(t *testing.T) {
+	now := time.Now()
+	for i, tc := range []struct {
+		name    string
+		opts    openOptions
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "names",
+			opts: openOptions{names: []string{"x", "c", "x"}},
+			want: "Opened c.\nOpened x.\n",
+		},
+		{
+			name: "missing name",
+			opts: openOptions{names: []string{"x", "missing"}},
+			// Nothing is opened.
+			wantErr: true,
+		},
+		{
+			name: "stack",
+			opts: openOptions{stack: true},
+			want: "Opened a.\nOpened b.\nOpened c.\n",
+		},
+		{
+			name: "all",
+			opts: openOptions{all: true},
+			want: "Opened root.\nOpened a.\nOpened b.\nOpened c.\nOpened x.\n",
+		},
+		{
+			name: "changed recently",
+			opts: openOptions{all: true, changedAfter: now.Add(-time.Hour)},
+			want: "Opened b.\nOpened x.\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			vcs := api.VersionControlSystems{
+				repotest.NewVCS("testing/", repotest.RepoConfig{
+					Name: "repo",
+					WorkUnits: map[string][]string{
+						repotest.DefaultWorkUnitName: {"a", "x"},
+						"a":                          {"b"},
+						"b":                          {"c"},
+					},
+					LastChanged: map[string]time.Time{
+						"a": now.Add(-2 * time.Hour),
+						"b": now.Add(-time.Minute),
+						"x": now,
+					},
+				}),
+			}
+			repo, err := vcs.MaybeFindRepository(ctx, "testing/repo")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := repo.Update(ctx, "b"); err != nil {
+				t.Fatal(err)
+			}
+			// TestDisplayMenu uses small PIDs.
+			srv := tmuxtest.NewServer(1008 + i)
+			st, err := state.New(ctx, srv, vcs)
+			if err != nil {
+				t.Fatalf("state.New() = _, %v", err)
+			}
+
+			var out strings.Builder
+			err = openWorkUnits(ctx, &out, st, repo, tc.opts)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("openWorkUnits() = %v, want error? %t", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, out.String()); diff != "" {
+				t.Errorf("openWorkUnits() output diff (-want +got)\n%s", diff)
+			}
+		})
+	}
+}
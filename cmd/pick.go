@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/JeffFaer/go-stdlib-ext/moremaps"
+	"github.com/JeffFaer/tmux-vcs-sync/api"
+	"github.com/JeffFaer/tmux-vcs-sync/picker"
+	"github.com/JeffFaer/tmux-vcs-sync/tmux"
+	"github.com/JeffFaer/tmux-vcs-sync/tmux/state"
+	"github.com/spf13/cobra"
+)
+
+// previewLength is the number of recent changes to preview for each work unit.
+const previewLength = 20
+
+var pickInline bool
+
+func init() {
+	pickCommand.Flags().BoolVar(&pickInline, "inline", false, "Search in this terminal instead of in a tmux popup.")
+	rootCmd.AddCommand(pickCommand)
+}
+
+var pickCommand = &cobra.Command{
+	Use:   "pick",
+	Short: "Interactively search for a work unit to update to.",
+	Long: `Search through the work units of every repository that has a tmux session, including work units that don't have a tmux session yet. Picking a work unit is the same as running update with it.
+
+Within tmux, the search is displayed in a popup.`,
+	Args: cobra.ExactArgs(0),
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		if c := tmux.MaybeCurrentClient(); c != nil && !pickInline {
+			return pickInPopup(cmd.Context(), c)
+		}
+		return pick(cmd.Context())
+	},
+}
+
+func pickInPopup(ctx context.Context, c tmux.Client) error {
+	// The popup waits for user input, and might take a really long time. Cancel
+	// the trace early to prevent the flight recorder from thinking we took too
+	// long.
+	err := stopTrace()
+	return errors.Join(c.DisplayPopup(ctx, tmux.PopupOptions{
+		Title:           " pick ",
+		Width:           "80%",
+		Height:          "60%",
+		Command:         shellCommand("pick", "--inline"),
+		KeepOpenOnError: true,
+	}), err)
+}
+
+func pick(ctx context.Context) error {
+	vcs := registered()
+	st, hasCurrentServer, err := currentState(ctx, vcs)
+	if err != nil {
+		return err
+	}
+	repos := st.Repositories()
+	addCurrentRepository(ctx, vcs, repos)
+	items, err := pickItems(ctx, st, repos)
+	if err != nil {
+		return err
+	}
+
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return fmt.Errorf("could not open terminal: %w", err)
+	}
+	defer tty.Close()
+
+	labels := make([]string, len(items))
+	for i, item := range items {
+		labels[i] = item.label
+	}
+	i, err := picker.Run(ctx, tty, picker.Options{
+		Prompt: "> ",
+		Items:  labels,
+		Preview: func(i int) []string {
+			log, err := api.Log(ctx, items[i].repo, items[i].workUnit, previewLength)
+			if err != nil {
+				return []string{err.Error()}
+			}
+			return log
+		},
+	})
+	if errors.Is(err, picker.ErrCanceled) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return updateToWorkUnit(ctx, st, hasCurrentServer, items[i].repo, items[i].workUnit)
+}
+
+type pickItem struct {
+	repo     api.Repository
+	workUnit string
+	label    string
+}
+
+// pickItems lists every work unit in repos, grouped by repository and sorted
+// topologically.
+func pickItems(ctx context.Context, st *state.State, repos map[state.RepoName]api.Repository) ([]pickItem, error) {
+	var items []pickItem
+	for _, n := range moremaps.SortedKeysFunc(repos, repoNameCmp) {
+		repo := repos[n]
+		workUnits, err := repo.List(ctx, "")
+		if err != nil {
+			return nil, fmt.Errorf("could not list %s %ss: %w", n.Repo, repo.VCS().WorkUnitName(), err)
+		}
+		sortWorkUnits(ctx, repo, workUnits, "topology")
+		for _, wu := range workUnits {
+			items = append(items, pickItem{repo: repo, workUnit: wu, label: st.SessionName(state.NewWorkUnitName(repo, wu))})
+		}
+	}
+	return items, nil
+}
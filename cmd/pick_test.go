@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/JeffFaer/tmux-vcs-sync/api"
+	"github.com/JeffFaer/tmux-vcs-sync/api/repotest"
+	"github.com/JeffFaer/tmux-vcs-sync/tmux"
+	"github.com/JeffFaer/tmux-vcs-sync/tmux/state"
+	"github.com/JeffFaer/tmux-vcs-sync/tmux/tmuxtest"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPickItems(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	vcs := api.VersionControlSystems{
+		repotest.NewVCS("testing/", repotest.RepoConfig{
+			Name: "repo1",
+			WorkUnits: map[string][]string{
+				repotest.DefaultWorkUnitName: {"b"},
+				"b":                          {"a"},
+			},
+		}, repotest.RepoConfig{
+			Name:      "repo2",
+			WorkUnits: map[string][]string{repotest.DefaultWorkUnitName: {"c"}},
+		}),
+	}
+	// TestDisplayMenu uses small PIDs.
+	srv := tmuxtest.NewServer(1000)
+	// Only one work unit per repository has a session.
+	for _, sesh := range []tmux.NewSessionOptions{
+		{Name: "a", StartDir: "testing/repo1"},
+		{Name: "c", StartDir: "testing/repo2"},
+	} {
+		if _, err := srv.NewSession(ctx, sesh); err != nil {
+			t.Fatalf("tmux.NewSession(%#v) = _, %v", sesh, err)
+		}
+	}
+	st, err := state.New(ctx, srv, vcs)
+	if err != nil {
+		t.Fatalf("state.New() = _, %v", err)
+	}
+
+	items, err := pickItems(ctx, st, st.Repositories())
+	if err != nil {
+		t.Fatalf("pickItems() = _, %v", err)
+	}
+	var got []string
+	for _, item := range items {
+		got = append(got, item.label)
+	}
+	want := []string{
+		"repo1>" + repotest.DefaultWorkUnitName, "repo1>b", "repo1>a",
+		"repo2>" + repotest.DefaultWorkUnitName, "repo2>c",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("pickItems() diff (-want +got)\n%s", diff)
+	}
+}
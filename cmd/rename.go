@@ -4,9 +4,7 @@ import (
 	"context"
 	"fmt"
 
-	"github.com/JeffFaer/tmux-vcs-sync/api"
 	"github.com/JeffFaer/tmux-vcs-sync/tmux"
-	"github.com/JeffFaer/tmux-vcs-sync/tmux/state"
 	"github.com/spf13/cobra"
 )
 
@@ -24,7 +22,7 @@ var renameCommand = &cobra.Command{
 }
 
 func rename(ctx context.Context, newName string) error {
-	vcs := api.Registered()
+	vcs := registered()
 	repo, err := vcs.CurrentRepository(ctx)
 	if err != nil {
 		return err
@@ -33,15 +31,20 @@ func rename(ctx context.Context, newName string) error {
 	if err != nil {
 		return err
 	}
-	state, err := state.New(ctx, sesh.Server(), vcs)
+	if err := lockServer(ctx, sesh.Server()); err != nil {
+		return err
+	}
+	if err := lockRepos(ctx, repo); err != nil {
+		return err
+	}
+	state, err := newState(ctx, sesh.Server(), vcs)
 	if err != nil {
 		return err
 	}
-	oldNameProp, err := sesh.Property(ctx, tmux.SessionName)
+	_, oldName, err := state.WorkUnit(ctx, sesh)
 	if err != nil {
 		return err
 	}
-	oldName := tmux.PropertyValue(tmux.SessionName, oldNameProp)
 	if state.Session(repo, newName) == nil {
 		// If the tmux session doesn't yet exist, try renaming the work unit first.
 		// Otherwise, if the tmux session does exist, we know that state.RenameSession will
@@ -24,7 +24,21 @@ import (
 )
 
 func Execute(ctx context.Context) error {
-	return rootCmd.ExecuteContext(ctx)
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
+		return err
+	}
+	if pendingExitCode != 0 {
+		return ExitCodeError(pendingExitCode)
+	}
+	return nil
+}
+
+// ExitCodeError is returned by Execute when the command didn't fail, but it
+// still wants to exit with a non-zero exit code.
+type ExitCodeError int
+
+func (err ExitCodeError) Error() string {
+	return fmt.Sprintf("exit code %d", int(err))
 }
 
 var (
@@ -37,12 +51,21 @@ var (
 		slog.LevelDebug,
 	}
 
+	// pendingExitCode is the exit code that commands want once they're done.
+	// Unlike os.Exit, it lets PersistentPostRunE release locks and record
+	// traces.
+	pendingExitCode int
+
+	// The tmux server to use instead of the current or default one.
+	socketName string
+	socketPath string
+
 	traceTask *trace.Task
 
 	doTrace   bool
 	traceFile *os.File
 
-	recordAfter    = 100 * time.Millisecond
+	recordAfter    = config.Default().Trace.RecordAfter
 	start          time.Time
 	commandName    string
 	flightRecorder *exptrace.FlightRecorder
@@ -62,11 +85,18 @@ var rootCmd = &cobra.Command{
 		}
 
 		configureLogging()
+		if err := loadConfig(cmd); err != nil {
+			return err
+		}
 		ctx, err := startTrace(cmd, args)
 		if err != nil {
 			return err
 		}
 		cmd.SetContext(ctx)
+		// The config and doctor commands don't need any VCS.
+		if isRepairCommand(cmd) {
+			return nil
+		}
 		if err := loadPlugins(ctx); err != nil {
 			return err
 		}
@@ -76,6 +106,9 @@ var rootCmd = &cobra.Command{
 		if cobraBuiltin(cmd) {
 			return nil
 		}
+		if err := unlock(); err != nil {
+			return err
+		}
 		if err := stopTrace(); err != nil {
 			return err
 		}
@@ -107,6 +140,9 @@ func formatVersion() string {
 
 func init() {
 	rootCmd.PersistentFlags().CountVarP(&verbosity, "verbose", "v", "Log more verbosely.")
+	rootCmd.PersistentFlags().StringVarP(&socketName, "socket-name", "L", "", "Use the tmux server with this socket name (tmux -L), even from within another tmux server.")
+	rootCmd.PersistentFlags().StringVarP(&socketPath, "socket-path", "S", "", "Use the tmux server with this socket path (tmux -S), even from within another tmux server.")
+	rootCmd.MarkFlagsMutuallyExclusive("socket-name", "socket-path")
 	rootCmd.PersistentFlags().BoolVar(&doTrace, "trace", false, "Whether to record an execution trace or not.")
 	if err := rootCmd.PersistentFlags().MarkHidden("trace"); err != nil {
 		log.Fatal(err)
@@ -210,25 +246,13 @@ var stopTrace = sync.OnceValue(func() error {
 func loadPlugins(ctx context.Context) error {
 	defer trace.StartRegion(ctx, "loading plugins").End()
 
-	dir, err := config.PluginDir()
+	dir, files, err := pluginFiles()
 	if err != nil {
 		return err
 	}
-	des, err := os.ReadDir(dir)
-	if err != nil {
-		return fmt.Errorf("could not read VCS dir: %w", err)
-	}
 	var loaded int
 	var errs []error
-	for _, de := range des {
-		if de.IsDir() {
-			continue
-		}
-		if !strings.HasSuffix(de.Name(), ".so") {
-			continue
-		}
-
-		path := filepath.Join(dir, de.Name())
+	for _, path := range files {
 		if err := loadPlugin(ctx, path); err != nil {
 			errs = append(errs, fmt.Errorf("%s: %w", path, err))
 		} else {
@@ -248,6 +272,28 @@ func loadPlugins(ctx context.Context) error {
 	return nil
 }
 
+// pluginFiles finds the VCS libraries in the plugin directory.
+func pluginFiles() (dir string, files []string, err error) {
+	dir, err = config.PluginDir()
+	if err != nil {
+		return "", nil, err
+	}
+	des, err := os.ReadDir(dir)
+	if err != nil {
+		return "", nil, fmt.Errorf("could not read VCS dir: %w", err)
+	}
+	for _, de := range des {
+		if de.IsDir() {
+			continue
+		}
+		if !strings.HasSuffix(de.Name(), ".so") {
+			continue
+		}
+		files = append(files, filepath.Join(dir, de.Name()))
+	}
+	return dir, files, nil
+}
+
 func loadPlugin(ctx context.Context, file string) error {
 	defer trace.StartRegion(ctx, filepath.Base(file)).End()
 	_, err := plugin.Open(file)
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/JeffFaer/tmux-vcs-sync/tmux"
+	"github.com/JeffFaer/tmux-vcs-sync/tmux/state"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(sessionRenamedCommand)
+}
+
+var sessionRenamedCommand = &cobra.Command{
+	Use:    "session-renamed session-id",
+	Hidden: true,
+	Short:  "Rename a work unit after its tmux session was renamed with tmux's rename-session.",
+	Long: `Rename a work unit after its tmux session was renamed with tmux's rename-session. If the work unit can't be renamed, the session's name is reverted.
+
+This is meant to be invoked from a tmux hook:
+
+  set-hook -g session-renamed 'run-shell "tmux-vcs-sync session-renamed #{hook_session}"'`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return sessionRenamed(cmd.Context(), args[0])
+	},
+}
+
+func sessionRenamed(ctx context.Context, sessionID string) error {
+	srv, _ := selectedServer(ctx)
+	st, err := newState(ctx, srv, registered())
+	if err != nil {
+		return err
+	}
+	sesh, err := sessionByID(ctx, srv, sessionID)
+	if err != nil {
+		return err
+	}
+	return syncRenamedSession(ctx, st, sesh)
+}
+
+// syncRenamedSession renames the work unit that sesh was for so that it matches
+// sesh's new name. sesh's name is reverted if that's not possible.
+func syncRenamedSession(ctx context.Context, st *state.State, sesh tmux.Session) error {
+	old, err := st.StoredWorkUnit(ctx, sesh)
+	if err != nil {
+		return err
+	}
+	if old == "" {
+		slog.Info("tmux session isn't known to be for a work unit.", "session_id", sesh.ID())
+		return nil
+	}
+	repo, new, err := st.WorkUnit(ctx, sesh)
+	if err != nil {
+		// sesh's new name doesn't represent a work unit.
+		return errors.Join(fmt.Errorf("tmux session %q was renamed to an invalid name: %w", sesh.ID(), err), st.RevertSessionName(ctx, sesh, old))
+	}
+	if new == old {
+		return nil
+	}
+
+	slog.Info("tmux session was renamed.", "session_id", sesh.ID(), "old", old, "new", new)
+	if err := renameWorkUnit(ctx, repo, old, new); err != nil {
+		return errors.Join(err, st.RevertSessionName(ctx, sesh, old))
+	}
+	return st.RecordWorkUnit(ctx, sesh, new)
+}
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/JeffFaer/tmux-vcs-sync/api"
+	"github.com/JeffFaer/tmux-vcs-sync/api/repotest"
+	"github.com/JeffFaer/tmux-vcs-sync/tmux"
+	"github.com/JeffFaer/tmux-vcs-sync/tmux/state"
+	"github.com/JeffFaer/tmux-vcs-sync/tmux/tmuxtest"
+)
+
+func TestSyncRenamedSession(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	vcs := api.VersionControlSystems{
+		repotest.NewVCS("testing/", repotest.RepoConfig{
+			Name:      "repo",
+			WorkUnits: map[string][]string{repotest.DefaultWorkUnitName: {"foo", "bar"}},
+		}),
+	}
+	// TestDisplayMenu uses small PIDs.
+	srv := tmuxtest.NewServer(1014)
+	scratch, err := srv.NewSession(ctx, tmux.NewSessionOptions{Name: "scratch", StartDir: "testing/repo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, err := state.New(ctx, srv, vcs)
+	if err != nil {
+		t.Fatalf("state.New() = _, %v", err)
+	}
+	repo, err := vcs.MaybeFindRepository(ctx, "testing/repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	foo, err := st.NewSession(ctx, repo, "foo")
+	if err != nil {
+		t.Fatalf("NewSession(foo) = _, %v", err)
+	}
+	// Renaming sessions shouldn't change the repository's current work unit.
+	if err := repo.Update(ctx, repotest.DefaultWorkUnitName); err != nil {
+		t.Fatal(err)
+	}
+	checkCurrent := func() {
+		t.Helper()
+		if cur, err := repo.Current(ctx); err != nil || cur != repotest.DefaultWorkUnitName {
+			t.Errorf("repo.Current() = %q, %v, want %q", cur, err, repotest.DefaultWorkUnitName)
+		}
+	}
+	// Simulate tmux's rename-session, which this tool doesn't know about until
+	// the hook runs.
+	renamed := func(sesh tmux.Session, name string) error {
+		t.Helper()
+		if err := sesh.Rename(ctx, name); err != nil {
+			t.Fatal(err)
+		}
+		st, err := state.New(ctx, srv, vcs)
+		if err != nil {
+			t.Fatalf("state.New() = _, %v", err)
+		}
+		return syncRenamedSession(ctx, st, sesh)
+	}
+	sessionName := func(sesh tmux.Session) string {
+		t.Helper()
+		prop, err := sesh.Property(ctx, tmux.SessionName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tmux.PropertyValue(tmux.SessionName, prop)
+	}
+
+	if err := renamed(scratch, "scratch2"); err != nil {
+		t.Errorf("syncRenamedSession(scratch) = %v, want nil since it's not for a work unit", err)
+	}
+
+	if err := renamed(foo, "baz"); err != nil {
+		t.Fatalf("syncRenamedSession(foo -> baz) = %v", err)
+	}
+	if ok, err := repo.Exists(ctx, "baz"); err != nil || !ok {
+		t.Errorf("repo.Exists(baz) = %t, %v, want true", ok, err)
+	}
+	if ok, err := repo.Exists(ctx, "foo"); err != nil || ok {
+		t.Errorf("repo.Exists(foo) = %t, %v, want false", ok, err)
+	}
+	checkCurrent()
+
+	if err := renamed(foo, "bar"); err == nil {
+		t.Errorf("syncRenamedSession(baz -> bar) = nil, want an error since bar already exists")
+	}
+	if got := sessionName(foo); got != "baz" {
+		t.Errorf("foo's name = %q, want it to be reverted to %q", got, "baz")
+	}
+	if ok, err := repo.Exists(ctx, "baz"); err != nil || !ok {
+		t.Errorf("repo.Exists(baz) = %t, %v, want true", ok, err)
+	}
+	checkCurrent()
+
+	// Renaming the session to the name it already represents is a no-op.
+	if err := renamed(foo, "baz"); err != nil {
+		t.Errorf("syncRenamedSession(baz -> baz) = %v", err)
+	}
+}
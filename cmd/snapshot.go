@@ -0,0 +1,213 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/JeffFaer/go-stdlib-ext/morecmp"
+	"github.com/JeffFaer/tmux-vcs-sync/api"
+	"github.com/JeffFaer/tmux-vcs-sync/api/config"
+	"github.com/JeffFaer/tmux-vcs-sync/tmux"
+	"github.com/JeffFaer/tmux-vcs-sync/tmux/state"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(snapshotCommand)
+	rootCmd.AddCommand(restoreCommand)
+}
+
+var snapshotCommand = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Save the tmux sessions for work units so that they can be restored later.",
+	Long: `Save each tmux session's repository, work unit, windows, and pane directories so that restore can recreate them, e.g. after the tmux server restarts.
+
+Sessions that aren't for work units aren't saved. Each snapshot replaces the last one.`,
+	Args: cobra.ExactArgs(0),
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		ctx := cmd.Context()
+		srv, _ := selectedServer(ctx)
+		st, err := newState(ctx, srv, registered())
+		if err != nil {
+			return err
+		}
+		snap, err := takeSnapshot(ctx, st)
+		if err != nil {
+			return err
+		}
+		path, err := config.SnapshotFile()
+		if err != nil {
+			return err
+		}
+		if err := writeSnapshotFile(path, snap); err != nil {
+			return err
+		}
+		fmt.Printf("Saved %d sessions to %s.\n", len(snap.Sessions), path)
+		return nil
+	},
+}
+
+var restoreCommand = &cobra.Command{
+	Use:   "restore",
+	Short: "Recreate the tmux sessions saved by snapshot.",
+	Long: `Recreate the tmux sessions saved by snapshot, along with their windows and pane directories.
+
+Sessions whose work units no longer exist, or that already exist, are skipped.`,
+	Args: cobra.ExactArgs(0),
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		ctx := cmd.Context()
+		path, err := config.SnapshotFile()
+		if err != nil {
+			return err
+		}
+		snap, err := readSnapshotFile(path)
+		if err != nil {
+			return err
+		}
+		srv, _ := selectedServer(ctx)
+		vcs := registered()
+		st, err := newState(ctx, srv, vcs)
+		if err != nil {
+			return err
+		}
+		return restoreSnapshot(ctx, os.Stdout, st, vcs, snap)
+	},
+}
+
+// A snapshot is the saved state of the tmux sessions for work units.
+type snapshot struct {
+	Sessions []snapshotSession `json:"sessions"`
+}
+
+type snapshotSession struct {
+	VCS      string           `json:"vcs"`
+	RepoDir  string           `json:"repo_dir"`
+	WorkUnit string           `json:"work_unit"`
+	Windows  []snapshotWindow `json:"windows"`
+}
+
+type snapshotWindow struct {
+	Name     string   `json:"name"`
+	Layout   string   `json:"layout"`
+	PaneDirs []string `json:"pane_dirs"`
+}
+
+func takeSnapshot(ctx context.Context, st *state.State) (snapshot, error) {
+	var snap snapshot
+	for n, sesh := range st.Sessions() {
+		windows, err := sesh.Windows(ctx)
+		if err != nil {
+			return snapshot{}, err
+		}
+		s := snapshotSession{VCS: n.VCS, RepoDir: n.Dir, WorkUnit: n.WorkUnit}
+		for _, w := range windows {
+			s.Windows = append(s.Windows, snapshotWindow(w))
+		}
+		snap.Sessions = append(snap.Sessions, s)
+	}
+	slices.SortFunc(snap.Sessions, morecmp.Comparing(func(s snapshotSession) string { return s.RepoDir }).
+		AndThen(morecmp.Comparing(func(s snapshotSession) string { return s.WorkUnit })))
+	return snap, nil
+}
+
+func writeSnapshotFile(path string, snap snapshot) error {
+	b, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file first so that a failure doesn't lose the last
+	// snapshot.
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+func readSnapshotFile(path string) (snapshot, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return snapshot{}, fmt.Errorf("there is no snapshot at %s: run tmux-vcs-sync snapshot first", path)
+	} else if err != nil {
+		return snapshot{}, err
+	}
+	var snap snapshot
+	if err := json.Unmarshal(b, &snap); err != nil {
+		return snapshot{}, fmt.Errorf("could not parse snapshot %s: %w", path, err)
+	}
+	return snap, nil
+}
+
+// restoreSnapshot recreates the sessions in snap, and reports what it did to w.
+func restoreSnapshot(ctx context.Context, w io.Writer, st *state.State, vcs api.VersionControlSystems, snap snapshot) error {
+	var b strings.Builder
+	defer func() { io.WriteString(w, b.String()) }()
+	for _, s := range snap.Sessions {
+		repo, reason := findSnapshotWorkUnit(ctx, st, vcs, s)
+		if reason != "" {
+			slog.Info("Skipping session.", "vcs", s.VCS, "dir", s.RepoDir, "work_unit", s.WorkUnit, "reason", reason)
+			fmt.Fprintf(&b, "Skipped %q in %s: %s.\n", s.WorkUnit, s.RepoDir, reason)
+			continue
+		}
+		sesh, err := st.NewSession(ctx, repo, s.WorkUnit)
+		if err != nil {
+			return err
+		}
+		if len(s.Windows) > 0 {
+			windows := make([]tmux.Window, len(s.Windows))
+			for i, w := range s.Windows {
+				windows[i] = tmux.Window(w)
+			}
+			if err := sesh.ReplaceWindows(ctx, windows); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(&b, "Restored %s.\n", st.SessionName(state.NewWorkUnitName(repo, s.WorkUnit)))
+	}
+	return nil
+}
+
+// findSnapshotWorkUnit finds the repository for s. If s shouldn't be restored,
+// it returns the reason why instead.
+func findSnapshotWorkUnit(ctx context.Context, st *state.State, vcs api.VersionControlSystems, s snapshotSession) (api.Repository, string) {
+	i := slices.IndexFunc(vcs, func(v api.VersionControlSystem) bool { return v.Name() == s.VCS })
+	if i < 0 {
+		return nil, fmt.Sprintf("%s isn't registered", s.VCS)
+	}
+	// The repository's directory might have been deleted, too.
+	repo, err := vcs[i].Repository(ctx, s.RepoDir)
+	if err != nil {
+		return nil, fmt.Sprintf("could not open the repository: %v", err)
+	}
+	if repo == nil {
+		return nil, "the repository no longer exists"
+	}
+	wus, err := repo.List(ctx, "")
+	if err != nil {
+		return nil, fmt.Sprintf("could not list work units: %v", err)
+	}
+	if !slices.Contains(wus, s.WorkUnit) {
+		return nil, "the work unit no longer exists"
+	}
+	if st.Session(repo, s.WorkUnit) != nil {
+		return nil, "the session already exists"
+	}
+	return repo, ""
+}
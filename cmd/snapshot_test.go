@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JeffFaer/tmux-vcs-sync/api"
+	"github.com/JeffFaer/tmux-vcs-sync/api/repotest"
+	"github.com/JeffFaer/tmux-vcs-sync/tmux"
+	"github.com/JeffFaer/tmux-vcs-sync/tmux/state"
+	"github.com/JeffFaer/tmux-vcs-sync/tmux/tmuxtest"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	vcs := api.VersionControlSystems{
+		repotest.NewVCS("testing/", repotest.RepoConfig{
+			Name:      "repo",
+			WorkUnits: map[string][]string{repotest.DefaultWorkUnitName: {"foo", "bar", "baz"}},
+		}),
+	}
+	// TestDisplayMenu uses small PIDs.
+	srv := tmuxtest.NewServer(1007)
+	windows := []tmux.Window{
+		{Name: "edit", Layout: "layout", PaneDirs: []string{"testing/repo", "testing/repo/sub"}},
+		{Name: "run", PaneDirs: []string{"testing/repo"}},
+	}
+	for _, name := range []string{"foo", "bar", "baz"} {
+		sesh, err := srv.NewSession(ctx, tmux.NewSessionOptions{Name: name, StartDir: "testing/repo"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name == "foo" {
+			if err := sesh.ReplaceWindows(ctx, windows); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	st, err := state.New(ctx, srv, vcs)
+	if err != nil {
+		t.Fatalf("state.New() = _, %v", err)
+	}
+	snap, err := takeSnapshot(ctx, st)
+	if err != nil {
+		t.Fatalf("takeSnapshot() = _, %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "sessions.json")
+	if err := writeSnapshotFile(path, snap); err != nil {
+		t.Fatalf("writeSnapshotFile() = %v", err)
+	}
+	snap, err = readSnapshotFile(path)
+	if err != nil {
+		t.Fatalf("readSnapshotFile() = _, %v", err)
+	}
+
+	// The tmux server restarted, and bar was deleted in the meantime.
+	if err := srv.Kill(ctx); err != nil {
+		t.Fatal(err)
+	}
+	repo, err := vcs.MaybeFindRepository(ctx, "testing/repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := api.Delete(ctx, repo, "bar"); err != nil {
+		t.Fatal(err)
+	}
+	st, err = state.New(ctx, srv, vcs)
+	if err != nil {
+		t.Fatalf("state.New() = _, %v", err)
+	}
+
+	var out strings.Builder
+	if err := restoreSnapshot(ctx, &out, st, vcs, snap); err != nil {
+		t.Fatalf("restoreSnapshot() = %v", err)
+	}
+	wantOut := `Skipped "bar" in testing/repo: the work unit no longer exists.
+Restored baz.
+Restored foo.
+`
+	if diff := cmp.Diff(wantOut, out.String()); diff != "" {
+		t.Errorf("restoreSnapshot() output diff (-want +got)\n%s", diff)
+	}
+
+	sesh := st.Session(repo, "foo")
+	if sesh == nil {
+		t.Fatalf("foo wasn't restored")
+	}
+	got, err := sesh.Windows(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(windows, got); diff != "" {
+		t.Errorf("foo's windows diff (-want +got)\n%s", diff)
+	}
+}
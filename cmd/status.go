@@ -0,0 +1,210 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/JeffFaer/go-stdlib-ext/morecmp"
+	"github.com/JeffFaer/tmux-vcs-sync/api"
+	"github.com/JeffFaer/tmux-vcs-sync/tmux"
+	"github.com/JeffFaer/tmux-vcs-sync/tmux/state"
+	"github.com/spf13/cobra"
+)
+
+// syncState summarizes whether the current tmux session and its repository
+// agree with each other.
+type syncState string
+
+const (
+	inSync        syncState = "in_sync"
+	outOfSync     syncState = "out_of_sync"
+	notApplicable syncState = "not_applicable"
+)
+
+// exitCode is the exit code of the status command for each syncState. 1 is
+// left for errors.
+var exitCode = map[syncState]int{
+	inSync:        0,
+	outOfSync:     2,
+	notApplicable: 3,
+}
+
+var statusJSON bool
+
+func init() {
+	statusCommand.Flags().BoolVar(&statusJSON, "json", false, "Print the status as JSON.")
+	rootCmd.AddCommand(statusCommand)
+}
+
+var statusCommand = &cobra.Command{
+	Use:   "status",
+	Short: "Show whether the current tmux session and its repository are in sync.",
+	Long: `Show the current tmux session, the work unit it's for, and the repository's current work unit, along with any sessions whose work units no longer exist.
+
+Exit codes:
+  0: The current session and its repository are in sync.
+  2: The current session and its repository are out of sync.
+  3: There's no current session, or it isn't for a work unit.`,
+	Args: cobra.ExactArgs(0),
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		srv, _ := selectedServer(cmd.Context())
+		curSesh := currentSession(cmd.Context(), srv)
+		st, err := syncStatus(cmd.Context(), srv, curSesh, registered())
+		if err != nil {
+			return err
+		}
+		write := writeStatus
+		if statusJSON {
+			write = writeJSONStatus
+		}
+		if err := write(os.Stdout, st); err != nil {
+			return err
+		}
+		pendingExitCode = exitCode[st.state]
+		return nil
+	},
+}
+
+type statusReport struct {
+	state syncState
+	// reason explains why the state is notApplicable.
+	reason string
+
+	// These are only set if there's a current session.
+	sessionID, sessionName string
+	// These are only set if the current session is for a work unit.
+	repo     api.Repository
+	workUnit state.WorkUnitName
+	current  string
+
+	stale []staleSession
+}
+
+type staleSession struct {
+	id, name string
+	workUnit state.WorkUnitName
+}
+
+// syncStatus determines whether curSesh, which may be nil, is in sync with its
+// repository.
+func syncStatus(ctx context.Context, srv tmux.Server, curSesh tmux.Session, vcs api.VersionControlSystems) (statusReport, error) {
+	st, err := newState(ctx, srv, vcs)
+	if err != nil {
+		return statusReport{}, err
+	}
+	var report statusReport
+	for _, s := range st.PlanPrune(ctx) {
+		report.stale = append(report.stale, staleSession{id: s.Session.ID(), name: s.SessionName, workUnit: s.WorkUnit})
+	}
+	slices.SortFunc(report.stale, morecmp.Comparing(func(s staleSession) string { return s.name }))
+
+	if curSesh == nil {
+		report.state, report.reason = notApplicable, "not running in tmux"
+		return report, nil
+	}
+	report.sessionID = curSesh.ID()
+	prop, err := curSesh.Property(ctx, tmux.SessionName)
+	if err != nil {
+		return statusReport{}, err
+	}
+	report.sessionName = tmux.PropertyValue(tmux.SessionName, prop)
+
+	repo, wu, err := st.WorkUnit(ctx, curSesh)
+	if err != nil {
+		slog.Info("Current session isn't for a work unit.", "error", err)
+		report.state, report.reason = notApplicable, "the current session isn't for a work unit"
+		return report, nil
+	}
+	report.repo = repo
+	report.workUnit = state.NewWorkUnitName(repo, wu)
+	report.current, err = repo.Current(ctx)
+	if err != nil {
+		return statusReport{}, fmt.Errorf("couldn't check repo's current %s: %w", repo.VCS().WorkUnitName(), err)
+	}
+	if report.current == wu {
+		report.state = inSync
+	} else {
+		report.state = outOfSync
+	}
+	return report, nil
+}
+
+func writeStatus(w io.Writer, report statusReport) error {
+	var b strings.Builder
+	if report.sessionID != "" {
+		fmt.Fprintf(&b, "Session:   %s (%s)\n", report.sessionName, report.sessionID)
+	}
+	if report.repo != nil {
+		fmt.Fprintf(&b, "Repo:      %s (%s, %s)\n", report.workUnit.Repo, report.workUnit.VCS, report.workUnit.Dir)
+		fmt.Fprintf(&b, "Session's %s: %s\n", report.repo.VCS().WorkUnitName(), report.workUnit.WorkUnit)
+		fmt.Fprintf(&b, "Repo's %s:    %s\n", report.repo.VCS().WorkUnitName(), report.current)
+	}
+	switch report.state {
+	case inSync:
+		b.WriteString("In sync.\n")
+	case outOfSync:
+		b.WriteString("Out of sync. Run update to fix it.\n")
+	case notApplicable:
+		fmt.Fprintf(&b, "Not applicable: %s.\n", report.reason)
+	}
+	if len(report.stale) > 0 {
+		b.WriteString("\nSessions whose work units no longer exist (run cleanup to kill them):\n")
+		for _, s := range report.stale {
+			fmt.Fprintf(&b, "  %s (%s)\n", s.name, s.id)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+type jsonStatus struct {
+	State         syncState           `json:"state"`
+	Reason        string              `json:"reason,omitempty"`
+	Session       *jsonStatusSession  `json:"session,omitempty"`
+	Current       string              `json:"current,omitempty"`
+	StaleSessions []jsonStatusSession `json:"stale_sessions"`
+}
+
+type jsonStatusSession struct {
+	ID       string        `json:"id"`
+	Name     string        `json:"name"`
+	WorkUnit *jsonWorkUnit `json:"work_unit,omitempty"`
+}
+
+type jsonWorkUnit struct {
+	VCS      string `json:"vcs"`
+	Repo     string `json:"repo"`
+	RepoDir  string `json:"repo_dir"`
+	WorkUnit string `json:"work_unit"`
+}
+
+func newJSONWorkUnit(n state.WorkUnitName) *jsonWorkUnit {
+	return &jsonWorkUnit{VCS: n.VCS, Repo: n.Repo, RepoDir: n.Dir, WorkUnit: n.WorkUnit}
+}
+
+func writeJSONStatus(w io.Writer, report statusReport) error {
+	out := jsonStatus{
+		State:         report.state,
+		Reason:        report.reason,
+		Current:       report.current,
+		StaleSessions: make([]jsonStatusSession, 0, len(report.stale)),
+	}
+	if report.sessionID != "" {
+		out.Session = &jsonStatusSession{ID: report.sessionID, Name: report.sessionName}
+		if report.repo != nil {
+			out.Session.WorkUnit = newJSONWorkUnit(report.workUnit)
+		}
+	}
+	for _, s := range report.stale {
+		out.StaleSessions = append(out.StaleSessions, jsonStatusSession{ID: s.id, Name: s.name, WorkUnit: newJSONWorkUnit(s.workUnit)})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
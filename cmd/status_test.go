@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JeffFaer/tmux-vcs-sync/api"
+	"github.com/JeffFaer/tmux-vcs-sync/api/repotest"
+	"github.com/JeffFaer/tmux-vcs-sync/tmux"
+	"github.com/JeffFaer/tmux-vcs-sync/tmux/tmuxtest"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSyncStatus(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	vcs := api.VersionControlSystems{
+		repotest.NewVCS("testing/", repotest.RepoConfig{
+			Name:      "repo",
+			WorkUnits: map[string][]string{repotest.DefaultWorkUnitName: {"foo"}},
+		}),
+	}
+	repo, err := vcs.MaybeFindRepository(ctx, "testing/repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Update(ctx, "foo"); err != nil {
+		t.Fatal(err)
+	}
+	// TestDisplayMenu uses small PIDs.
+	srv := tmuxtest.NewServer(1002)
+	sessions := make(map[string]tmux.Session)
+	for _, opts := range []tmux.NewSessionOptions{
+		{Name: repotest.DefaultWorkUnitName, StartDir: "testing/repo"},
+		{Name: "foo", StartDir: "testing/repo"},
+		{Name: "gone", StartDir: "testing/repo"},
+		{Name: "bar", StartDir: "someOtherDir"},
+	} {
+		sesh, err := srv.NewSession(ctx, opts)
+		if err != nil {
+			t.Fatalf("tmux.NewSession(%#v) = _, %v", opts, err)
+		}
+		sessions[opts.Name] = sesh
+	}
+
+	for _, tc := range []struct {
+		name    string
+		curSesh tmux.Session
+		want    jsonStatus
+	}{
+		{
+			name:    "in sync",
+			curSesh: sessions["foo"],
+			want: jsonStatus{
+				State: inSync,
+				Session: &jsonStatusSession{
+					ID:       sessions["foo"].ID(),
+					Name:     "foo",
+					WorkUnit: &jsonWorkUnit{VCS: "fake(testing/)", Repo: "repo", RepoDir: "testing/repo", WorkUnit: "foo"},
+				},
+				Current: "foo",
+			},
+		},
+		{
+			name:    "out of sync",
+			curSesh: sessions[repotest.DefaultWorkUnitName],
+			want: jsonStatus{
+				State: outOfSync,
+				Session: &jsonStatusSession{
+					ID:       sessions[repotest.DefaultWorkUnitName].ID(),
+					Name:     repotest.DefaultWorkUnitName,
+					WorkUnit: &jsonWorkUnit{VCS: "fake(testing/)", Repo: "repo", RepoDir: "testing/repo", WorkUnit: repotest.DefaultWorkUnitName},
+				},
+				Current: "foo",
+			},
+		},
+		{
+			name:    "not a work unit",
+			curSesh: sessions["bar"],
+			want: jsonStatus{
+				State:   notApplicable,
+				Reason:  "the current session isn't for a work unit",
+				Session: &jsonStatusSession{ID: sessions["bar"].ID(), Name: "bar"},
+			},
+		},
+		{
+			name: "outside tmux",
+			want: jsonStatus{
+				State:  notApplicable,
+				Reason: "not running in tmux",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			report, err := syncStatus(ctx, srv, tc.curSesh, vcs)
+			if err != nil {
+				t.Fatalf("syncStatus() = _, %v", err)
+			}
+			var b strings.Builder
+			if err := writeJSONStatus(&b, report); err != nil {
+				t.Fatalf("writeJSONStatus() = %v", err)
+			}
+			var got jsonStatus
+			if err := json.Unmarshal([]byte(b.String()), &got); err != nil {
+				t.Fatalf("json.Unmarshal(%q) = %v", b.String(), err)
+			}
+
+			tc.want.StaleSessions = []jsonStatusSession{{
+				ID:       sessions["gone"].ID(),
+				Name:     "gone",
+				WorkUnit: &jsonWorkUnit{VCS: "fake(testing/)", Repo: "repo", RepoDir: "testing/repo", WorkUnit: "gone"},
+			}}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("syncStatus() diff (-want +got)\n%s", diff)
+			}
+		})
+	}
+}
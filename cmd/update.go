@@ -5,7 +5,6 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
-	"os"
 	"slices"
 	"strings"
 
@@ -15,10 +14,14 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var failNoop bool
+var (
+	failNoop bool
+	updateID string
+)
 
 func init() {
 	updateCommand.Flags().BoolVar(&failNoop, "fail-noop", false, "If update didn't do anything (because both tmux and the repository were already in the correct state), return a non-zero exit code.")
+	updateCommand.Flags().StringVar(&updateID, "id", "", "Update to the work unit of the tmux session with this ID, as printed by list-menu.")
 	rootCmd.AddCommand(updateCommand)
 }
 
@@ -35,6 +38,12 @@ var updateCommand = &cobra.Command{
 		return suggestWorkUnitNames(cmd.Context(), toComplete), 0
 	},
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if updateID != "" {
+			if len(args) > 0 {
+				return fmt.Errorf("can't use both --id and a work unit name")
+			}
+			return updateToSession(cmd.Context(), updateID)
+		}
 		if len(args) == 0 {
 			return update(cmd.Context())
 		}
@@ -43,24 +52,21 @@ var updateCommand = &cobra.Command{
 }
 
 func suggestWorkUnitNames(ctx context.Context, toComplete string) []string {
-	vcs := api.Registered()
+	vcs := registered()
 	repos := make(map[state.RepoName]api.Repository)
-	if srv := tmux.MaybeCurrentServer(); srv != nil {
-		st, err := state.New(ctx, srv, vcs)
+	srv := flaggedServer()
+	if srv == nil {
+		srv = tmux.MaybeCurrentServer()
+	}
+	if srv != nil {
+		st, err := newState(ctx, srv, vcs)
 		if err != nil {
 			slog.Warn("Could not determine repositories from tmux server.", "server", srv, "error", err)
 		} else {
 			repos = st.Repositories()
 		}
 	}
-	if repo, err := vcs.MaybeCurrentRepository(ctx); err != nil {
-		slog.Warn("Could not determine current repository.", "error", err)
-	} else {
-		n := state.NewRepoName(repo)
-		if _, ok := repos[n]; !ok {
-			repos[n] = repo
-		}
-	}
+	addCurrentRepository(ctx, vcs, repos)
 
 	var suggestions []string
 	for name, repo := range repos {
@@ -75,20 +81,28 @@ func suggestWorkUnitNames(ctx context.Context, toComplete string) []string {
 }
 
 func update(ctx context.Context) error {
-	vcs := api.Registered()
+	vcs := registered()
 	curRepo, err := vcs.CurrentRepository(ctx)
 	if err != nil {
 		return err
 	}
+	srv, isCurrent := selectedServer(ctx)
+	// Another process might be changing the repository or tmux, so don't look at
+	// either of them until it's done.
+	if err := lockServer(ctx, srv); err != nil {
+		return err
+	}
+	if err := lockRepos(ctx, curRepo); err != nil {
+		return err
+	}
 	curWorkUnit, err := curRepo.Current(ctx)
 	if err != nil {
 		return fmt.Errorf("couldn't check repo's current %s: %w", curRepo.VCS().WorkUnitName(), err)
 	}
-	curSesh := tmux.MaybeCurrentSession()
-	if curSesh == nil {
-		// Executed outside of tmux. Attach to the proper tmux session.
-		srv := tmux.DefaultServer()
-		state, err := state.New(ctx, srv, vcs)
+	if !isCurrent {
+		// Executed outside of tmux, or for a different tmux server. Attach to the
+		// proper tmux session.
+		state, err := newState(ctx, srv, vcs)
 		if err != nil {
 			return err
 		}
@@ -96,19 +110,33 @@ func update(ctx context.Context) error {
 	}
 
 	// Executed within tmux. Update the repo state.
+	curSesh := tmux.MaybeCurrentSession()
 	nameProp, err := curSesh.Property(ctx, tmux.SessionName)
 	if err != nil {
 		return err
 	}
 	name := tmux.PropertyValue(tmux.SessionName, nameProp)
-	parsed := state.ParseSessionName(curRepo, name)
+	parsed, ok := sessionNaming(cfg).ParseSessionName(curRepo, name)
+	if !ok {
+		// Long session names are truncated, and can only be resolved by checking
+		// all of the repository's work units.
+		st, err := newState(ctx, curSesh.Server(), vcs)
+		if err != nil {
+			return err
+		}
+		_, wu, err := st.WorkUnit(ctx, curSesh)
+		if err != nil {
+			return fmt.Errorf("tmux session %q doesn't match the naming templates (see migrate-names): %w", name, err)
+		}
+		parsed = state.NewWorkUnitName(curRepo, wu)
+	}
 	if curWorkUnit != parsed.WorkUnit {
 		slog.Info("Updating repository.", "current", curWorkUnit, "want", parsed.WorkUnit)
 		return curRepo.Update(ctx, parsed.WorkUnit)
 	}
 	slog.Info("No update needed.")
 	if failNoop {
-		os.Exit(1)
+		pendingExitCode = 1
 	}
 	return nil
 }
@@ -122,22 +150,50 @@ func updateTmux(ctx context.Context, st *state.State, repo api.Repository, workU
 			return err
 		}
 	}
-	var err error
+	err := unlock()
 	if endTrace {
 		// Attaching to a session hangs until the client is detached.
-		err = stopTrace()
+		err = errors.Join(err, stopTrace())
 	}
 	return errors.Join(sesh.Server().AttachOrSwitch(ctx, sesh), err)
 }
 
-func updateTo(ctx context.Context, workUnitName string) error {
-	vcs := api.Registered()
-	srv := tmux.MaybeCurrentServer()
-	hasCurrentServer := srv != nil
-	if !hasCurrentServer {
-		srv = tmux.DefaultServer()
+// currentState determines the state of the tmux server given by --socket-name
+// or --socket-path, or else the current tmux server, or else the default tmux
+// server if this isn't being executed in tmux.
+func currentState(ctx context.Context, vcs api.VersionControlSystems) (st *state.State, hasCurrentServer bool, err error) {
+	srv, hasCurrentServer := selectedServer(ctx)
+	st, err = newState(ctx, srv, vcs)
+	return st, hasCurrentServer, err
+}
+
+// lockedState is like currentState, but it waits for other tmux-vcs-sync
+// processes to finish changing the tmux server first.
+func lockedState(ctx context.Context, vcs api.VersionControlSystems) (st *state.State, hasCurrentServer bool, err error) {
+	srv, hasCurrentServer := selectedServer(ctx)
+	if err := lockServer(ctx, srv); err != nil {
+		return nil, false, err
 	}
-	st, err := state.New(ctx, srv, vcs)
+	st, err = newState(ctx, srv, vcs)
+	return st, hasCurrentServer, err
+}
+
+// addCurrentRepository adds the repository in the working directory, if any, to
+// repos.
+func addCurrentRepository(ctx context.Context, vcs api.VersionControlSystems, repos map[state.RepoName]api.Repository) {
+	if repo, err := vcs.MaybeCurrentRepository(ctx); err != nil {
+		slog.Warn("Could not determine current repository.", "error", err)
+	} else if repo != nil {
+		n := state.NewRepoName(repo)
+		if _, ok := repos[n]; !ok {
+			repos[n] = repo
+		}
+	}
+}
+
+func updateTo(ctx context.Context, workUnitName string) error {
+	vcs := registered()
+	st, hasCurrentServer, err := lockedState(ctx, vcs)
 	if err != nil {
 		return err
 	}
@@ -159,7 +215,45 @@ func updateTo(ctx context.Context, workUnitName string) error {
 		}
 	}
 	slog.Info("Found repository for requested work unit.", "name", state.NewWorkUnitName(repo, workUnitName))
+	return updateToWorkUnit(ctx, st, hasCurrentServer, repo, workUnitName)
+}
 
+// updateToSession updates to the work unit that the tmux session with the given
+// ID represents.
+func updateToSession(ctx context.Context, id string) error {
+	st, hasCurrentServer, err := lockedState(ctx, registered())
+	if err != nil {
+		return err
+	}
+	sesh, err := sessionByID(ctx, st.Server(), id)
+	if err != nil {
+		return err
+	}
+	if repo, workUnitName, err := st.WorkUnit(ctx, sesh); err == nil {
+		if ok, err := repo.Exists(ctx, workUnitName); err != nil {
+			return err
+		} else if ok {
+			return updateToWorkUnit(ctx, st, hasCurrentServer, repo, workUnitName)
+		}
+	}
+	// There's no work unit to update to, but we can still switch to the session.
+	slog.Info("tmux session does not have a work unit.", "id", id)
+	err = unlock()
+	if !hasCurrentServer {
+		// Attaching to a session hangs until the client is detached.
+		err = errors.Join(err, stopTrace())
+	}
+	return errors.Join(st.Server().AttachOrSwitch(ctx, sesh), err)
+}
+
+// updateToWorkUnit updates both repo and tmux to point to the given work unit.
+func updateToWorkUnit(ctx context.Context, st *state.State, hasCurrentServer bool, repo api.Repository, workUnitName string) error {
+	if err := lockServer(ctx, st.Server()); err != nil {
+		return err
+	}
+	if err := lockRepos(ctx, repo); err != nil {
+		return err
+	}
 	var update bool
 
 	// Update to the work unit.
@@ -197,7 +291,7 @@ func updateTo(ctx context.Context, workUnitName string) error {
 	}
 	slog.Info("No update needed.")
 	if failNoop {
-		os.Exit(1)
+		pendingExitCode = 1
 	}
 	return nil
 }
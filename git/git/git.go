@@ -2,21 +2,26 @@ package git
 
 import (
 	"bufio"
+	"bytes"
 	"cmp"
 	"context"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log/slog"
 	"os"
 	"path/filepath"
 	"regexp"
 	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/JeffFaer/go-stdlib-ext/morecmp"
 	"github.com/JeffFaer/tmux-vcs-sync/api"
 	"github.com/JeffFaer/tmux-vcs-sync/api/exec"
+	"github.com/avast/retry-go/v4"
 )
 
 var errUnstableRepoState = fmt.Errorf("unable to determine branch name (is the repo in an unstable state?)")
@@ -73,6 +78,53 @@ func (repo *gitRepo) Command(ctx context.Context, args ...string) *exec.Command
 	return repo.git.Command(ctx, args...)
 }
 
+// readCommand is like Command, but for git commands that only read the
+// repository. They won't take optional locks (like the one for refreshing the
+// index), so they can't make concurrent git commands fail.
+func (repo *gitRepo) readCommand(ctx context.Context, args ...string) *exec.Command {
+	return repo.Command(ctx, append([]string{"--no-optional-locks"}, args...)...)
+}
+
+// errLocked indicates that a git command failed because another git process
+// was holding one of the repository's locks.
+var errLocked = errors.New("repository is locked by another git process")
+
+// lockedRegex matches git's error messages for locks that are already held.
+// Other reasons that git can't lock something, like a branch that conflicts with
+// an existing one or a permissions problem, won't go away by retrying.
+var lockedRegex = regexp.MustCompile(`Unable to create '[^']+\.lock': File exists|could not lock config file .+: File exists`)
+
+// run runs a git command that changes the repository. If the command fails
+// because another git process is holding one of the repository's locks, it's
+// retried with backoff.
+func (repo *gitRepo) run(ctx context.Context, args ...string) error {
+	return retry.Do(
+		func() error {
+			cmd := repo.Command(ctx, args...)
+			var stderr bytes.Buffer
+			if cmd.Stderr == nil {
+				cmd.Stderr = &stderr
+			} else {
+				cmd.Stderr = io.MultiWriter(cmd.Stderr, &stderr)
+			}
+			err := cmd.Run()
+			if err != nil && lockedRegex.Match(stderr.Bytes()) {
+				return fmt.Errorf("%w: %w", errLocked, err)
+			}
+			return err
+		},
+		retry.Context(ctx),
+		retry.Attempts(10),
+		retry.Delay(50*time.Millisecond),
+		retry.MaxDelay(time.Second),
+		retry.LastErrorOnly(true),
+		retry.RetryIf(func(err error) bool { return errors.Is(err, errLocked) }),
+		retry.OnRetry(func(n uint, err error) {
+			slog.Info("Retrying git command.", "attempt", n+1, "error", err)
+		}),
+	)
+}
+
 func (repo *gitRepo) VCS() api.VersionControlSystem {
 	return repo.git
 }
@@ -129,7 +181,7 @@ func (repo *gitRepo) RootDir() string {
 }
 
 func (repo *gitRepo) Current(ctx context.Context) (string, error) {
-	cur, err := repo.Command(ctx, "rev-parse", "--abbrev-ref", "HEAD").RunStdout()
+	cur, err := repo.readCommand(ctx, "rev-parse", "--abbrev-ref", "HEAD").RunStdout()
 	if err != nil {
 		return "", err
 	}
@@ -144,7 +196,7 @@ func (repo *gitRepo) List(ctx context.Context, prefix string) ([]string, error)
 	if prefix != "" {
 		args = append(args, prefix+"*")
 	}
-	stdout, err := repo.Command(ctx, args...).RunStdout()
+	stdout, err := repo.readCommand(ctx, args...).RunStdout()
 	if err != nil {
 		return nil, err
 	}
@@ -171,7 +223,7 @@ func (repo *gitRepo) Sort(ctx context.Context, workUnits []string) error {
 	// sorted correctly in the output.
 	slices.SortFunc(workUnits, morecmp.CmpFunc[string](cmp.Compare[string]).Reversed())
 	args = append(args, workUnits...)
-	cmd := repo.Command(ctx, args...)
+	cmd := repo.readCommand(ctx, args...)
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
 		return err
@@ -228,13 +280,12 @@ func (repo *gitRepo) Sort(ctx context.Context, workUnits []string) error {
 	return nil
 }
 
+// keyBranchByHash groups the given branches by the commit they point to.
+// If no branches are given, all branches are included.
 func (repo *gitRepo) keyBranchByHash(ctx context.Context, branches []string) (map[string][]string, error) {
-	if len(branches) == 0 {
-		return nil, nil
-	}
 	args := []string{"branch", "--list", "--format=%(refname:short) %(objectname)"}
 	args = append(args, branches...)
-	stdout, err := repo.Command(ctx, args...).RunStdout()
+	stdout, err := repo.readCommand(ctx, args...).RunStdout()
 	if err != nil {
 		return nil, fmt.Errorf("could not get branch hashes: %w", err)
 	}
@@ -249,12 +300,369 @@ func (repo *gitRepo) keyBranchByHash(ctx context.Context, branches []string) (ma
 	return ret, nil
 }
 
+// Parents finds the closest branch in each branch's first-parent history.
+// Branches that point to the same commit are ordered the same way as Sort
+// orders them: the default branch first, then by name. Branches that don't have
+// another branch in their history since they diverged from the default branch
+// are based on the default branch.
+func (repo *gitRepo) Parents(ctx context.Context) (map[string]string, error) {
+	defaultBranch, err := repo.defaultBranchName(ctx)
+	if err != nil {
+		return nil, err
+	}
+	branchesByHash, err := repo.keyBranchByHash(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	isDefault := func(name string) bool { return name == defaultBranch }
+	for _, branches := range branchesByHash {
+		slices.Sort(branches)
+		slices.SortStableFunc(branches, morecmp.ComparingFunc(isDefault, morecmp.TrueFirst()))
+	}
+
+	// Find the first parent of every commit that isn't in the default branch, all
+	// at once. The default branch's history can be arbitrarily long, but a
+	// branch's parent is either in the branch's own history or where it diverged
+	// from the default branch.
+	stdout, err := repo.readCommand(ctx, "rev-list", "--first-parent", "--parents", "--branches", "^refs/heads/"+defaultBranch, "--").RunStdout()
+	if err != nil {
+		return nil, fmt.Errorf("could not search for parents: %w", err)
+	}
+	firstParents := make(map[string]string)
+	for _, line := range strings.Split(stdout, "\n") {
+		if commit, parents, ok := strings.Cut(line, " "); ok {
+			parent, _, _ := strings.Cut(parents, " ")
+			firstParents[commit] = parent
+		}
+	}
+
+	ret := make(map[string]string)
+	for hash, branches := range branchesByHash {
+		for i, b := range branches {
+			switch {
+			case b == defaultBranch:
+				ret[b] = ""
+			case i > 0:
+				// Branches that share a commit are based on the ones that come before
+				// them.
+				ret[b] = branches[i-1]
+			default:
+				ret[b] = defaultBranch
+				for p, ok := firstParents[hash]; ok; p, ok = firstParents[p] {
+					if bs := branchesByHash[p]; len(bs) > 0 {
+						ret[b] = bs[len(bs)-1]
+						break
+					}
+				}
+			}
+		}
+	}
+	return ret, nil
+}
+
+// Status reports whether each of the given branches has uncommitted changes,
+// and how far it is ahead of or behind its upstream branch.
+func (repo *gitRepo) Status(ctx context.Context, workUnitNames []string) (map[string]api.WorkUnitStatus, error) {
+	ret := make(map[string]api.WorkUnitStatus)
+	if len(workUnitNames) == 0 {
+		return ret, nil
+	}
+	args := []string{"for-each-ref", "--format=%(refname) %(upstream:track,nobracket)"}
+	for _, wu := range workUnitNames {
+		args = append(args, "refs/heads/"+wu)
+	}
+	stdout, err := repo.readCommand(ctx, args...).RunStdout()
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(stdout, "\n") {
+		ref, track, _ := strings.Cut(line, " ")
+		// for-each-ref's patterns also match the branches nested under them.
+		name, ok := strings.CutPrefix(ref, "refs/heads/")
+		if !ok || !slices.Contains(workUnitNames, name) {
+			continue
+		}
+		var status api.WorkUnitStatus
+		// e.g. "ahead 1, behind 2" or "gone".
+		for _, part := range strings.Split(track, ", ") {
+			if n, ok := strings.CutPrefix(part, "ahead "); ok {
+				status.Ahead, err = strconv.Atoi(n)
+			} else if n, ok := strings.CutPrefix(part, "behind "); ok {
+				status.Behind, err = strconv.Atoi(n)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("could not parse upstream tracking info %q of branch %q: %w", track, name, err)
+			}
+		}
+		ret[name] = status
+	}
+	for _, wu := range workUnitNames {
+		if _, ok := ret[wu]; !ok {
+			return nil, fmt.Errorf("branch %q does not exist", wu)
+		}
+	}
+
+	// Only the checked out branch can have uncommitted changes.
+	cur, err := repo.Current(ctx)
+	if err != nil && !errors.Is(err, errUnstableRepoState) {
+		return nil, err
+	}
+	if status, ok := ret[cur]; ok {
+		changes, err := repo.readCommand(ctx, "status", "--porcelain", "--untracked-files=no").RunStdout()
+		if err != nil {
+			return nil, err
+		}
+		status.Dirty = changes != ""
+		ret[cur] = status
+	}
+	return ret, nil
+}
+
+// Merged finds the branches whose changes are in the default branch, either
+// because they were merged into it, or because they were squashed into a
+// single commit on it.
+// Branches that are in the default branch's first-parent history were created
+// from it and don't have any changes yet, so they aren't considered merged.
+// That includes branches that were fast-forwarded into the default branch.
+// Branches with several commits that were rebased onto the default branch, or
+// squashed into more than one commit on it, aren't detected either.
+func (repo *gitRepo) Merged(ctx context.Context) ([]string, error) {
+	trunk, err := repo.defaultBranchName(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := repo.readCommand(ctx, "branch", "--format=%(refname:short)", "--merged", trunk).RunStdout()
+	if err != nil {
+		return nil, err
+	}
+	merged := make(map[string]bool)
+	for _, b := range strings.Split(stdout, "\n") {
+		if b != "" {
+			merged[b] = true
+		}
+	}
+	branchesByHash, err := repo.keyBranchByHash(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []string
+	var unmerged []string
+	for hash, branches := range branchesByHash {
+		if slices.Contains(branches, trunk) {
+			continue
+		}
+		if !merged[branches[0]] {
+			unmerged = append(unmerged, hash)
+			continue
+		}
+		onTrunk, err := repo.inFirstParentHistory(ctx, trunk, hash)
+		if err != nil {
+			return nil, fmt.Errorf("could not check whether branch %q was merged into %q: %w", branches[0], trunk, err)
+		}
+		if !onTrunk {
+			ret = append(ret, branches...)
+		}
+	}
+	squashed, err := repo.squashMerged(ctx, trunk, unmerged)
+	if err != nil {
+		return nil, fmt.Errorf("could not check whether branches were squashed into %q: %w", trunk, err)
+	}
+	for _, hash := range squashed {
+		ret = append(ret, branchesByHash[hash]...)
+	}
+	slices.Sort(ret)
+	return ret, nil
+}
+
+// inFirstParentHistory determines whether commit, which must be in trunk's
+// history, is also in its first-parent history. Those commits were made on
+// trunk itself instead of being merged into it.
+func (repo *gitRepo) inFirstParentHistory(ctx context.Context, trunk, commit string) (bool, error) {
+	stdout, err := repo.readCommand(ctx, "rev-list", "--first-parent", "--parents", trunk, "^"+commit, "--").RunStdout()
+	if err != nil {
+		return false, err
+	}
+	for _, line := range strings.Split(stdout, "\n") {
+		if parents := strings.Fields(line); len(parents) > 1 && parents[1] == commit {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// squashMerged finds the commits whose changes since they diverged from trunk
+// were applied to trunk as a single commit.
+func (repo *gitRepo) squashMerged(ctx context.Context, trunk string, commits []string) ([]string, error) {
+	want := make(map[string]string)
+	var bases []string
+	for _, commit := range commits {
+		base, err := repo.readCommand(ctx, "merge-base", trunk, commit).RunStdout()
+		if err != nil {
+			return nil, err
+		}
+		diff, err := repo.readCommand(ctx, "diff", "--no-color", "--no-ext-diff", base, commit, "--").RunStdout()
+		if err != nil {
+			return nil, err
+		}
+		if diff == "" {
+			continue
+		}
+		ids, err := repo.patchIDs(ctx, diff)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			want[id] = commit
+		}
+		bases = append(bases, base)
+	}
+	if len(want) == 0 {
+		return nil, nil
+	}
+
+	// trunk's changes since the oldest base include the changes since every
+	// other base, so trunk's history only needs to be read once.
+	oldest := bases[0]
+	if len(bases) > 1 {
+		var err error
+		oldest, err = repo.readCommand(ctx, append([]string{"merge-base", "--octopus"}, bases...)...).RunStdout()
+		if err != nil {
+			return nil, err
+		}
+	}
+	log, err := repo.readCommand(ctx, "log", "--no-color", "--no-ext-diff", "--patch", oldest+".."+trunk, "--").RunStdout()
+	if err != nil {
+		return nil, err
+	}
+	got, err := repo.patchIDs(ctx, log)
+	if err != nil {
+		return nil, err
+	}
+	var ret []string
+	for _, id := range got {
+		if commit, ok := want[id]; ok {
+			ret = append(ret, commit)
+		}
+	}
+	slices.Sort(ret)
+	return slices.Compact(ret), nil
+}
+
+// patchIDs computes the git patch-ids of the patches in s.
+func (repo *gitRepo) patchIDs(ctx context.Context, s string) ([]string, error) {
+	cmd := repo.Command(ctx, "patch-id", "--stable")
+	cmd.Stdin = strings.NewReader(s + "\n")
+	stdout, err := cmd.RunStdout()
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, line := range strings.Split(stdout, "\n") {
+		if id, _, ok := strings.Cut(line, " "); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (repo *gitRepo) Log(ctx context.Context, workUnitName string, n int) ([]string, error) {
+	if !repo.branchExists(ctx, workUnitName) {
+		return nil, fmt.Errorf("branch %q does not exist", workUnitName)
+	}
+	stdout, err := repo.readCommand(ctx, "log", "--format=%h %s", fmt.Sprintf("--max-count=%d", n), "refs/heads/"+workUnitName, "--").RunStdout()
+	if err != nil {
+		return nil, err
+	}
+	if stdout == "" {
+		return nil, nil
+	}
+	return strings.Split(stdout, "\n"), nil
+}
+
+// LastChanged returns the commit date of the branch's latest commit.
+func (repo *gitRepo) LastChanged(ctx context.Context, workUnitName string) (time.Time, error) {
+	if !repo.branchExists(ctx, workUnitName) {
+		return time.Time{}, fmt.Errorf("branch %q does not exist", workUnitName)
+	}
+	stdout, err := repo.readCommand(ctx, "log", "--format=%ct", "--max-count=1", "refs/heads/"+workUnitName, "--").RunStdout()
+	if err != nil {
+		return time.Time{}, err
+	}
+	sec, err := strconv.ParseInt(stdout, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("could not parse commit date of branch %q: %w", workUnitName, err)
+	}
+	return time.Unix(sec, 0), nil
+}
+
+const (
+	// hookMarker identifies git hooks that were installed by this tool.
+	hookMarker = "# Installed by tmux-vcs-sync."
+	// internalConfig is set for checkouts made by this tool, so that its hooks
+	// can ignore them.
+	internalConfig = "tmux-vcs-sync.internal"
+)
+
+func (repo *gitRepo) InstallCheckoutHook(ctx context.Context, command string, force bool) error {
+	// --git-path respects core.hooksPath.
+	path, err := repo.Command(ctx, "rev-parse", "--git-path", "hooks/post-checkout").RunStdout()
+	if err != nil {
+		return err
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(repo.rootDir, path)
+	}
+	if b, err := os.ReadFile(path); err == nil {
+		if !force && !strings.Contains(string(b), hookMarker) {
+			return fmt.Errorf("%s: %w", path, fs.ErrExist)
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	// post-checkout's third argument is 1 for branch checkouts, and 0 for file
+	// checkouts.
+	hook := fmt.Sprintf(`#!/bin/sh
+%s
+[ "$3" = 1 ] || exit 0
+[ "$(git config --bool %s)" = true ] && exit 0
+%s
+`, hookMarker, internalConfig, command)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	slog.Info("Installing git hook.", "path", path)
+	if err := os.WriteFile(path, []byte(hook), 0755); err != nil {
+		return err
+	}
+	// WriteFile keeps the mode of a hook that's being replaced, but git won't
+	// run hooks that aren't executable.
+	return os.Chmod(path, 0755)
+}
+
+func (repo *gitRepo) StateFiles(ctx context.Context) ([]string, error) {
+	// --git-path accounts for worktrees, whose HEAD is separate from the shared
+	// refs.
+	stdout, err := repo.Command(ctx, "rev-parse", "--git-path", "HEAD", "--git-path", "packed-refs", "--git-path", "refs/heads").RunStdout()
+	if err != nil {
+		return nil, err
+	}
+	paths := strings.Split(stdout, "\n")
+	for i, p := range paths {
+		if !filepath.IsAbs(p) {
+			paths[i] = filepath.Join(repo.rootDir, p)
+		}
+	}
+	return paths, nil
+}
+
 func (repo *gitRepo) New(ctx context.Context, workUnitName string) error {
 	n, err := repo.defaultBranchName(ctx)
 	if err != nil {
 		return err
 	}
-	return repo.Command(ctx, "checkout", "-b", workUnitName, n).Run()
+	return repo.checkout(ctx, "-b", workUnitName, n)
 }
 
 // defaultBranch name attempts to determine the default branch name of this repository.
@@ -274,7 +682,7 @@ func (repo *gitRepo) defaultBranchName(ctx context.Context) (string, error) {
 }
 
 func (repo *gitRepo) configValue(ctx context.Context, key string) (string, error) {
-	stdout, stderr, err := repo.Command(ctx, "config", key).RunOutput()
+	stdout, stderr, err := repo.readCommand(ctx, "config", key).RunOutput()
 	if err != nil {
 		if stderr == "" {
 			return "", nil
@@ -287,16 +695,16 @@ func (repo *gitRepo) configValue(ctx context.Context, key string) (string, error
 
 // branchExists determines whether a branch exists in the this repository.
 func (repo *gitRepo) branchExists(ctx context.Context, name string) bool {
-	err := repo.Command(ctx, "show-ref", "--verify", "--quiet", fmt.Sprintf("refs/heads/%s", name)).Run()
+	err := repo.readCommand(ctx, "show-ref", "--verify", "--quiet", fmt.Sprintf("refs/heads/%s", name)).Run()
 	return err == nil
 }
 
 func (repo *gitRepo) Commit(ctx context.Context, workUnitName string) error {
-	return repo.Command(ctx, "checkout", "-b", workUnitName).Run()
+	return repo.checkout(ctx, "-b", workUnitName)
 }
 
 func (repo *gitRepo) Rename(ctx context.Context, workUnitName string) error {
-	return repo.Command(ctx, "branch", "-m", workUnitName).Run()
+	return repo.run(ctx, "branch", "-m", workUnitName)
 }
 
 func (repo *gitRepo) Exists(ctx context.Context, workUnitName string) (bool, error) {
@@ -304,5 +712,15 @@ func (repo *gitRepo) Exists(ctx context.Context, workUnitName string) (bool, err
 }
 
 func (repo *gitRepo) Update(ctx context.Context, workUnitName string) error {
-	return repo.Command(ctx, "checkout", workUnitName).Run()
+	return repo.checkout(ctx, workUnitName)
+}
+
+// checkout runs git checkout in a way that this tool's hooks ignore.
+func (repo *gitRepo) checkout(ctx context.Context, args ...string) error {
+	return repo.run(ctx, append([]string{"-c", internalConfig + "=true", "checkout"}, args...)...)
+}
+
+func (repo *gitRepo) Delete(ctx context.Context, workUnitName string) error {
+	// git refuses to delete the checked out branch on its own.
+	return repo.run(ctx, "branch", "--delete", "--force", workUnitName)
 }
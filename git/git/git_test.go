@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"slices"
@@ -277,6 +278,407 @@ func TestSort_DuplicateBranch(t *testing.T) {
 	}
 }
 
+func TestParent_SameCommit(t *testing.T) {
+	addBranch := func(name string) initStep {
+		return repoCommand{args: []string{"branch", name}}
+	}
+
+	git := newGit(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	repo, err := git.newRepo(ctx, t.TempDir(), t.Name(), []initStep{addBranch("foo"), addBranch("bar")})
+	if err != nil {
+		t.Fatalf("Could not create repo: %v", err)
+	}
+
+	// Branches that point to the same commit are stacked in the same order that
+	// Sort uses.
+	want := map[string]string{
+		defaultBranchName: "",
+		"bar":             defaultBranchName,
+		"foo":             "bar",
+	}
+	if got, err := repo.Parents(ctx); err != nil {
+		t.Errorf("repo.Parents() = _, %v", err)
+	} else if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("repo.Parents() diff (-want +got)\n%s", diff)
+	}
+}
+
+func TestParents_DefaultBranchMoved(t *testing.T) {
+	checkoutNewBranch := func(name string, parent string) initStep {
+		return repoCommand{args: []string{"checkout", "-b", name, parent}}
+	}
+	commit := func(msg string) initStep {
+		return repoCommand{args: []string{"commit", "--allow-empty", "--message", msg}}
+	}
+
+	git := newGit(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	repo, err := git.newRepo(ctx, t.TempDir(), t.Name(), []initStep{
+		checkoutNewBranch("foo", defaultBranchName),
+		commit("foo commit"),
+		checkoutNewBranch("bar", "foo"),
+		commit("bar commit"),
+		repoCommand{args: []string{"checkout", defaultBranchName}},
+		commit("main commit"),
+	})
+	if err != nil {
+		t.Fatalf("Could not create repo: %v", err)
+	}
+
+	// foo is still based on the default branch, even though the default branch's
+	// tip isn't in its history anymore.
+	want := map[string]string{
+		defaultBranchName: "",
+		"foo":             defaultBranchName,
+		"bar":             "foo",
+	}
+	if got, err := repo.Parents(ctx); err != nil {
+		t.Errorf("repo.Parents() = _, %v", err)
+	} else if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("repo.Parents() diff (-want +got)\n%s", diff)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	checkoutNewBranch := func(name string, parent string) initStep {
+		return repoCommand{args: []string{"checkout", "-b", name, parent}}
+	}
+	setUpstream := func(upstream string) initStep {
+		return repoCommand{args: []string{"branch", "--set-upstream-to", upstream}}
+	}
+	add := func(file string) initStep {
+		return repoCommand{args: []string{"add", file}}
+	}
+	commit := func(msg string) initStep {
+		return repoCommand{args: []string{"commit", "--allow-empty", "--message", msg}}
+	}
+	for _, tc := range []struct {
+		name string
+
+		init   []initStep
+		branch string
+
+		want api.WorkUnitStatus
+	}{
+		{
+			name:   "Clean",
+			branch: defaultBranchName,
+		},
+		{
+			name: "Dirty",
+			init: []initStep{
+				newFile{"README", "abc"},
+				add("README"),
+			},
+			branch: defaultBranchName,
+			want:   api.WorkUnitStatus{Dirty: true},
+		},
+		{
+			name: "UntrackedFilesAreClean",
+			init: []initStep{
+				newFile{"README", "abc"},
+			},
+			branch: defaultBranchName,
+		},
+		{
+			name: "OnlyCurrentBranchIsDirty",
+			init: []initStep{
+				checkoutNewBranch("branch", defaultBranchName),
+				newFile{"README", "abc"},
+				add("README"),
+			},
+			branch: defaultBranchName,
+		},
+		{
+			name: "AheadAndBehind",
+			init: []initStep{
+				checkoutNewBranch("branch", defaultBranchName),
+				setUpstream(defaultBranchName),
+				commit("branch commit 1"),
+				commit("branch commit 2"),
+				repoCommand{args: []string{"checkout", defaultBranchName}},
+				commit("main commit"),
+			},
+			branch: "branch",
+			want:   api.WorkUnitStatus{Ahead: 2, Behind: 1},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			git := newGit(t)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			repo, err := git.newRepo(ctx, t.TempDir(), tc.name, tc.init)
+			if err != nil {
+				t.Fatalf("Could not create repo: %v", err)
+			}
+
+			got, err := repo.Status(ctx, []string{tc.branch})
+			if err != nil {
+				t.Errorf("repo.Status(%q) = _, %v", tc.branch, err)
+			}
+			if diff := cmp.Diff(tc.want, got[tc.branch]); diff != "" {
+				t.Errorf("repo.Status(%q) diff (-want +got)\n%s", tc.branch, diff)
+			}
+		})
+	}
+}
+
+func TestMerged(t *testing.T) {
+	checkoutNewBranch := func(name string) initStep {
+		return repoCommand{args: []string{"checkout", "-b", name, defaultBranchName}}
+	}
+	checkout := func(name string) initStep {
+		return repoCommand{args: []string{"checkout", name}}
+	}
+	commitFile := func(path, content string) []initStep {
+		return []initStep{
+			newFile{path, content},
+			repoCommand{args: []string{"add", path}},
+			repoCommand{args: []string{"commit", "--message", "Change " + path}},
+		}
+	}
+	var init []initStep
+	// merged is merged normally.
+	init = append(init, checkoutNewBranch("merged"))
+	init = append(init, commitFile("merged", "merged")...)
+	// squashed has two commits that are squashed into one.
+	init = append(init, checkoutNewBranch("squashed"))
+	init = append(init, commitFile("squashed", "a")...)
+	init = append(init, commitFile("squashed", "b")...)
+	// unmerged is never merged.
+	init = append(init, checkoutNewBranch("unmerged"))
+	init = append(init, commitFile("unmerged", "unmerged")...)
+	init = append(init,
+		checkout(defaultBranchName),
+		// stale was created before the default branch moved on, and doesn't have
+		// any changes.
+		repoCommand{args: []string{"branch", "stale"}},
+		repoCommand{args: []string{"merge", "--no-ff", "--message", "Merge", "merged"}},
+		repoCommand{args: []string{"merge", "--squash", "squashed"}},
+		repoCommand{args: []string{"commit", "--message", "Squash"}},
+	)
+	// squashedToo diverges from the default branch after the others.
+	init = append(init, checkoutNewBranch("squashedToo"))
+	init = append(init, commitFile("squashedToo", "squashedToo")...)
+	init = append(init,
+		checkout(defaultBranchName),
+		repoCommand{args: []string{"merge", "--squash", "squashedToo"}},
+		repoCommand{args: []string{"commit", "--message", "Squash too"}},
+	)
+	init = append(init, commitFile("trunk", "trunk")...)
+	// fresh doesn't have any changes yet.
+	init = append(init, repoCommand{args: []string{"branch", "fresh"}})
+
+	git := newGit(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	repo, err := git.newRepo(ctx, t.TempDir(), t.Name(), init)
+	if err != nil {
+		t.Fatalf("Could not create repo: %v", err)
+	}
+
+	got, err := repo.Merged(ctx)
+	if err != nil {
+		t.Errorf("repo.Merged() = _, %v", err)
+	}
+	if diff := cmp.Diff([]string{"merged", "squashed", "squashedToo"}, got); diff != "" {
+		t.Errorf("repo.Merged() diff (-want +got)\n%s", diff)
+	}
+}
+
+func TestLastChanged(t *testing.T) {
+	git := newGit(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// Commit dates only have second precision.
+	before := time.Now().Truncate(time.Second)
+	repo, err := git.newRepo(ctx, t.TempDir(), t.Name(), []initStep{
+		repoCommand{args: []string{"checkout", "-b", "feature"}},
+		repoCommand{args: []string{"commit", "--allow-empty", "--message", "Change"}},
+	})
+	if err != nil {
+		t.Fatalf("Could not create repo: %v", err)
+	}
+	after := time.Now()
+
+	got, err := repo.LastChanged(ctx, "feature")
+	if err != nil {
+		t.Errorf("repo.LastChanged(%q) = _, %v", "feature", err)
+	}
+	if got.Before(before) || got.After(after) {
+		t.Errorf("repo.LastChanged(%q) = %v, want between %v and %v", "feature", got, before, after)
+	}
+	if _, err := repo.LastChanged(ctx, "missing"); err == nil {
+		t.Errorf("repo.LastChanged(%q) = _, nil, want an error", "missing")
+	}
+}
+
+func TestInstallCheckoutHook(t *testing.T) {
+	git := newGit(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	dir := t.TempDir()
+	repo, err := git.newRepo(ctx, dir, t.Name(), []initStep{
+		newFile{"README", "abc"},
+		repoCommand{args: []string{"add", "README"}},
+		repoCommand{args: []string{"commit", "--message", "Add README"}},
+		repoCommand{args: []string{"branch", "feature"}},
+	})
+	if err != nil {
+		t.Fatalf("Could not create repo: %v", err)
+	}
+	out := filepath.Join(t.TempDir(), "out")
+	if err := repo.InstallCheckoutHook(ctx, "echo \"$(git branch --show-current)\" >>"+out, false); err != nil {
+		t.Fatalf("repo.InstallCheckoutHook() = %v", err)
+	}
+	// Reinstalling replaces the hook.
+	if err := repo.InstallCheckoutHook(ctx, "echo \"$(git branch --show-current)\" >>"+out, false); err != nil {
+		t.Fatalf("repo.InstallCheckoutHook() = %v", err)
+	}
+
+	for _, args := range [][]string{
+		{"checkout", "feature"},
+		// File checkouts don't run the command.
+		{"checkout", "feature", "--", "README"},
+	} {
+		if err := repo.Command(ctx, args...).Run(); err != nil {
+			t.Fatalf("git %q = %v", args, err)
+		}
+	}
+	// Nor do checkouts made by this tool.
+	if err := repo.Update(ctx, defaultBranchName); err != nil {
+		t.Fatalf("repo.Update(%q) = %v", defaultBranchName, err)
+	}
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "feature\n"; string(got) != want {
+		t.Errorf("Checkout hook output = %q, want %q", got, want)
+	}
+
+	hook := filepath.Join(dir, t.Name(), ".git", "hooks", "post-checkout")
+	// The other hook isn't executable, so git wouldn't run it.
+	if err := os.WriteFile(hook, []byte("#!/bin/sh\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.InstallCheckoutHook(ctx, "true", false); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("repo.InstallCheckoutHook() = %v, want fs.ErrExist for another hook", err)
+	}
+	if err := repo.InstallCheckoutHook(ctx, "true", true); err != nil {
+		t.Errorf("repo.InstallCheckoutHook(force) = %v", err)
+	}
+	if fi, err := os.Stat(hook); err != nil {
+		t.Error(err)
+	} else if fi.Mode().Perm()&0111 == 0 {
+		t.Errorf("Replaced hook's mode = %v, want it to be executable", fi.Mode())
+	}
+}
+
+func TestStateFiles(t *testing.T) {
+	git := newGit(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	dir := t.TempDir()
+	repo, err := git.newRepo(ctx, dir, t.Name(), nil)
+	if err != nil {
+		t.Fatalf("Could not create repo: %v", err)
+	}
+
+	got, err := repo.StateFiles(ctx)
+	if err != nil {
+		t.Fatalf("repo.StateFiles() = _, %v", err)
+	}
+	gitDir := filepath.Join(dir, t.Name(), ".git")
+	want := []string{filepath.Join(gitDir, "HEAD"), filepath.Join(gitDir, "packed-refs"), filepath.Join(gitDir, "refs", "heads")}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("repo.StateFiles() diff (-want +got)\n%s", diff)
+	}
+}
+
+func TestUpdate_Locked(t *testing.T) {
+	addBranch := func(name string) initStep {
+		return repoCommand{args: []string{"branch", name}}
+	}
+
+	git := newGit(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	repo, err := git.newRepo(ctx, t.TempDir(), t.Name(), []initStep{addBranch("foo")})
+	if err != nil {
+		t.Fatalf("Could not create repo: %v", err)
+	}
+
+	// Pretend that another git process is holding the index lock for a bit.
+	lock := filepath.Join(repo.rootDir, ".git", "index.lock")
+	if err := os.WriteFile(lock, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	timer := time.AfterFunc(200*time.Millisecond, func() {
+		if err := os.Remove(lock); err != nil {
+			t.Error(err)
+		}
+	})
+	defer timer.Stop()
+
+	if cur, err := repo.Current(ctx); err != nil {
+		t.Errorf("repo.Current() = _, %v", err)
+	} else if cur != defaultBranchName {
+		t.Errorf("repo.Current() = %q, want %q", cur, defaultBranchName)
+	}
+	if err := repo.Update(ctx, "foo"); err != nil {
+		t.Errorf("repo.Update(%q) = %v", "foo", err)
+	}
+	if cur, err := repo.Current(ctx); err != nil {
+		t.Errorf("repo.Current() = _, %v", err)
+	} else if cur != "foo" {
+		t.Errorf("repo.Current() = %q, want %q", cur, "foo")
+	}
+}
+
+func TestLockedRegex(t *testing.T) {
+	for _, tc := range []struct {
+		stderr string
+		want   bool
+	}{
+		{"fatal: Unable to create '/repo/.git/index.lock': File exists.", true},
+		{"fatal: cannot lock ref 'refs/heads/foo': Unable to create '/repo/.git/refs/heads/foo.lock': File exists.", true},
+		{"error: could not lock config file .git/config: File exists", true},
+		{"fatal: cannot lock ref 'refs/heads/foo/bar': 'refs/heads/foo' exists; cannot create 'refs/heads/foo/bar'", false},
+		{"error: could not lock config file .git/config: Permission denied", false},
+	} {
+		if got := lockedRegex.MatchString(tc.stderr); got != tc.want {
+			t.Errorf("lockedRegex.MatchString(%q) = %t, want %t", tc.stderr, got, tc.want)
+		}
+	}
+}
+
+func TestCommit_RefConflict(t *testing.T) {
+	git := newGit(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	repo, err := git.newRepo(ctx, t.TempDir(), t.Name(), []initStep{repoCommand{args: []string{"branch", "foo"}}})
+	if err != nil {
+		t.Fatalf("Could not create repo: %v", err)
+	}
+
+	// foo/bar can't exist next to foo, so there's no point in retrying.
+	start := time.Now()
+	err = repo.Commit(ctx, "foo/bar")
+	if err == nil {
+		t.Fatalf("repo.Commit(foo/bar) = nil, want an error")
+	}
+	if errors.Is(err, errLocked) {
+		t.Errorf("repo.Commit(foo/bar) = %v, want it to fail without retrying", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("repo.Commit(foo/bar) took %v, want it to fail on the first attempt", d)
+	}
+}
+
 type initStep interface {
 	Run(context.Context, *testGitRepo) error
 	String() string
@@ -5,6 +5,7 @@ go 1.23
 require (
 	github.com/JeffFaer/go-stdlib-ext v0.2.0
 	github.com/JeffFaer/tmux-vcs-sync/api v0.0.0-20240314045224-4f466c92bafd
+	github.com/avast/retry-go/v4 v4.5.1
 	github.com/google/go-cmp v0.6.0
 	github.com/kballard/go-shellquote v0.0.0-20180428030007-95032a82bc51
 )
@@ -0,0 +1,107 @@
+// Package lock coordinates processes with advisory file locks.
+package lock
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// pollInterval is how often a lock that's held by another process is retried.
+const pollInterval = 10 * time.Millisecond
+
+// ErrBusy is returned when another process still holds a lock once the timeout
+// has passed.
+var ErrBusy = errors.New("another tmux-vcs-sync is running")
+
+// Locks are the locks held by this process. Each lock is identified by a key,
+// and is a file in a shared directory.
+type Locks struct {
+	dir     string
+	timeout time.Duration
+
+	files map[string]*os.File
+}
+
+// New creates a Locks that keeps its files in dir, and waits up to timeout
+// for other processes to release them.
+func New(dir string, timeout time.Duration) *Locks {
+	return &Locks{dir: dir, timeout: timeout, files: make(map[string]*os.File)}
+}
+
+// Acquire takes the locks for keys that aren't already held. The keys are
+// locked in sorted order, so processes that acquire overlapping keys in one
+// call can't deadlock.
+// If it can't acquire all of the locks, it doesn't keep any of them.
+func (l *Locks) Acquire(ctx context.Context, keys ...string) error {
+	keys = slices.Compact(slices.Sorted(slices.Values(keys)))
+	keys = slices.DeleteFunc(keys, func(k string) bool { return l.files[k] != nil })
+	timeout := time.NewTimer(l.timeout)
+	defer timeout.Stop()
+
+	acquired := make(map[string]*os.File)
+	for _, k := range keys {
+		f, err := l.acquire(ctx, timeout.C, k)
+		if err != nil {
+			var errs []error
+			for _, f := range acquired {
+				errs = append(errs, f.Close())
+			}
+			return errors.Join(append([]error{fmt.Errorf("could not lock %s: %w", k, err)}, errs...)...)
+		}
+		acquired[k] = f
+	}
+	for k, f := range acquired {
+		l.files[k] = f
+	}
+	return nil
+}
+
+func (l *Locks) acquire(ctx context.Context, timeout <-chan time.Time, key string) (*os.File, error) {
+	f, err := os.OpenFile(l.path(key), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		ok, err := tryLock(f)
+		if err != nil {
+			return nil, errors.Join(err, f.Close())
+		}
+		if ok {
+			return f, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, errors.Join(ctx.Err(), f.Close())
+		case <-timeout:
+			return nil, errors.Join(fmt.Errorf("%w: still waiting after %v", ErrBusy, l.timeout), f.Close())
+		case <-ticker.C:
+		}
+	}
+}
+
+// path is the file for key. Keys are hashed since they might be too long or
+// contain characters that can't be in file names.
+func (l *Locks) path(key string) string {
+	return filepath.Join(l.dir, fmt.Sprintf("%x.lock", sha256.Sum256([]byte(key))))
+}
+
+// Release releases all of the held locks.
+func (l *Locks) Release() error {
+	var errs []error
+	for k, f := range l.files {
+		// Closing the file releases its lock.
+		if err := f.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("could not unlock %s: %w", k, err))
+		}
+		delete(l.files, k)
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,77 @@
+//go:build unix
+
+package lock
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLocks_Busy(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	a := New(dir, 50*time.Millisecond)
+	b := New(dir, 50*time.Millisecond)
+
+	if err := a.Acquire(ctx, "server", "repo"); err != nil {
+		t.Fatalf("a.Acquire() = %v", err)
+	}
+	// Acquiring a held lock again is a no-op.
+	if err := a.Acquire(ctx, "repo"); err != nil {
+		t.Errorf("a.Acquire(repo) again = %v", err)
+	}
+	if err := b.Acquire(ctx, "other", "repo"); !errors.Is(err, ErrBusy) {
+		t.Errorf("b.Acquire(other, repo) = %v, want %v", err, ErrBusy)
+	}
+	// b didn't keep the lock it could acquire.
+	if err := a.Acquire(ctx, "other"); err != nil {
+		t.Errorf("a.Acquire(other) = %v", err)
+	}
+
+	if err := a.Release(); err != nil {
+		t.Fatalf("a.Release() = %v", err)
+	}
+	if err := b.Acquire(ctx, "other", "repo"); err != nil {
+		t.Errorf("b.Acquire(other, repo) after a.Release() = %v", err)
+	}
+	if err := b.Release(); err != nil {
+		t.Errorf("b.Release() = %v", err)
+	}
+}
+
+func TestLocks_Concurrent(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	dir := t.TempDir()
+
+	// Every goroutine uses its own Locks, like separate processes would.
+	var holders, maxHolders atomic.Int32
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l := New(dir, 5*time.Second)
+			if err := l.Acquire(ctx, "server", "repo"); err != nil {
+				t.Errorf("Acquire() = %v", err)
+				return
+			}
+			n := holders.Add(1)
+			for m := maxHolders.Load(); n > m && !maxHolders.CompareAndSwap(m, n); m = maxHolders.Load() {
+			}
+			time.Sleep(5 * time.Millisecond)
+			holders.Add(-1)
+			if err := l.Release(); err != nil {
+				t.Errorf("Release() = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := maxHolders.Load(); n != 1 {
+		t.Errorf("%d goroutines held the lock at once, want 1", n)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"os"
 
 	"github.com/JeffFaer/tmux-vcs-sync/cmd"
@@ -9,6 +10,10 @@ import (
 
 func main() {
 	if err := cmd.Execute(context.Background()); err != nil {
+		var code cmd.ExitCodeError
+		if errors.As(err, &code) {
+			os.Exit(int(code))
+		}
 		os.Exit(1)
 	}
 }
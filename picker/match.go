@@ -0,0 +1,72 @@
+package picker
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Scoring for match.
+const (
+	scoreMatch       = 1
+	bonusConsecutive = 5
+	bonusBoundary    = 3
+	penaltyGap       = 1
+)
+
+// match determines whether s matches every space-separated term in query.
+func match(query, s string) (score int, ok bool) {
+	for _, term := range strings.Fields(query) {
+		n, ok := matchTerm(term, s)
+		if !ok {
+			return 0, false
+		}
+		score += n
+	}
+	return score, true
+}
+
+// matchTerm determines whether all of query's characters appear in s, in
+// order. Matches score higher when the characters are next to each other or at
+// the start of words. The query is case sensitive only if it contains an upper
+// case letter.
+func matchTerm(query, s string) (score int, ok bool) {
+	if strings.ToLower(query) == query {
+		s = strings.ToLower(s)
+	}
+	q := []rune(query)
+	str := []rune(s)
+
+	var qi int
+	first, prev := -1, -1
+	for i, r := range str {
+		if qi == len(q) {
+			break
+		}
+		if r != q[qi] {
+			continue
+		}
+		score += scoreMatch
+		if prev >= 0 && i == prev+1 {
+			score += bonusConsecutive
+		}
+		if i == 0 || isBoundary(str[i-1]) {
+			score += bonusBoundary
+		}
+		if first < 0 {
+			first = i
+		}
+		prev = i
+		qi++
+	}
+	if qi < len(q) {
+		return 0, false
+	}
+	// Penalize characters between the first and last match that weren't part of
+	// the query.
+	score -= penaltyGap * (prev - first + 1 - len(q))
+	return score, true
+}
+
+func isBoundary(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
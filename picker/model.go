@@ -0,0 +1,255 @@
+package picker
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// key is a single key press.
+// Printable characters are represented by themselves. Everything else is one
+// of the constants below.
+type key string
+
+const (
+	keyUp         key = "<up>"
+	keyDown       key = "<down>"
+	keyEnter      key = "<enter>"
+	keyCancel     key = "<cancel>"
+	keyBackspace  key = "<backspace>"
+	keyClear      key = "<clear>"
+	keyDeleteWord key = "<delete-word>"
+)
+
+// parseKeys splits terminal input into key presses.
+// Unrecognized control characters and escape sequences are dropped.
+func parseKeys(b []byte) []key {
+	var keys []key
+	for len(b) > 0 {
+		switch c := b[0]; {
+		case c == 0x1b:
+			if len(b) == 1 || (b[1] != '[' && b[1] != 'O') {
+				keys = append(keys, keyCancel)
+				b = b[1:]
+				continue
+			}
+			// A CSI or SS3 escape sequence ends with a byte in the range 0x40-0x7e.
+			end := 2
+			for end < len(b) && (b[end] < 0x40 || b[end] > 0x7e) {
+				end++
+			}
+			if end < len(b) {
+				switch b[end] {
+				case 'A':
+					keys = append(keys, keyUp)
+				case 'B':
+					keys = append(keys, keyDown)
+				}
+			}
+			b = b[min(end+1, len(b)):]
+		case c == '\r' || c == '\n':
+			keys = append(keys, keyEnter)
+			b = b[1:]
+		case c == 0x03 || c == 0x07: // ctrl-c, ctrl-g
+			keys = append(keys, keyCancel)
+			b = b[1:]
+		case c == 0x7f || c == 0x08: // backspace, ctrl-h
+			keys = append(keys, keyBackspace)
+			b = b[1:]
+		case c == 0x15: // ctrl-u
+			keys = append(keys, keyClear)
+			b = b[1:]
+		case c == 0x17: // ctrl-w
+			keys = append(keys, keyDeleteWord)
+			b = b[1:]
+		case c == 0x10: // ctrl-p
+			keys = append(keys, keyUp)
+			b = b[1:]
+		case c == 0x0e: // ctrl-n
+			keys = append(keys, keyDown)
+			b = b[1:]
+		case c < 0x20:
+			b = b[1:]
+		default:
+			r, n := utf8.DecodeRune(b)
+			if r != utf8.RuneError {
+				keys = append(keys, key(string(r)))
+			}
+			b = b[n:]
+		}
+	}
+	return keys
+}
+
+// model is the state of the picker, independent of any terminal.
+type model struct {
+	opts Options
+
+	query []rune
+	// matches are the indexes of opts.Items that match query, best first.
+	matches []int
+	// selected is an index into matches.
+	selected int
+	// offset is the index of the first match that's visible.
+	offset int
+}
+
+type result int
+
+const (
+	resultNone result = iota
+	resultAccept
+	resultCancel
+)
+
+func newModel(opts Options) *model {
+	m := &model{opts: opts}
+	m.filter()
+	return m
+}
+
+// handle updates the model for a key press.
+func (m *model) handle(k key) result {
+	switch k {
+	case keyEnter:
+		if len(m.matches) == 0 {
+			return resultNone
+		}
+		return resultAccept
+	case keyCancel:
+		return resultCancel
+	case keyUp:
+		m.selected = max(m.selected-1, 0)
+	case keyDown:
+		m.selected = min(m.selected+1, max(len(m.matches)-1, 0))
+	case keyBackspace:
+		if len(m.query) > 0 {
+			m.query = m.query[:len(m.query)-1]
+			m.filter()
+		}
+	case keyClear:
+		m.query = nil
+		m.filter()
+	case keyDeleteWord:
+		q := strings.TrimRight(string(m.query), " ")
+		m.query = []rune(q[:strings.LastIndex(q, " ")+1])
+		m.filter()
+	default:
+		m.query = append(m.query, []rune(string(k))...)
+		m.filter()
+	}
+	return resultNone
+}
+
+// filter recomputes matches for the current query and resets the selection.
+func (m *model) filter() {
+	type scored struct {
+		i, score int
+	}
+	var ms []scored
+	q := string(m.query)
+	for i, item := range m.opts.Items {
+		if score, ok := match(q, item); ok {
+			ms = append(ms, scored{i, score})
+		}
+	}
+	// Ties keep the order of opts.Items.
+	slices.SortStableFunc(ms, func(a, b scored) int { return b.score - a.score })
+	m.matches = m.matches[:0]
+	for _, s := range ms {
+		m.matches = append(m.matches, s.i)
+	}
+	m.selected = 0
+	m.offset = 0
+}
+
+// current returns the index of the selected item, or -1 if nothing matches.
+func (m *model) current() int {
+	if len(m.matches) == 0 {
+		return -1
+	}
+	return m.matches[m.selected]
+}
+
+// render draws the picker onto a rows x cols terminal: the query, followed by
+// the matching items and a preview of the selected item next to them.
+func (m *model) render(w io.Writer, rows, cols int, preview []string) error {
+	listRows := max(rows-2, 1)
+	if m.selected < m.offset {
+		m.offset = m.selected
+	} else if m.selected >= m.offset+listRows {
+		m.offset = m.selected - listRows + 1
+	}
+	listCols := cols
+	if m.opts.Preview != nil && cols >= 40 {
+		listCols = cols / 2
+	}
+
+	var b strings.Builder
+	// Move to the top left.
+	b.WriteString("\x1b[H")
+	line := func(s string) {
+		b.WriteString(s)
+		// Clear the rest of the line.
+		b.WriteString("\x1b[K\r\n")
+	}
+	line(truncate(m.opts.Prompt+string(m.query), cols))
+	line(fmt.Sprintf("\x1b[2m  %d/%d\x1b[0m", len(m.matches), len(m.opts.Items)))
+	for row := range listRows {
+		var s string
+		if i := m.offset + row; i < len(m.matches) {
+			s = truncate(m.opts.Items[m.matches[i]], listCols-2)
+			if i == m.selected {
+				s = "\x1b[7m> " + s + "\x1b[0m"
+			} else {
+				s = "  " + s
+			}
+		}
+		if listCols < cols {
+			s += strings.Repeat(" ", max(listCols-displayWidth(s), 0)) + "\x1b[2m│\x1b[0m "
+			if row < len(preview) {
+				s += truncate(preview[row], cols-listCols-2)
+			}
+		}
+		if row == listRows-1 {
+			b.WriteString(s + "\x1b[K")
+		} else {
+			line(s)
+		}
+	}
+	// Clear anything left below, and put the cursor at the end of the query.
+	fmt.Fprintf(&b, "\x1b[J\x1b[1;%dH", min(utf8.RuneCountInString(m.opts.Prompt)+len(m.query)+1, cols))
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// truncate shortens s to at most n characters.
+func truncate(s string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-1]) + "…"
+	}
+	return s
+}
+
+// displayWidth is the number of characters in s, ignoring the escape sequences
+// that render uses.
+func displayWidth(s string) int {
+	var n int
+	for len(s) > 0 {
+		if strings.HasPrefix(s, "\x1b[") {
+			if i := strings.IndexByte(s, 'm'); i >= 0 {
+				s = s[i+1:]
+				continue
+			}
+		}
+		_, size := utf8.DecodeRuneInString(s)
+		s = s[size:]
+		n++
+	}
+	return n
+}
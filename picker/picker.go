@@ -0,0 +1,161 @@
+// Package picker implements a small interactive fuzzy finder for terminals.
+package picker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/JeffFaer/tmux-vcs-sync/api/exec"
+)
+
+// ErrCanceled is returned by Run if the user didn't pick anything.
+var ErrCanceled = errors.New("nothing was picked")
+
+// Options affects what Run displays.
+type Options struct {
+	// Prompt is displayed before the query.
+	Prompt string
+	// Items are the choices. Items are displayed in this order until the user
+	// starts typing a query.
+	Items []string
+	// Preview optionally describes Items[i] in a pane next to the list of items.
+	Preview func(i int) []string
+}
+
+// Run lets the user interactively filter opts.Items in the terminal tty and
+// returns the index of the chosen item.
+func Run(ctx context.Context, tty *os.File, opts Options) (int, error) {
+	if len(opts.Items) == 0 {
+		return -1, fmt.Errorf("nothing to pick from")
+	}
+	stty, err := exec.Lookup("stty")
+	if err != nil {
+		return -1, err
+	}
+	term := terminal{stty, tty}
+	restore, err := term.makeRaw(ctx)
+	if err != nil {
+		return -1, err
+	}
+	// Use the alternate screen so that the picker doesn't leave anything behind.
+	fmt.Fprint(tty, "\x1b[?1049h")
+	defer func() {
+		fmt.Fprint(tty, "\x1b[?1049l")
+		restore()
+	}()
+
+	winch := make(chan os.Signal, 1)
+	signal.Notify(winch, syscall.SIGWINCH)
+	defer signal.Stop(winch)
+
+	input := make(chan []byte)
+	readErr := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 256)
+		for {
+			n, err := tty.Read(buf)
+			if err != nil {
+				readErr <- err
+				return
+			}
+			b := make([]byte, n)
+			copy(b, buf)
+			select {
+			case input <- b:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	m := newModel(opts)
+	previews := make(map[int][]string)
+	rows, cols := term.size(ctx)
+	for {
+		var preview []string
+		if i := m.current(); i >= 0 && opts.Preview != nil {
+			if _, ok := previews[i]; !ok {
+				previews[i] = opts.Preview(i)
+			}
+			preview = previews[i]
+		}
+		if err := m.render(tty, rows, cols, preview); err != nil {
+			return -1, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return -1, ctx.Err()
+		case err := <-readErr:
+			return -1, fmt.Errorf("reading from terminal: %w", err)
+		case <-winch:
+			rows, cols = term.size(ctx)
+		case b := <-input:
+			for _, k := range parseKeys(b) {
+				switch m.handle(k) {
+				case resultAccept:
+					return m.current(), nil
+				case resultCancel:
+					return -1, ErrCanceled
+				}
+			}
+		}
+	}
+}
+
+// terminal configures a terminal with stty.
+type terminal struct {
+	stty exec.Executable
+	tty  *os.File
+}
+
+func (t terminal) run(ctx context.Context, args ...string) (string, error) {
+	cmd := t.stty.Command(ctx, args...)
+	cmd.Stdin = t.tty
+	return cmd.RunStdout()
+}
+
+// makeRaw puts the terminal into raw mode so that it's possible to read
+// individual key presses. The returned function restores the terminal's
+// previous mode.
+func (t terminal) makeRaw(ctx context.Context) (func(), error) {
+	saved, err := t.run(ctx, "-g")
+	if err != nil {
+		return nil, fmt.Errorf("could not save terminal state: %w", err)
+	}
+	if _, err := t.run(ctx, "raw", "-echo"); err != nil {
+		return nil, fmt.Errorf("could not put terminal into raw mode: %w", err)
+	}
+	return func() {
+		if _, err := t.run(context.WithoutCancel(ctx), saved); err != nil {
+			slog.Warn("Could not restore terminal state.", "error", err)
+		}
+	}, nil
+}
+
+// size returns the number of rows and columns in the terminal.
+func (t terminal) size(ctx context.Context) (rows, cols int) {
+	rows, cols = 24, 80
+	out, err := t.run(ctx, "size")
+	if err != nil {
+		return rows, cols
+	}
+	sp := strings.Fields(out)
+	if len(sp) != 2 {
+		return rows, cols
+	}
+	if r, err := strconv.Atoi(sp[0]); err == nil && r > 0 {
+		rows = r
+	}
+	if c, err := strconv.Atoi(sp[1]); err == nil && c > 0 {
+		cols = c
+	}
+	return rows, cols
+}
@@ -0,0 +1,184 @@
+package picker
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMatch(t *testing.T) {
+	for _, tc := range []struct {
+		query, s string
+		want     bool
+	}{
+		{"", "anything", true},
+		{"abc", "abc", true},
+		{"abc", "a-b-c", true},
+		{"abc", "acb", false},
+		{"abc", "ABC", true},
+		{"ABC", "abc", false},
+		{"repo>b", "repo>branch", true},
+		{"é", "café", true},
+		{"repo fix", "repo>bugfix", true},
+		{"fix repo", "repo>bugfix", true},
+		{"repo xyz", "repo>bugfix", false},
+	} {
+		if _, got := match(tc.query, tc.s); got != tc.want {
+			t.Errorf("match(%q, %q) = _, %t, want %t", tc.query, tc.s, got, tc.want)
+		}
+	}
+}
+
+func TestMatch_Score(t *testing.T) {
+	// Each query should score better against better than against worse.
+	for _, tc := range []struct {
+		query, better, worse string
+	}{
+		{"foo", "foo", "f-o-o"},
+		{"foo", "xfoo", "fxoxo"},
+		{"fb", "foo-bar", "fxbxxxx"},
+		{"bar", "repo>bar", "repo>xbar"},
+	} {
+		b, ok := match(tc.query, tc.better)
+		if !ok {
+			t.Errorf("match(%q, %q) = _, false", tc.query, tc.better)
+		}
+		w, ok := match(tc.query, tc.worse)
+		if !ok {
+			t.Errorf("match(%q, %q) = _, false", tc.query, tc.worse)
+		}
+		if b <= w {
+			t.Errorf("match(%q, %q) = %d, which isn't better than match(%q, %q) = %d", tc.query, tc.better, b, tc.query, tc.worse, w)
+		}
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want []key
+	}{
+		{"ab", []key{"a", "b"}},
+		{"\x1b[A\x1b[B", []key{keyUp, keyDown}},
+		{"\x1bOA", []key{keyUp}},
+		{"\x1b", []key{keyCancel}},
+		{"\x1b[1;5C", nil},
+		{"\r", []key{keyEnter}},
+		{"\x7f\x15\x17", []key{keyBackspace, keyClear, keyDeleteWord}},
+		{"\x10\x0e", []key{keyUp, keyDown}},
+		{"\x03", []key{keyCancel}},
+		{"é", []key{"é"}},
+	} {
+		if diff := cmp.Diff(tc.want, parseKeys([]byte(tc.in))); diff != "" {
+			t.Errorf("parseKeys(%q) diff (-want +got)\n%s", tc.in, diff)
+		}
+	}
+}
+
+func TestModel(t *testing.T) {
+	items := []string{"main", "feature-one", "feature-two", "bugfix"}
+	for _, tc := range []struct {
+		name string
+		keys []key
+
+		want       int
+		wantResult result
+	}{
+		{
+			name:       "FirstItem",
+			keys:       []key{keyEnter},
+			want:       0,
+			wantResult: resultAccept,
+		},
+		{
+			name:       "Query",
+			keys:       []key{"b", "u", "g", keyEnter},
+			want:       3,
+			wantResult: resultAccept,
+		},
+		{
+			name:       "QueryThenDown",
+			keys:       []key{"f", "t", keyDown, keyEnter},
+			want:       2,
+			wantResult: resultAccept,
+		},
+		{
+			name:       "DownPastEnd",
+			keys:       []key{keyDown, keyDown, keyDown, keyDown, keyDown, keyEnter},
+			want:       3,
+			wantResult: resultAccept,
+		},
+		{
+			name:       "UpPastStart",
+			keys:       []key{keyUp, keyEnter},
+			want:       0,
+			wantResult: resultAccept,
+		},
+		{
+			name:       "Backspace",
+			keys:       []key{"x", keyBackspace, keyDown, keyEnter},
+			want:       1,
+			wantResult: resultAccept,
+		},
+		{
+			name:       "DeleteWord",
+			keys:       []key{"b", "u", "g", " ", "x", keyDeleteWord, keyEnter},
+			want:       3,
+			wantResult: resultAccept,
+		},
+		{
+			name:       "NoMatches",
+			keys:       []key{"z", keyEnter},
+			want:       -1,
+			wantResult: resultNone,
+		},
+		{
+			name:       "Cancel",
+			keys:       []key{"m", keyCancel},
+			want:       0,
+			wantResult: resultCancel,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m := newModel(Options{Items: items})
+			var res result
+			for _, k := range tc.keys {
+				if res = m.handle(k); res != resultNone {
+					break
+				}
+			}
+			if res != tc.wantResult {
+				t.Errorf("result = %v, want %v", res, tc.wantResult)
+			}
+			if got := m.current(); got != tc.want {
+				t.Errorf("m.current() = %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestModel_Render(t *testing.T) {
+	m := newModel(Options{
+		Prompt:  "> ",
+		Items:   []string{"a", "b", "c", "d", "e"},
+		Preview: func(int) []string { return nil },
+	})
+	for range 4 {
+		m.handle(keyDown)
+	}
+	var b strings.Builder
+	// Only 2 items fit.
+	if err := m.render(&b, 4, 80, []string{"preview"}); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	for _, want := range []string{"> e", "preview", "5/5"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("m.render() = %q, which doesn't contain %q", out, want)
+		}
+	}
+	if strings.Contains(out, "  c") {
+		t.Errorf("m.render() = %q, which should have scrolled past %q", out, "c")
+	}
+}
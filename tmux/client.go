@@ -62,13 +62,54 @@ func (c *client) Properties(ctx context.Context, props ...ClientProperty) (map[C
 	return res, nil
 }
 
+func (c *client) Switch(ctx context.Context, s Session) error {
+	if !SameServer(ctx, c.srv, s.Server()) {
+		return fmt.Errorf("target session does not exist in this server")
+	}
+	args := []string{"switch-client"}
+	if c.tty != currentClientTTY {
+		args = append(args, "-c", c.tty)
+	}
+	args = append(args, "-t", s.ID())
+	return c.srv.command(ctx, args...).Run()
+}
+
 func (c *client) DisplayMenu(ctx context.Context, elems []MenuElement) error {
+	if err := requireVersion(ctx, c.srv, displayMenuVersion, "display-menu"); err != nil {
+		return err
+	}
 	args := []string{"display-menu"}
 	if c.tty != currentClientTTY {
 		args = append(args, "-c", c.tty)
 	}
-	for _, e := range elems {
-		args = append(args, e.args()...)
+	args = append(args, "--")
+	args = append(args, MenuArgs(elems)...)
+	return c.srv.command(ctx, args...).Run()
+}
+
+func (c *client) DisplayPopup(ctx context.Context, opts PopupOptions) error {
+	if err := requireVersion(ctx, c.srv, displayPopupVersion, "display-popup"); err != nil {
+		return err
+	}
+	args := []string{"display-popup", "-E"}
+	if opts.KeepOpenOnError {
+		args = append(args, "-E")
+	}
+	if c.tty != currentClientTTY {
+		args = append(args, "-c", c.tty)
+	}
+	if opts.Title != "" && supports(ctx, c.srv, popupTitleVersion) {
+		args = append(args, "-T", opts.Title)
+	}
+	if opts.Width != "" {
+		args = append(args, "-w", opts.Width)
+	}
+	if opts.Height != "" {
+		args = append(args, "-h", opts.Height)
+	}
+	if opts.StartDir != "" {
+		args = append(args, "-d", opts.StartDir)
 	}
+	args = append(args, opts.Command)
 	return c.srv.command(ctx, args...).Run()
 }
@@ -0,0 +1,42 @@
+package tmux
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/JeffFaer/tmux-vcs-sync/api/exec"
+)
+
+// Commander runs tmux commands against a particular tmux server.
+type Commander interface {
+	// Run runs a single tmux command and returns its standard output and standard
+	// error, both trimmed of trailing newlines.
+	Run(ctx context.Context, args ...string) (stdout string, stderr string, err error)
+	// Close releases any resources held by the Commander.
+	Close() error
+}
+
+// execCommander is a Commander that starts a new tmux process for every
+// command.
+type execCommander struct {
+	tmux exec.Commander
+	args []string
+}
+
+var _ Commander = execCommander{}
+
+func (c execCommander) Run(ctx context.Context, args ...string) (string, string, error) {
+	args = append(append([]string(nil), c.args...), args...)
+	return c.tmux.Command(ctx, args...).RunOutput()
+}
+
+func (execCommander) Close() error { return nil }
+
+// commandError attaches a tmux command's standard error to err, if there is
+// any.
+func commandError(err error, stderr string) error {
+	if err == nil || stderr == "" {
+		return err
+	}
+	return fmt.Errorf("%w: %s", err, stderr)
+}
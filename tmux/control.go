@@ -0,0 +1,266 @@
+package tmux
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/JeffFaer/tmux-vcs-sync/api/exec"
+)
+
+var errControlModeClosed = errors.New("tmux control mode connection closed")
+
+// controlMode is a Commander that sends all of its commands to a single tmux
+// process running in control mode (tmux -C).
+//
+// Commands are pipelined: Run may be called concurrently, and each command is
+// written to tmux as soon as it's requested. tmux replies to commands in the
+// order it received them, so replies are matched up with their callers in
+// FIFO order.
+type controlMode struct {
+	cmd   *exec.Command
+	stdin io.WriteCloser
+
+	mu sync.Mutex
+	// Callers waiting for a reply, in the order their commands were sent.
+	pending []chan controlReply
+	// Set once the connection has closed.
+	err  error
+	done chan struct{}
+
+	// If set, notifications are sent here until stop is closed. It's closed
+	// once the connection has closed.
+	notifications chan Notification
+	stop          chan struct{}
+}
+
+var _ Commander = (*controlMode)(nil)
+
+type controlReply struct {
+	output string
+	failed bool
+}
+
+// startControlMode starts a tmux control mode client for the server described
+// by args.
+// The client attaches to the server's most recently used session, so this will
+// return an error if the server doesn't have any sessions.
+func startControlMode(ctx context.Context, tmux exec.Commander, args []string) (*controlMode, error) {
+	return startControlModeClient(ctx, tmux, args, nil)
+}
+
+// watchControlMode starts a tmux control mode client that only reports
+// notifications. Close it to stop receiving them.
+func watchControlMode(ctx context.Context, tmux exec.Commander, args []string) (*controlMode, error) {
+	// Notifications can arrive before anyone is receiving them, e.g. while the
+	// client is attaching.
+	return startControlModeClient(ctx, tmux, args, make(chan Notification, 64))
+}
+
+func startControlModeClient(ctx context.Context, tmux exec.Commander, args []string, notifications chan Notification) (*controlMode, error) {
+	args = append(append([]string(nil), args...), "-C", "attach-session", "-f", "no-output,ignore-size")
+	// The connection should outlive whichever command happened to start it.
+	cmd := tmux.Command(context.WithoutCancel(ctx), args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("could not start tmux control mode: %w", err)
+	}
+
+	c := &controlMode{cmd: cmd, stdin: stdin, done: make(chan struct{}), notifications: notifications, stop: make(chan struct{})}
+	go c.read(stdout)
+
+	// Make sure the client actually attached before anyone relies on it.
+	if _, _, err := c.Run(ctx, "display-message", "-p", ""); err != nil {
+		return nil, errors.Join(fmt.Errorf("tmux control mode: %w", err), c.Close())
+	}
+	return c, nil
+}
+
+func (c *controlMode) Run(ctx context.Context, args ...string) (string, string, error) {
+	if len(args) == 0 {
+		// An empty line detaches the control mode client.
+		return "", "", fmt.Errorf("no tmux command given")
+	}
+	line := FormatCommand(args...)
+
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return "", "", c.err
+	}
+	slog.Debug("Sending tmux control mode command.", "command", line)
+	if _, err := io.WriteString(c.stdin, line+"\n"); err != nil {
+		c.mu.Unlock()
+		return "", "", fmt.Errorf("could not send tmux command: %w", err)
+	}
+	// Replies are read while holding mu, so this is enqueued before tmux's reply
+	// can be matched up with it. Commands that couldn't be sent won't get a
+	// reply, so they must not be enqueued.
+	ch := make(chan controlReply, 1)
+	c.pending = append(c.pending, ch)
+	c.mu.Unlock()
+
+	select {
+	case reply, ok := <-ch:
+		if !ok {
+			return "", "", errControlModeClosed
+		}
+		if reply.failed {
+			return "", reply.output, fmt.Errorf("tmux %s failed", args[0])
+		}
+		return reply.output, "", nil
+	case <-ctx.Done():
+		return "", "", ctx.Err()
+	}
+}
+
+// read parses the output of the control mode client until it exits.
+// Every command's output is wrapped in a %begin/%end (or %error) block. Any
+// other line is a notification.
+func (c *controlMode) read(r io.Reader) {
+	defer c.shutdown()
+	if c.notifications != nil {
+		defer close(c.notifications)
+	}
+
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1024*1024)
+	var (
+		inBlock bool
+		ours    bool
+		guard   string
+		output  []string
+	)
+	for s.Scan() {
+		line := s.Text()
+		if !inBlock {
+			switch {
+			case strings.HasPrefix(line, "%begin "):
+				// %begin <time> <command number> <flags>
+				guard = strings.TrimPrefix(line, "%begin ")
+				fields := strings.Fields(guard)
+				// flags is 1 if the command was sent by this client.
+				ours = len(fields) == 3 && fields[2] == "1"
+				inBlock = true
+				output = nil
+			case line == "%exit" || strings.HasPrefix(line, "%exit "):
+				return
+			case c.notifications != nil && strings.HasPrefix(line, "%"):
+				name, args, _ := strings.Cut(strings.TrimPrefix(line, "%"), " ")
+				select {
+				case c.notifications <- Notification{Name: name, Args: args}:
+				case <-c.stop:
+				}
+			}
+			continue
+		}
+
+		var failed bool
+		switch line {
+		case "%end " + guard:
+		case "%error " + guard:
+			failed = true
+		default:
+			output = append(output, line)
+			continue
+		}
+		inBlock = false
+		if !ours {
+			continue
+		}
+		c.mu.Lock()
+		if len(c.pending) == 0 {
+			c.mu.Unlock()
+			slog.Warn("Received an unexpected reply from tmux control mode.", "output", output)
+			continue
+		}
+		ch := c.pending[0]
+		c.pending = c.pending[1:]
+		c.mu.Unlock()
+		ch <- controlReply{output: strings.Join(output, "\n"), failed: failed}
+	}
+	if err := s.Err(); err != nil {
+		slog.Warn("Error reading from tmux control mode.", "error", err)
+	}
+}
+
+func (c *controlMode) shutdown() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err == nil {
+		c.err = errControlModeClosed
+		close(c.done)
+	}
+	for _, ch := range c.pending {
+		close(ch)
+	}
+	c.pending = nil
+}
+
+// closed determines whether this connection can no longer be used.
+func (c *controlMode) closed() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Close detaches the control mode client and waits for it to exit.
+func (c *controlMode) Close() error {
+	c.mu.Lock()
+	err := c.stdin.Close()
+	select {
+	case <-c.stop:
+	default:
+		close(c.stop)
+	}
+	c.mu.Unlock()
+	<-c.done
+	return err
+}
+
+// FormatCommand formats a tmux command so that it can be parsed by tmux, e.g.
+// for a MenuEntry's Command.
+func FormatCommand(args ...string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = quoteControlArg(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+var controlArgEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`"`, `\"`,
+	`$`, `\$`,
+	"\n", `\n`,
+	"\r", `\r`,
+	"\t", `\t`,
+)
+
+// quoteControlArg quotes arg so that tmux's command parser treats it as a single
+// literal argument.
+// Double quotes are used because control mode reads one command per line, so
+// newlines need to be escaped, which isn't possible within single quotes.
+func quoteControlArg(arg string) string {
+	arg = controlArgEscaper.Replace(arg)
+	if strings.HasPrefix(arg, "~") {
+		// tmux expands a leading ~ even within double quotes.
+		arg = `\` + arg
+	}
+	return `"` + arg + `"`
+}
@@ -2,11 +2,15 @@ package tmux
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/JeffFaer/tmux-vcs-sync/api/exec"
 )
@@ -31,6 +35,17 @@ type server struct {
 	opts serverOptions
 
 	tmux exec.Commander
+
+	mu sync.Mutex
+	// A persistent control mode connection, if opts.controlMode is set and the
+	// connection could be established.
+	control *controlMode
+	// Set if a control mode connection could not be established. Commands will
+	// be executed in separate processes instead.
+	controlErr error
+
+	versionMu sync.Mutex
+	version   *Version
 }
 
 // NewServer creates a new server for the given socket.
@@ -41,7 +56,7 @@ func NewServer(opts ...ServerOption) *server {
 	for _, o := range opts {
 		o(&opt)
 	}
-	return &server{opt, tmux}
+	return &server{opts: opt, tmux: tmux}
 }
 
 type ServerOption func(*serverOptions)
@@ -52,16 +67,35 @@ func NamedServerSocket(name string) ServerOption {
 	}
 }
 
+func ServerSocketPath(path string) ServerOption {
+	return func(opts *serverOptions) {
+		opts.socketPath = path
+	}
+}
+
 func ServerConfigFile(file string) ServerOption {
 	return func(opts *serverOptions) {
 		opts.configFile = file
 	}
 }
 
+// ControlMode makes the server send its commands over a single, persistent
+// tmux control mode connection instead of starting a new tmux process for each
+// command.
+// Commands that need to interact with a terminal are still run as separate
+// processes.
+func ControlMode() ServerOption {
+	return func(opts *serverOptions) {
+		opts.controlMode = true
+	}
+}
+
 type serverOptions struct {
 	socketPath string
 	socketName string
 	configFile string
+
+	controlMode bool
 }
 
 func (opts serverOptions) args() []string {
@@ -80,8 +114,8 @@ func (opts serverOptions) args() []string {
 
 // CurrentServer returns a server if this program is running within a tmux
 // server.
-func CurrentServer() (Server, error) {
-	srv := MaybeCurrentServer()
+func CurrentServer(opts ...ServerOption) (Server, error) {
+	srv := MaybeCurrentServer(opts...)
 	if srv == nil {
 		return nil, errNotTmux
 	}
@@ -90,18 +124,18 @@ func CurrentServer() (Server, error) {
 
 // MaybeCurrentServer returns a server if this program is running within a tmux
 // server. If it's not, it returns nil.
-func MaybeCurrentServer() Server {
+func MaybeCurrentServer(opts ...ServerOption) Server {
 	env, err := getenv()
 	if err != nil {
 		return nil
 	}
-	srv := env.server()
+	srv := env.server(opts...)
 	slog.Info("Found tmux server.", "server", srv)
 	return srv
 }
 
-func DefaultServer() Server {
-	return &server{tmux: tmux}
+func DefaultServer(opts ...ServerOption) Server {
+	return NewServer(opts...)
 }
 
 func (srv *server) LogValue() slog.Value {
@@ -115,21 +149,113 @@ func (srv *server) LogValue() slog.Value {
 	}
 }
 
+// SocketPath returns the socket path that tmux uses for this server. See the -L
+// and -S options in tmux(1).
+func (srv *server) SocketPath() string {
+	if srv.opts.socketPath != "" {
+		return srv.opts.socketPath
+	}
+	name := srv.opts.socketName
+	if name == "" {
+		name = "default"
+	}
+	dir := os.Getenv("TMUX_TMPDIR")
+	if dir == "" {
+		dir = "/tmp"
+	}
+	return filepath.Join(dir, fmt.Sprintf("tmux-%d", os.Getuid()), name)
+}
+
+// command creates a tmux process for this server.
+// Most commands should use run instead so that they can take advantage of
+// control mode.
 func (srv *server) command(ctx context.Context, args ...string) *exec.Command {
 	args = append(srv.opts.args(), args...)
 	return srv.tmux.Command(ctx, args...)
 }
 
+// commander returns the Commander that should be used to run tmux commands
+// against this server.
+func (srv *server) commander(ctx context.Context) Commander {
+	exec := execCommander{srv.tmux, srv.opts.args()}
+	if !srv.opts.controlMode {
+		return exec
+	}
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.controlErr != nil {
+		return exec
+	}
+	if srv.control == nil || srv.control.closed() {
+		if err := requireVersion(ctx, srv, controlModeVersion, "control mode"); err != nil {
+			slog.Debug("Could not use tmux control mode.", "server", srv, "error", err)
+			srv.controlErr = err
+			return exec
+		}
+		c, err := startControlMode(ctx, srv.tmux, srv.opts.args())
+		if err != nil {
+			slog.Debug("Could not use tmux control mode.", "server", srv, "error", err)
+			srv.controlErr = err
+			return exec
+		}
+		srv.control = c
+	}
+	return srv.control
+}
+
+// run runs a tmux command against this server and returns its standard output
+// and standard error.
+func (srv *server) run(ctx context.Context, args ...string) (string, string, error) {
+	return srv.commander(ctx).Run(ctx, args...)
+}
+
+// runStdout runs a tmux command against this server and returns its standard
+// output.
+func (srv *server) runStdout(ctx context.Context, args ...string) (string, error) {
+	stdout, stderr, err := srv.run(ctx, args...)
+	return stdout, commandError(err, stderr)
+}
+
 func (srv *server) PID(ctx context.Context) (int, error) {
-	pid, err := srv.command(ctx, "display-message", "-p", "-F", "#{pid}").RunStdout()
+	pid, err := srv.runStdout(ctx, "display-message", "-p", "-F", "#{pid}")
 	if err != nil {
 		return 0, err
 	}
 	return strconv.Atoi(pid)
 }
 
+// Version returns the version of tmux running this server. If the server isn't
+// running, it returns the version of the tmux executable instead.
+// The version is only determined once per server.
+func (srv *server) Version(ctx context.Context) (Version, error) {
+	srv.versionMu.Lock()
+	defer srv.versionMu.Unlock()
+	if srv.version != nil {
+		return *srv.version, nil
+	}
+
+	// Control mode needs to know the version before it can be used.
+	c := execCommander{srv.tmux, srv.opts.args()}
+	s, _, err := c.Run(ctx, "display-message", "-p", "#{version}")
+	if err != nil || s == "" {
+		// Either the server isn't running, or it's too old to know its own version.
+		s, err = srv.tmux.Command(ctx, "-V").RunStdout()
+		if err != nil {
+			return Version{}, fmt.Errorf("could not determine tmux version: %w", err)
+		}
+	}
+	v, err := ParseVersion(s)
+	if err != nil {
+		return Version{}, err
+	}
+	slog.Debug("Found tmux version.", "server", srv, "version", v)
+	srv.version = &v
+	return v, nil
+}
+
 func (srv *server) ListSessions(ctx context.Context) (Sessions, error) {
-	stdout, stderr, err := srv.command(ctx, "list-sessions", "-F", string(SessionID)).RunOutput()
+	stdout, stderr, err := srv.run(ctx, "list-sessions", "-F", string(SessionID))
 	if err != nil {
 		if
 		// Socket doesn't yet exist.
@@ -138,8 +264,10 @@ func (srv *server) ListSessions(ctx context.Context) (Sessions, error) {
 			strings.Contains(stderr, "no server running") {
 			return sessions(nil), nil
 		}
-		fmt.Fprintln(os.Stderr, stderr)
-		return nil, err
+		return nil, commandError(err, stderr)
+	}
+	if stdout == "" {
+		return sessions(nil), nil
 	}
 	var res sessions
 	for _, id := range strings.Split(stdout, "\n") {
@@ -149,7 +277,7 @@ func (srv *server) ListSessions(ctx context.Context) (Sessions, error) {
 }
 
 func (srv *server) ListClients(ctx context.Context) ([]Client, error) {
-	stdout, err := srv.command(ctx, "list-clients", "-F", string(ClientTTY)).RunStdout()
+	stdout, err := srv.runStdout(ctx, "list-clients", "-F", string(ClientTTY))
 	if err != nil {
 		return nil, err
 	}
@@ -166,11 +294,24 @@ func (srv *server) ListClients(ctx context.Context) ([]Client, error) {
 func (srv *server) NewSession(ctx context.Context, opts NewSessionOptions) (Session, error) {
 	args := []string{"new-session", "-d", "-P", "-F", string(SessionID)}
 	args = append(args, opts.args()...)
-	newSession := srv.command(ctx, args...)
-	newSession.Stdin = os.Stdin // tmux wants a tty.
-	stdout, err := newSession.RunStdout()
+	c := srv.commander(ctx)
+	if _, ok := c.(execCommander); ok {
+		newSession := srv.command(ctx, args...)
+		newSession.Stdin = os.Stdin // tmux wants a tty.
+		stdout, err := newSession.RunStdout()
+		if err != nil {
+			return nil, err
+		}
+		// The server might not have had any sessions for control mode to attach to
+		// before now.
+		srv.mu.Lock()
+		srv.controlErr = nil
+		srv.mu.Unlock()
+		return &session{srv, stdout}, nil
+	}
+	stdout, stderr, err := c.Run(ctx, args...)
 	if err != nil {
-		return nil, err
+		return nil, commandError(err, stderr)
 	}
 	return &session{srv, stdout}, nil
 }
@@ -181,10 +322,18 @@ func (srv *server) AttachOrSwitch(ctx context.Context, s Session) error {
 	}
 	var cmd *exec.Command
 	var err error
-	if os.Getenv("TMUX") != "" {
-		cmd, err = srv.switchCommand(ctx, s)
-	} else {
+	switch {
+	case os.Getenv("TMUX") == "":
 		cmd, err = srv.attachCommand(ctx, s)
+	case srv.isOtherServer(ctx):
+		cmd, err = srv.attachCommand(ctx, s)
+		if err == nil {
+			// tmux refuses to attach from within another tmux server's session
+			// unless $TMUX is unset.
+			cmd.Env = slices.DeleteFunc(os.Environ(), func(e string) bool { return strings.HasPrefix(e, "TMUX=") })
+		}
+	default:
+		cmd, err = srv.switchCommand(ctx, s)
 	}
 	if err != nil {
 		return err
@@ -192,6 +341,37 @@ func (srv *server) AttachOrSwitch(ctx context.Context, s Session) error {
 	return cmd.Run()
 }
 
+// isOtherServer determines whether this program is running within a tmux
+// server other than srv.
+func (srv *server) isOtherServer(ctx context.Context) bool {
+	env, err := getenv()
+	if err != nil {
+		return false
+	}
+	pid, err := srv.PID(ctx)
+	return err == nil && pid != env.pid
+}
+
+func (srv *server) Watch(ctx context.Context) (<-chan Notification, error) {
+	if err := requireVersion(ctx, srv, controlModeVersion, "control mode"); err != nil {
+		return nil, err
+	}
+	c, err := watchControlMode(ctx, srv.tmux, srv.opts.args())
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			if err := c.Close(); err != nil {
+				slog.Warn("Could not close tmux control mode.", "server", srv, "error", err)
+			}
+		case <-c.done:
+		}
+	}()
+	return c.notifications, nil
+}
+
 func (srv *server) attachCommand(ctx context.Context, s Session) (*exec.Command, error) {
 	cmd := srv.command(ctx, "attach-session", "-t", s.ID())
 	cmd.Stdin = os.Stdin // tmux wants a tty.
@@ -205,5 +385,19 @@ func (srv *server) switchCommand(ctx context.Context, s Session) (*exec.Command,
 }
 
 func (srv *server) Kill(ctx context.Context) error {
-	return srv.command(ctx, "kill-server").Run()
+	// Killing the server would also kill the control mode connection, so don't
+	// bother using it.
+	err := srv.Close()
+	return errors.Join(srv.command(ctx, "kill-server").Run(), err)
+}
+
+func (srv *server) Close() error {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.control == nil {
+		return nil
+	}
+	err := srv.control.Close()
+	srv.control = nil
+	return err
 }
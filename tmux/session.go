@@ -62,18 +62,29 @@ func (s sessions) Properties(ctx context.Context, props ...SessionPropertyName)
 		idFilters[i] = fmt.Sprintf("#{==:%s,%s}", SessionID, sesh.ID())
 	}
 
-	filter := idFilters[0]
-	for _, idFilter := range idFilters[1:] {
-		filter = fmt.Sprintf("#{||:%s,%s}", filter, idFilter)
+	args := []string{"list-sessions", "-F", format}
+	if supports(ctx, s.server(), listFilterVersion) {
+		filter := idFilters[0]
+		for _, idFilter := range idFilters[1:] {
+			filter = fmt.Sprintf("#{||:%s,%s}", filter, idFilter)
+		}
+		args = append(args, "-f", filter)
 	}
+	// Otherwise, this tmux is too old to filter for us. We'll skip any sessions
+	// we didn't ask for below.
 
-	stdout, err := s.server().command(ctx, "list-sessions", "-F", format, "-f", filter).RunStdout()
+	stdout, err := s.server().runStdout(ctx, args...)
 	if err != nil {
 		return nil, err
 	}
 
 	ret := make(map[Session]SessionPropertyValues, len(s))
 	lines := strings.Split(stdout, "\n")
+	// Trailing newlines are trimmed from stdout, but they might have been empty
+	// property values.
+	for len(lines)%(len(props)+1) != 0 {
+		lines = append(lines, "")
+	}
 	for i := 0; i < len(lines); i++ {
 		id := lines[i]
 		vals := make(SessionPropertyValues, len(props))
@@ -81,7 +92,9 @@ func (s sessions) Properties(ctx context.Context, props ...SessionPropertyName)
 			i++
 			vals[prop] = SessionPropertyValue{name: prop, val: lines[i]}
 		}
-		ret[seshByID[id]] = vals
+		if sesh, ok := seshByID[id]; ok {
+			ret[sesh] = vals
+		}
 	}
 	return ret, nil
 }
@@ -93,8 +106,8 @@ type session struct {
 
 // CurrentSession returns a Session if this program is being executed inside
 // tmux.
-func CurrentSession() (Session, error) {
-	sesh := MaybeCurrentSession()
+func CurrentSession(opts ...ServerOption) (Session, error) {
+	sesh := MaybeCurrentSession(opts...)
 	if sesh == nil {
 		return nil, errNotTmux
 	}
@@ -103,12 +116,12 @@ func CurrentSession() (Session, error) {
 
 // MaybeCurrentSession returns a Session if this program is being executed
 // inside tmux. If it's not being executed inside tmux, returns nil.
-func MaybeCurrentSession() Session {
+func MaybeCurrentSession(opts ...ServerOption) Session {
 	env, err := getenv()
 	if err != nil {
 		return nil
 	}
-	sesh := env.session()
+	sesh := env.session(opts...)
 	slog.Info("Found current tmux session.", "server", sesh.srv, "session", sesh.id)
 	return sesh
 }
@@ -138,8 +151,106 @@ func (s *session) Properties(ctx context.Context, props ...SessionPropertyName)
 	return vals[Session(s)], nil
 }
 
+// Windows lists the session's windows and the working directories of their
+// panes.
+func (s *session) Windows(ctx context.Context) ([]Window, error) {
+	// Names are last since they're the most likely to contain a tab.
+	format := strings.Join([]string{"#{window_id}", "#{window_layout}", "#{pane_current_path}", "#{window_name}"}, "\t")
+	stdout, err := s.srv.runStdout(ctx, "list-panes", "-s", "-t", s.id, "-F", format)
+	if err != nil {
+		return nil, fmt.Errorf("could not list windows of session %q: %w", s.ID(), err)
+	}
+	var ret []Window
+	var lastID string
+	for _, line := range strings.Split(stdout, "\n") {
+		fields := strings.SplitN(line, "\t", 4)
+		if len(fields) != 4 {
+			continue
+		}
+		id, layout, dir, name := fields[0], fields[1], fields[2], fields[3]
+		if id != lastID {
+			ret = append(ret, Window{Name: name, Layout: layout})
+			lastID = id
+		}
+		w := &ret[len(ret)-1]
+		w.PaneDirs = append(w.PaneDirs, dir)
+	}
+	return ret, nil
+}
+
+// ReplaceWindows creates each of windows, splitting them into a pane for each
+// of their directories, and then kills the windows that already existed.
+func (s *session) ReplaceWindows(ctx context.Context, windows []Window) error {
+	stdout, err := s.srv.runStdout(ctx, "list-windows", "-t", s.id, "-F", "#{window_id}")
+	if err != nil {
+		return fmt.Errorf("could not list windows of session %q: %w", s.ID(), err)
+	}
+	old := strings.Split(stdout, "\n")
+	for _, w := range windows {
+		if err := s.newWindow(ctx, w); err != nil {
+			return fmt.Errorf("could not create window %q in session %q: %w", w.Name, s.ID(), err)
+		}
+	}
+	for _, id := range old {
+		if _, err := s.srv.runStdout(ctx, "kill-window", "-t", id); err != nil {
+			return fmt.Errorf("could not kill window %q in session %q: %w", id, s.ID(), err)
+		}
+	}
+	return nil
+}
+
+func (s *session) newWindow(ctx context.Context, w Window) error {
+	args := []string{"new-window", "-d", "-t", s.id + ":", "-P", "-F", "#{window_id}"}
+	if w.Name != "" {
+		args = append(args, "-n", w.Name)
+	}
+	if len(w.PaneDirs) > 0 {
+		args = append(args, "-c", w.PaneDirs[0])
+	}
+	id, err := s.srv.runStdout(ctx, args...)
+	if err != nil {
+		return err
+	}
+	for i := 1; i < len(w.PaneDirs); i++ {
+		if _, err := s.srv.runStdout(ctx, "split-window", "-d", "-t", id, "-c", w.PaneDirs[i]); err != nil {
+			return err
+		}
+		// Make sure there's room for the next pane.
+		if _, err := s.srv.runStdout(ctx, "select-layout", "-t", id, "tiled"); err != nil {
+			return err
+		}
+	}
+	if w.Layout != "" {
+		if _, err := s.srv.runStdout(ctx, "select-layout", "-t", id, w.Layout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *session) SetUserOption(ctx context.Context, name, value string) error {
+	_, err := s.srv.runStdout(ctx, "set-option", "-t", s.id, "@"+name, value)
+	if err != nil {
+		return fmt.Errorf("could not set option @%s of session %q: %w", name, s.ID(), err)
+	}
+	return nil
+}
+
+func (s *session) SetStartDir(ctx context.Context, dir string) error {
+	// Only attach-session can change a session's directory, so briefly attach a
+	// control mode client to the session. tmux might run commands from stdin
+	// before the one on the command line, and the client exits once stdin is
+	// closed, so the directory has to be changed from stdin.
+	cmd := s.srv.command(ctx, "-C", "attach-session", "-t", s.id, "-f", "no-output,ignore-size")
+	cmd.Stdin = strings.NewReader(FormatCommand("attach-session", "-t", s.id, "-c", dir) + "\n")
+	if _, err := cmd.RunStdout(); err != nil {
+		return fmt.Errorf("could not change directory of session %q to %q: %w", s.ID(), dir, err)
+	}
+	return nil
+}
+
 func (s *session) Rename(ctx context.Context, name string) error {
-	err := s.srv.command(ctx, "rename-session", "-t", s.id, name).Run()
+	_, err := s.srv.runStdout(ctx, "rename-session", "-t", s.id, name)
 	if err != nil {
 		return fmt.Errorf("could not rename session %q to %q: %w", s.ID(), name, err)
 	}
@@ -147,7 +258,7 @@ func (s *session) Rename(ctx context.Context, name string) error {
 }
 
 func (s *session) Kill(ctx context.Context) error {
-	err := s.srv.command(ctx, "kill-session", "-t", s.id).Run()
+	_, err := s.srv.runStdout(ctx, "kill-session", "-t", s.id)
 	if err != nil {
 		return fmt.Errorf("could not kill session %q: %w", s.ID(), err)
 	}
@@ -0,0 +1,109 @@
+package state
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxSessionNameLength is the longest session name, in bytes, that State will
+// give a tmux session. tmux doesn't limit session names itself, but really long
+// ones make the status line and session lists unusable.
+const maxSessionNameLength = 100
+
+// truncatedMarker separates a truncated session name from the hash of its full
+// name. It can't otherwise appear in an encoded session name because % is
+// always escaped.
+const truncatedMarker = "%~"
+
+// truncatedHashLength is the number of hex digits of the hash to keep.
+const truncatedHashLength = 8
+
+// encodeSessionName makes name safe to use as a tmux session name.
+//
+// tmux doesn't store some characters in session names as-is: it replaces . and
+// : with _, and escapes backslashes and non-printable characters. Those
+// characters, along with %, are percent-encoded so that the name survives the
+// round trip through tmux.
+//
+// Names that would be longer than maxSessionNameLength are truncated and
+// suffixed with a hash of the full name. Truncated names can't be decoded; they
+// have to be compared against the encoded names of the candidates instead.
+func encodeSessionName(name string) string {
+	var b strings.Builder
+	// ends are the offsets in b after each rune or escape sequence, so that
+	// truncation doesn't split them.
+	var ends []int
+	for _, r := range name {
+		if needsEscape(r) {
+			var buf [utf8.UTFMax]byte
+			for _, c := range buf[:utf8.EncodeRune(buf[:], r)] {
+				fmt.Fprintf(&b, "%%%02X", c)
+			}
+		} else {
+			b.WriteRune(r)
+		}
+		ends = append(ends, b.Len())
+	}
+	s := b.String()
+	if len(s) <= maxSessionNameLength {
+		return s
+	}
+
+	limit := maxSessionNameLength - len(truncatedMarker) - truncatedHashLength
+	var end int
+	for _, e := range ends {
+		if e > limit {
+			break
+		}
+		end = e
+	}
+	hash := sha256.Sum256([]byte(name))
+	return s[:end] + truncatedMarker + hex.EncodeToString(hash[:])[:truncatedHashLength]
+}
+
+func needsEscape(r rune) bool {
+	switch r {
+	case '%', '.', ':', '\\':
+		return true
+	}
+	return r < 0x20 || r == 0x7f || r == utf8.RuneError
+}
+
+// decodeSessionName is the inverse of encodeSessionName.
+// Returns false if s was truncated. Percent signs that aren't part of an escape
+// sequence are left alone, so that session names that weren't created by
+// encodeSessionName still decode to something sensible.
+func decodeSessionName(s string) (string, bool) {
+	if isTruncated(s) {
+		return "", false
+	}
+	if !strings.Contains(s, "%") {
+		return s, true
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) {
+			if c, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String(), true
+}
+
+// isTruncated determines whether s is a truncated session name.
+func isTruncated(s string) bool {
+	i := len(s) - len(truncatedMarker) - truncatedHashLength
+	if i < 0 || s[i:i+len(truncatedMarker)] != truncatedMarker {
+		return false
+	}
+	_, err := hex.DecodeString(s[i+len(truncatedMarker):])
+	return err == nil
+}
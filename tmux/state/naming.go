@@ -0,0 +1,217 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/JeffFaer/tmux-vcs-sync/api"
+)
+
+// Naming determines the tmux session names of work units.
+//
+// Templates are strings with placeholders in braces:
+//
+//	{vcs}        The name of the VCS. e.g. git
+//	{vcs_short}  The initials of the VCS's name. e.g. g
+//	{repo}       The name of the repository. e.g. tmux-vcs-sync
+//	{repo_short} The initials of the repository's name. e.g. tvs
+//	{work_unit}  The name of the work unit. e.g. main
+//
+// Each template must contain {work_unit} exactly once.
+type Naming struct {
+	// Qualified is the template for session names that need to say which
+	// repository they belong to.
+	Qualified string
+	// Unqualified is the template for session names that don't.
+	Unqualified string
+	// Qualify determines when Qualified is used instead of Unqualified.
+	Qualify Qualify
+}
+
+// Qualify determines when session names say which repository they belong to.
+type Qualify string
+
+const (
+	// QualifyAuto qualifies session names when there are sessions for more than
+	// one repository.
+	QualifyAuto Qualify = "auto"
+	// QualifyAlways always qualifies session names.
+	QualifyAlways Qualify = "always"
+	// QualifyNever never qualifies session names. Work units with the same name
+	// in different repositories can't both have sessions.
+	QualifyNever Qualify = "never"
+)
+
+// DefaultNaming names sessions repo>work-unit, but only when there are
+// sessions for more than one repository.
+var DefaultNaming = Naming{
+	Qualified:   "{repo}>{work_unit}",
+	Unqualified: "{work_unit}",
+	Qualify:     QualifyAuto,
+}
+
+const workUnitPlaceholder = "{work_unit}"
+
+var placeholders = map[string]func(RepoName) string{
+	"{vcs}":        func(n RepoName) string { return n.VCS },
+	"{vcs_short}":  func(n RepoName) string { return initials(n.VCS) },
+	"{repo}":       func(n RepoName) string { return n.Repo },
+	"{repo_short}": func(n RepoName) string { return initials(n.Repo) },
+}
+
+// initials abbreviates s to the first letter of each of its words.
+func initials(s string) string {
+	var b strings.Builder
+	start := true
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			start = true
+			continue
+		}
+		if start {
+			b.WriteRune(r)
+		}
+		start = false
+	}
+	return b.String()
+}
+
+// Validate checks that the templates can be used to name sessions.
+func (n Naming) Validate() error {
+	var errs []error
+	for _, tmpl := range []struct{ name, s string }{{"qualified", n.Qualified}, {"unqualified", n.Unqualified}} {
+		if err := validateTemplate(tmpl.s); err != nil {
+			errs = append(errs, fmt.Errorf("%s template %q: %w", tmpl.name, tmpl.s, err))
+		}
+	}
+	switch n.Qualify {
+	case QualifyAuto, QualifyAlways, QualifyNever:
+	default:
+		errs = append(errs, fmt.Errorf("qualify must be one of %s, %s, or %s, not %q", QualifyAuto, QualifyAlways, QualifyNever, n.Qualify))
+	}
+	return errors.Join(errs...)
+}
+
+func validateTemplate(tmpl string) error {
+	var workUnits int
+	for s := tmpl; s != ""; {
+		i := strings.IndexAny(s, "{}")
+		if i < 0 {
+			break
+		}
+		if s[i] == '}' {
+			return fmt.Errorf("unmatched }")
+		}
+		j := strings.IndexByte(s[i:], '}')
+		if j < 0 {
+			return fmt.Errorf("unmatched {")
+		}
+		p := s[i : i+j+1]
+		if p == workUnitPlaceholder {
+			workUnits++
+		} else if _, ok := placeholders[p]; !ok {
+			return fmt.Errorf("unknown placeholder %s", p)
+		}
+		s = s[i+j+1:]
+	}
+	if workUnits != 1 {
+		return fmt.Errorf("must contain %s exactly once", workUnitPlaceholder)
+	}
+	return nil
+}
+
+// qualified determines whether a session for repo should have a qualified name,
+// given the number of sessions that each repository has.
+func (n Naming) qualified(repos map[RepoName]int, repo RepoName) bool {
+	switch n.Qualify {
+	case QualifyAlways:
+		return true
+	case QualifyNever:
+		return false
+	}
+	return len(repos) > 1 || (len(repos) == 1 && repos[repo] == 0)
+}
+
+func (n Naming) template(qualified bool) string {
+	if qualified {
+		return n.Qualified
+	}
+	return n.Unqualified
+}
+
+// SessionName formats the tmux session name for wu. The name is encoded so
+// that tmux stores it as-is.
+func (n Naming) SessionName(wu WorkUnitName, qualified bool) string {
+	prefix, suffix := n.affixes(n.template(qualified), wu.RepoName)
+	return encodeSessionName(prefix + wu.WorkUnit + suffix)
+}
+
+// affixes renders the parts of tmpl before and after {work_unit}.
+func (n Naming) affixes(tmpl string, repo RepoName) (prefix, suffix string) {
+	prefix, suffix, _ = strings.Cut(tmpl, workUnitPlaceholder)
+	var r []string
+	for p, fn := range placeholders {
+		r = append(r, p, fn(repo))
+	}
+	replacer := strings.NewReplacer(r...)
+	return replacer.Replace(prefix), replacer.Replace(suffix)
+}
+
+// ParseSessionName is the inverse of SessionName: it determines which of
+// repo's work units a session name is for. Qualified names take precedence
+// over unqualified names.
+// Returns false if the session name doesn't match either template, or if it
+// was too long and had to be truncated.
+func (n Naming) ParseSessionName(repo api.Repository, tmuxSessionName string) (WorkUnitName, bool) {
+	rn := NewRepoName(repo)
+	name, ok := decodeSessionName(tmuxSessionName)
+	if !ok {
+		return WorkUnitName{}, false
+	}
+	// Without knowing about other clones of the repository, any label that
+	// repoLabel might have given it is possible.
+	labels := []string{rn.Repo}
+	dir := strings.Split(filepath.ToSlash(filepath.Clean(rn.Dir)), "/")
+	for k := 1; k <= len(dir); k++ {
+		labels = append(labels, strings.Join(dir[len(dir)-k:], "/"))
+	}
+	for _, l := range labels {
+		labeled := rn
+		labeled.Repo = l
+		if wu, ok := n.match(n.Qualified, labeled, name); ok {
+			return WorkUnitName{rn, wu}, true
+		}
+	}
+	if wu, ok := n.match(n.Unqualified, rn, name); ok {
+		return WorkUnitName{rn, wu}, true
+	}
+	return WorkUnitName{}, false
+}
+
+// parse parses a session name, preferring the template that the session should
+// be using.
+func (n Naming) parse(repo RepoName, tmuxSessionName string, qualified bool) (WorkUnitName, bool) {
+	name, ok := decodeSessionName(tmuxSessionName)
+	if !ok {
+		return WorkUnitName{}, false
+	}
+	for _, q := range []bool{qualified, !qualified} {
+		if wu, ok := n.match(n.template(q), repo, name); ok {
+			return WorkUnitName{repo, wu}, true
+		}
+	}
+	return WorkUnitName{}, false
+}
+
+// match extracts the work unit from a decoded session name that was formatted
+// with tmpl.
+func (n Naming) match(tmpl string, repo RepoName, name string) (string, bool) {
+	prefix, suffix := n.affixes(tmpl, repo)
+	if len(name) <= len(prefix)+len(suffix) || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) {
+		return "", false
+	}
+	return name[len(prefix) : len(name)-len(suffix)], true
+}
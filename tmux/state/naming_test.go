@@ -0,0 +1,74 @@
+package state
+
+import (
+	"testing"
+)
+
+func TestNaming_SessionName(t *testing.T) {
+	repo := RepoName{VCS: "git", Repo: "tmux-vcs-sync"}
+	for _, tc := range []struct {
+		naming    Naming
+		qualified bool
+		want      string
+	}{
+		{DefaultNaming, false, "main"},
+		{DefaultNaming, true, "tmux-vcs-sync>main"},
+		{Naming{Qualified: "{vcs}:{repo}/{work_unit}"}, true, "git%3Atmux-vcs-sync/main"},
+		{Naming{Qualified: "[{repo_short}] {work_unit}"}, true, "[tvs] main"},
+		{Naming{Qualified: "{work_unit} ({vcs_short})"}, true, "main (g)"},
+	} {
+		n := WorkUnitName{repo, "main"}
+		if got := tc.naming.SessionName(n, tc.qualified); got != tc.want {
+			t.Errorf("%+v.SessionName(%v, %t) = %q, want %q", tc.naming, n, tc.qualified, got, tc.want)
+		}
+	}
+}
+
+func TestNaming_RoundTrip(t *testing.T) {
+	repo := RepoName{VCS: "git", Repo: "repo"}
+	for _, naming := range []Naming{
+		DefaultNaming,
+		{Qualified: "{repo_short}/{work_unit}", Unqualified: "{work_unit}"},
+		{Qualified: "{vcs}:{repo}:{work_unit}:end", Unqualified: "<{work_unit}>"},
+	} {
+		for _, wu := range []string{"main", "repo>main", "r/x", "<>", "a b"} {
+			for _, qualified := range []bool{false, true} {
+				n := WorkUnitName{repo, wu}
+				s := naming.SessionName(n, qualified)
+				if got, ok := naming.parse(repo, s, qualified); !ok || got != n {
+					t.Errorf("%+v.parse(%q, %t) = %v, %t, want %v, true", naming, s, qualified, got, ok, n)
+				}
+			}
+		}
+	}
+}
+
+func TestNaming_Parse_Mismatch(t *testing.T) {
+	naming := Naming{Qualified: "{repo}/{work_unit}", Unqualified: "<{work_unit}>"}
+	repo := RepoName{Repo: "repo"}
+	for _, s := range []string{"main", "other/main", "<>", "repo/"} {
+		if got, ok := naming.parse(repo, s, true); ok {
+			t.Errorf("parse(%q) = %v, true, want false", s, got)
+		}
+	}
+}
+
+func TestNaming_Validate(t *testing.T) {
+	for _, tc := range []struct {
+		naming  Naming
+		wantErr bool
+	}{
+		{DefaultNaming, false},
+		{Naming{Qualified: "{repo_short}-{work_unit}", Unqualified: "{work_unit}", Qualify: QualifyAlways}, false},
+		{Naming{Qualified: "{repo}", Unqualified: "{work_unit}", Qualify: QualifyAuto}, true},
+		{Naming{Qualified: "{work_unit}{work_unit}", Unqualified: "{work_unit}", Qualify: QualifyAuto}, true},
+		{Naming{Qualified: "{branch}>{work_unit}", Unqualified: "{work_unit}", Qualify: QualifyAuto}, true},
+		{Naming{Qualified: "{repo>{work_unit}", Unqualified: "{work_unit}", Qualify: QualifyAuto}, true},
+		{Naming{Qualified: "{repo}>{work_unit}}", Unqualified: "{work_unit}", Qualify: QualifyAuto}, true},
+		{Naming{Qualified: "{repo}>{work_unit}", Unqualified: "{work_unit}", Qualify: "sometimes"}, true},
+	} {
+		if err := tc.naming.Validate(); (err != nil) != tc.wantErr {
+			t.Errorf("%+v.Validate() = %v, wantErr = %t", tc.naming, err, tc.wantErr)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log/slog"
 	"maps"
+	"path/filepath"
 	"runtime/trace"
 	"slices"
 	"strings"
@@ -20,19 +21,32 @@ import (
 type State struct {
 	srv      tmux.Server
 	sessions tmux.Sessions
+	naming   Naming
 
 	// tmux sessions in srv with their associated repositories.
 	sessionsByName map[WorkUnitName]session
 	sessionsByID   map[string]workUnit
-	// An index of unqualified repo names that exist in sessions.
-	unqualifiedRepos map[string]int
+	// The number of sessions that each repository has.
+	repoSessions map[RepoName]int
 	// Representative examples of each api.Repository in sessions.
 	repos map[RepoName]api.Repository
 
 	unknownSessions map[string]tmux.Session
+	// Sessions in a repository whose names don't match naming, keyed by ID.
+	mismatchedSessions map[string]mismatchedSession
 }
 
-func New(ctx context.Context, srv tmux.Server, vcs api.VersionControlSystems) (*State, error) {
+// An Option configures a State.
+type Option func(*State)
+
+// WithNaming makes the State name sessions with n instead of DefaultNaming.
+func WithNaming(n Naming) Option {
+	return func(st *State) {
+		st.naming = n
+	}
+}
+
+func New(ctx context.Context, srv tmux.Server, vcs api.VersionControlSystems, opts ...Option) (*State, error) {
 	defer trace.StartRegion(ctx, "state.New()").End()
 
 	sessions, err := srv.ListSessions(ctx)
@@ -41,13 +55,18 @@ func New(ctx context.Context, srv tmux.Server, vcs api.VersionControlSystems) (*
 	}
 
 	st := &State{
-		srv:              srv,
-		sessions:         sessions,
-		sessionsByName:   make(map[WorkUnitName]session),
-		sessionsByID:     make(map[string]workUnit),
-		unqualifiedRepos: make(map[string]int),
-		repos:            make(map[RepoName]api.Repository),
-		unknownSessions:  make(map[string]tmux.Session),
+		srv:                srv,
+		sessions:           sessions,
+		naming:             DefaultNaming,
+		sessionsByName:     make(map[WorkUnitName]session),
+		sessionsByID:       make(map[string]workUnit),
+		repoSessions:       make(map[RepoName]int),
+		repos:              make(map[RepoName]api.Repository),
+		unknownSessions:    make(map[string]tmux.Session),
+		mismatchedSessions: make(map[string]mismatchedSession),
+	}
+	for _, opt := range opts {
+		opt(st)
 	}
 	props, err := sessions.Properties(ctx, tmux.SessionName, tmux.SessionPath)
 	if err != nil {
@@ -89,11 +108,19 @@ func New(ctx context.Context, srv tmux.Server, vcs api.VersionControlSystems) (*
 		close(results)
 	}()
 
+	// Whether session names should be qualified depends on all of the
+	// repositories, so they all need to be known before parsing any names.
+	var found []result
+	repoSessions := make(map[RepoName]int)
 	for result := range results {
-		sessions, repo := result.sessions, result.Repository
-		if repo != nil {
-			st.repos[NewRepoName(repo)] = repo
+		if repo := result.Repository; repo != nil {
+			repoSessions[NewRepoName(repo)] += len(result.sessions)
 		}
+		found = append(found, result)
+	}
+
+	for _, result := range found {
+		sessions, repo := result.sessions, result.Repository
 		for _, sesh := range sessions {
 			name := tmux.SinglePropertyValue(tmux.SessionName, props[sesh])
 			logger := slog.With("id", sesh.ID(), "session_name", name)
@@ -103,23 +130,110 @@ func New(ctx context.Context, srv tmux.Server, vcs api.VersionControlSystems) (*
 				continue
 			}
 
-			parsed := ParseSessionName(repo, name)
-			st.sessionsByName[parsed] = session{sesh, name}
-			st.sessionsByID[sesh.ID()] = workUnit{repo, parsed.WorkUnit}
-			st.unqualifiedRepos[parsed.Repo]++
+			parsed, ok := parseSessionName(st.naming, NewRepoName(repo), name, repoSessions)
+			if !ok && isTruncated(name) {
+				parsed, ok = st.resolveTruncated(ctx, repo, name, repoSessions)
+			}
+			if !ok {
+				st.unknownSessions[name] = sesh
+				st.mismatchedSessions[sesh.ID()] = mismatchedSession{session{sesh, name}, repo}
+				logger.Warn("Session name doesn't match the naming templates.")
+				continue
+			}
+			st.addSession(parsed, session{sesh, name}, repo)
 			logger.Info("Found work unit in tmux session.", "name", parsed)
 		}
 	}
 	return st, nil
 }
 
+// resolveTruncated finds the work unit in repo whose session name was
+// truncated to name.
+func (st *State) resolveTruncated(ctx context.Context, repo api.Repository, name string, repoSessions map[RepoName]int) (WorkUnitName, bool) {
+	defer trace.StartRegion(ctx, "State.resolveTruncated()").End()
+	wus, err := repo.List(ctx, "")
+	if err != nil {
+		slog.Warn("Could not list work units for repository.", "repo", repo.Name(), "error", err)
+		return WorkUnitName{}, false
+	}
+	for _, wu := range wus {
+		n := NewWorkUnitName(repo, wu)
+		l := labeled(n, repoSessions)
+		if st.naming.SessionName(l, true) == name || st.naming.SessionName(l, false) == name {
+			return n, true
+		}
+	}
+	return WorkUnitName{}, false
+}
+
+func (st *State) addSession(n WorkUnitName, sesh session, repo api.Repository) {
+	st.sessionsByName[n] = sesh
+	st.sessionsByID[sesh.sesh.ID()] = workUnit{repo, n.WorkUnit}
+	st.repoSessions[n.RepoName]++
+	st.repos[n.RepoName] = repo
+}
+
 // SessionName returns the string that this State would use for the tmux
 // session name if a work unit with the given name were created right now.
 func (st *State) SessionName(n WorkUnitName) string {
-	if len(st.unqualifiedRepos) > 1 || (len(st.unqualifiedRepos) == 1 && st.unqualifiedRepos[n.Repo] == 0) {
-		return n.RepoString()
+	return sessionName(st.naming, n, st.repoSessions)
+}
+
+// sessionName names a session for n, given the number of sessions that each
+// repository has.
+func sessionName(naming Naming, n WorkUnitName, repoSessions map[RepoName]int) string {
+	return naming.SessionName(labeled(n, repoSessions), naming.qualified(repoSessions, n.RepoName))
+}
+
+// parseSessionName is the inverse of sessionName.
+func parseSessionName(naming Naming, repo RepoName, tmuxSessionName string, repoSessions map[RepoName]int) (WorkUnitName, bool) {
+	n, ok := naming.parse(labeled(WorkUnitName{RepoName: repo}, repoSessions).RepoName, tmuxSessionName, naming.qualified(repoSessions, repo))
+	n.RepoName = repo
+	return n, ok
+}
+
+// RepoLabel returns the name that session names use for a repository. It's
+// the repository's name, unless there are multiple clones of the repository.
+func (st *State) RepoLabel(n RepoName) string {
+	return repoLabel(n, st.repoSessions)
+}
+
+// labeled replaces n's repository name with its label.
+func labeled(n WorkUnitName, repoSessions map[RepoName]int) WorkUnitName {
+	n.Repo = repoLabel(n.RepoName, repoSessions)
+	return n
+}
+
+// repoLabel distinguishes between clones of a repository by using the end of
+// their root directories instead of their names. e.g. ~/src/app and
+// ~/src/app-hotfix are labeled app and app-hotfix, and ~/a/app and ~/b/app are
+// labeled a/app and b/app.
+func repoLabel(n RepoName, repoSessions map[RepoName]int) string {
+	var clones []string
+	for other := range repoSessions {
+		if other.VCS == n.VCS && other.Repo == n.Repo && other.Dir != n.Dir {
+			clones = append(clones, other.Dir)
+		}
+	}
+	if len(clones) == 0 {
+		return n.Repo
+	}
+	dir := strings.Split(filepath.ToSlash(filepath.Clean(n.Dir)), "/")
+	for k := 1; k <= len(dir); k++ {
+		label := strings.Join(dir[len(dir)-k:], "/")
+		unique := !slices.ContainsFunc(clones, func(c string) bool {
+			return strings.HasSuffix("/"+filepath.ToSlash(filepath.Clean(c)), "/"+label)
+		})
+		if unique {
+			return label
+		}
 	}
-	return n.WorkUnitString()
+	return n.Dir
+}
+
+// Server returns the tmux server this State describes.
+func (st *State) Server() tmux.Server {
+	return st.srv
 }
 
 // Sessions returns all tmux sessions keyed by their work unit.
@@ -137,6 +251,16 @@ func (st *State) UnknownSessions() map[string]tmux.Session {
 	return maps.Clone(st.unknownSessions)
 }
 
+// MismatchedSessions returns the tmux sessions that are in a repository, but
+// whose names don't match the naming templates. They're also unknown sessions.
+func (st *State) MismatchedSessions() map[string]tmux.Session {
+	ret := make(map[string]tmux.Session, len(st.mismatchedSessions))
+	for _, sesh := range st.mismatchedSessions {
+		ret[sesh.name] = sesh.sesh
+	}
+	return ret
+}
+
 // WorkUnit returns work unit metadata for the given session.
 func (st *State) WorkUnit(ctx context.Context, sesh tmux.Session) (api.Repository, string, error) {
 	defer trace.StartRegion(ctx, "State.WorkUnit()").End()
@@ -182,16 +306,56 @@ func (st *State) NewSession(ctx context.Context, repo api.Repository, workUnitNa
 		return nil, fmt.Errorf("failed to create tmux session %q: %w", n, err)
 	}
 
-	st.sessionsByName[name] = session{sesh, n}
-	st.sessionsByID[sesh.ID()] = workUnit{repo, name.WorkUnit}
-	st.unqualifiedRepos[name.Repo]++
-	st.repos[name.RepoName] = repo
+	st.addSession(name, session{sesh, n}, repo)
+	storeWorkUnit(ctx, sesh, workUnitName)
 	if err := st.updateSessionNames(ctx); err != nil {
 		slog.Warn("Failed to update tmux session names.", "error", err)
 	}
 	return sesh, nil
 }
 
+// AdoptSession associates sesh, which isn't for any work unit, with the given
+// work unit: new windows in sesh start in the repository, and sesh is renamed
+// to represent the work unit.
+// Returns an error if sesh is already for a work unit, or if there's already a
+// tmux session for the work unit.
+func (st *State) AdoptSession(ctx context.Context, sesh tmux.Session, repo api.Repository, workUnitName string) error {
+	defer trace.StartRegion(ctx, "State.AdoptSession()").End()
+
+	name := NewWorkUnitName(repo, workUnitName)
+	if _, ok := st.sessionsByName[name]; ok {
+		return fmt.Errorf("tmux session %q already exists", st.SessionName(name))
+	}
+	var oldName string
+	for n, s := range st.unknownSessions {
+		if s.ID() == sesh.ID() {
+			oldName = n
+			break
+		}
+	}
+	if oldName == "" {
+		return fmt.Errorf("tmux session %q is already for a work unit", sesh.ID())
+	}
+
+	n := st.SessionName(name)
+	slog.Info("Adopting tmux session.", "session_id", sesh.ID(), "session_name", oldName, "name", name)
+	if err := sesh.SetStartDir(ctx, repo.RootDir()); err != nil {
+		return err
+	}
+	storeWorkUnit(ctx, sesh, workUnitName)
+	if err := sesh.Rename(ctx, n); err != nil {
+		return err
+	}
+
+	delete(st.unknownSessions, oldName)
+	delete(st.mismatchedSessions, sesh.ID())
+	st.addSession(name, session{sesh, n}, repo)
+	if err := st.updateSessionNames(ctx); err != nil {
+		slog.Warn("Failed to update tmux session names.", "error", err)
+	}
+	return nil
+}
+
 // RenameSession finds a tmux session for work unit old and then renames that
 // session so that it represents work unit new.
 // Returns an error if the "old" tmux session doesn't exist or if there's
@@ -199,7 +363,7 @@ func (st *State) NewSession(ctx context.Context, repo api.Repository, workUnitNa
 func (st *State) RenameSession(ctx context.Context, repo api.Repository, old, new string) error {
 	defer trace.StartRegion(ctx, "State.RenameSession()").End()
 
-	oldName := ParseSessionName(repo, old)
+	oldName := NewWorkUnitName(repo, old)
 	sesh, ok := st.sessionsByName[oldName]
 	if !ok {
 		return fmt.Errorf("tmux session %q does not exist", st.SessionName(oldName))
@@ -210,7 +374,11 @@ func (st *State) RenameSession(ctx context.Context, repo api.Repository, old, ne
 	}
 
 	n := st.SessionName(newName)
+	// Store the new work unit first so that the rename doesn't look like it
+	// came from outside of this tool.
+	storeWorkUnit(ctx, sesh.sesh, new)
 	if err := sesh.sesh.Rename(ctx, n); err != nil {
+		storeWorkUnit(ctx, sesh.sesh, old)
 		return err
 	}
 
@@ -225,8 +393,122 @@ func (st *State) RenameSession(ctx context.Context, repo api.Repository, old, ne
 	return nil
 }
 
+// workUnitOption is the tmux user option where each session's work unit is
+// stored. Unlike the session's name, it can't be changed by tmux's
+// rename-session.
+const workUnitOption = "tmux-vcs-sync-work-unit"
+
+// storeWorkUnit records that sesh is for workUnitName.
+func storeWorkUnit(ctx context.Context, sesh tmux.Session, workUnitName string) {
+	if err := sesh.SetUserOption(ctx, workUnitOption, workUnitName); err != nil {
+		slog.Warn("Could not store work unit in tmux session.", "session_id", sesh.ID(), "work_unit", workUnitName, "error", err)
+	}
+}
+
+// StoredWorkUnit returns the name of the work unit that this State last
+// recorded for sesh, which might not match sesh's name if it was renamed
+// outside of this State. Returns "" if no work unit was recorded, e.g. because
+// sesh was created by an older version of this tool.
+func (st *State) StoredWorkUnit(ctx context.Context, sesh tmux.Session) (string, error) {
+	prop := tmux.UserOption(workUnitOption)
+	val, err := sesh.Property(ctx, prop)
+	if err != nil {
+		return "", err
+	}
+	return tmux.PropertyValue(prop, val), nil
+}
+
+// RecordWorkUnit records that sesh, which was renamed outside of this State,
+// is now for the given work unit.
+func (st *State) RecordWorkUnit(ctx context.Context, sesh tmux.Session, workUnitName string) error {
+	return sesh.SetUserOption(ctx, workUnitOption, workUnitName)
+}
+
+// RevertSessionName renames sesh, which was renamed outside of this State, so
+// that it represents workUnitName again.
+// Returns an error if sesh isn't in a repository, or if another tmux session
+// is already for workUnitName.
+func (st *State) RevertSessionName(ctx context.Context, sesh tmux.Session, workUnitName string) error {
+	defer trace.StartRegion(ctx, "State.RevertSessionName()").End()
+
+	var repo api.Repository
+	var cur *WorkUnitName
+	if wu, ok := st.sessionsByID[sesh.ID()]; ok {
+		repo = wu.repo
+		n := NewWorkUnitName(repo, wu.workUnitName)
+		cur = &n
+	} else if m, ok := st.mismatchedSessions[sesh.ID()]; ok {
+		repo = m.repo
+	} else {
+		return fmt.Errorf("tmux session %q isn't in a repository", sesh.ID())
+	}
+	name := NewWorkUnitName(repo, workUnitName)
+	if other, ok := st.sessionsByName[name]; ok && other.sesh.ID() != sesh.ID() {
+		return fmt.Errorf("tmux session %q already exists", st.SessionName(name))
+	}
+
+	slog.Info("Reverting tmux session name.", "session_id", sesh.ID(), "name", name)
+	if cur != nil {
+		st.removeSession(*cur, sesh)
+	} else {
+		delete(st.unknownSessions, st.mismatchedSessions[sesh.ID()].name)
+		delete(st.mismatchedSessions, sesh.ID())
+	}
+	// updateSessionNames gives sesh its name.
+	st.addSession(name, session{sesh, ""}, repo)
+	storeWorkUnit(ctx, sesh, workUnitName)
+	return st.updateSessionNames(ctx)
+}
+
+// PruneSessions kills the tmux sessions whose work units no longer exist.
 func (st *State) PruneSessions(ctx context.Context) error {
 	defer trace.StartRegion(ctx, "State.PruneSessions()").End()
+	return st.KillSessions(ctx, st.PlanPrune(ctx))
+}
+
+// A StaleSession is a tmux session whose work unit no longer exists.
+type StaleSession struct {
+	WorkUnit    WorkUnitName
+	SessionName string
+	Session     tmux.Session
+}
+
+// PlanPrune determines which tmux sessions PruneSessions would kill, in the
+// order it would kill them.
+func (st *State) PlanPrune(ctx context.Context) []StaleSession {
+	var plan []StaleSession
+	for n, sesh := range st.StaleSessions(ctx) {
+		plan = append(plan, StaleSession{n, st.sessionsByName[n].name, sesh})
+	}
+	slices.SortFunc(plan, morecmp.Comparing(func(s StaleSession) string { return s.SessionName }))
+	if curSesh := tmux.MaybeCurrentSession(); curSesh != nil {
+		// Delete the current session last so we don't terminate this command
+		// early.
+		isCurrent := func(s StaleSession) bool { return tmux.SameSession(ctx, curSesh, s.Session) }
+		slices.SortStableFunc(plan, morecmp.ComparingFunc(isCurrent, morecmp.FalseFirst()))
+	}
+	return plan
+}
+
+// KillSessions kills the sessions in plan, in order.
+func (st *State) KillSessions(ctx context.Context, plan []StaleSession) error {
+	defer trace.StartRegion(ctx, "State.KillSessions()").End()
+
+	for _, s := range plan {
+		if err := st.killSession(ctx, s.WorkUnit, s.Session); err != nil {
+			return err
+		}
+	}
+	if err := st.updateSessionNames(ctx); err != nil {
+		slog.Warn("Failed to update tmux session names.", "error", err)
+	}
+	return nil
+}
+
+// StaleSessions returns the tmux sessions whose work units no longer exist.
+// Repositories whose work units can't be listed are skipped.
+func (st *State) StaleSessions(ctx context.Context) map[WorkUnitName]tmux.Session {
+	defer trace.StartRegion(ctx, "State.StaleSessions()").End()
 
 	validWorkUnits := make(map[WorkUnitName]bool)
 	errRepos := make(map[RepoName]bool)
@@ -241,45 +523,57 @@ func (st *State) PruneSessions(ctx context.Context) error {
 			validWorkUnits[NewWorkUnitName(repo, wu)] = true
 		}
 	}
-	invalidSessions := make(map[tmux.Session]WorkUnitName)
-	var toRemove []tmux.Session
-	for n, sesh := range st.Sessions() {
+	stale := make(map[WorkUnitName]tmux.Session)
+	for n, sesh := range st.sessionsByName {
 		if errRepos[n.RepoName] {
 			continue
 		}
 		if !validWorkUnits[n] {
-			invalidSessions[sesh] = n
-			toRemove = append(toRemove, sesh)
+			stale[n] = sesh.sesh
 		}
 	}
-	if curSesh := tmux.MaybeCurrentSession(); curSesh != nil {
-		// Delete the current session last so we don't terminate this command
-		// early.
-		isCurrent := func(s tmux.Session) bool { return tmux.SameSession(ctx, curSesh, s) }
-		slices.SortFunc(toRemove, morecmp.ComparingFunc(isCurrent, morecmp.FalseFirst()))
-	}
+	return stale
+}
 
-	for _, sesh := range toRemove {
-		n := invalidSessions[sesh]
-		slog.Warn("Killing session.", "session_id", sesh.ID(), "name", n)
-		if err := sesh.Kill(ctx); err != nil {
-			return err
-		}
-		delete(st.sessionsByName, n)
-		delete(st.sessionsByID, sesh.ID())
-		st.unqualifiedRepos[n.Repo]--
-		if st.unqualifiedRepos[n.Repo] == 0 {
-			delete(st.unqualifiedRepos, n.Repo)
-			delete(st.repos, n.RepoName)
-		}
-	}
+// KillSession kills the tmux session for the given work unit.
+// Returns an error if the session doesn't exist.
+func (st *State) KillSession(ctx context.Context, repo api.Repository, workUnitName string) error {
+	defer trace.StartRegion(ctx, "State.KillSession()").End()
 
+	n := NewWorkUnitName(repo, workUnitName)
+	sesh, ok := st.sessionsByName[n]
+	if !ok {
+		return fmt.Errorf("tmux session %q does not exist", st.SessionName(n))
+	}
+	if err := st.killSession(ctx, n, sesh.sesh); err != nil {
+		return err
+	}
 	if err := st.updateSessionNames(ctx); err != nil {
 		slog.Warn("Failed to update tmux session names.", "error", err)
 	}
 	return nil
 }
 
+func (st *State) killSession(ctx context.Context, n WorkUnitName, sesh tmux.Session) error {
+	slog.Warn("Killing session.", "session_id", sesh.ID(), "name", n)
+	if err := sesh.Kill(ctx); err != nil {
+		return err
+	}
+	st.removeSession(n, sesh)
+	return nil
+}
+
+// removeSession is the inverse of addSession.
+func (st *State) removeSession(n WorkUnitName, sesh tmux.Session) {
+	delete(st.sessionsByName, n)
+	delete(st.sessionsByID, sesh.ID())
+	st.repoSessions[n.RepoName]--
+	if st.repoSessions[n.RepoName] == 0 {
+		delete(st.repoSessions, n.RepoName)
+		delete(st.repos, n.RepoName)
+	}
+}
+
 func (st *State) updateSessionNames(ctx context.Context) error {
 	defer trace.StartRegion(ctx, "State.updateSessionNames()").End()
 
@@ -290,11 +584,86 @@ func (st *State) updateSessionNames(ctx context.Context) error {
 				errs = append(errs, err)
 				continue
 			}
+			sesh.name = want
+			st.sessionsByName[k] = sesh
 		}
 	}
 	return errors.Join(errs...)
 }
 
+// MigrateNames renames sessions whose names were created with a different
+// Naming so that they match this State's Naming.
+// Sessions are checked against both Namings, preferring whichever one results
+// in a work unit that exists.
+func (st *State) MigrateNames(ctx context.Context, from Naming) error {
+	defer trace.StartRegion(ctx, "State.MigrateNames()").End()
+
+	// Use from's idea of whether names were qualified. That's how the sessions
+	// were named before the Naming changed.
+	repoSessions := make(map[RepoName]int)
+	var sessions []mismatchedSession
+	for n, sesh := range st.sessionsByName {
+		repoSessions[n.RepoName]++
+		sessions = append(sessions, mismatchedSession{sesh, st.sessionsByID[sesh.sesh.ID()].repo})
+	}
+	for _, sesh := range st.mismatchedSessions {
+		repoSessions[NewRepoName(sesh.repo)]++
+		sessions = append(sessions, sesh)
+	}
+	slices.SortFunc(sessions, morecmp.Comparing(func(s mismatchedSession) string { return s.sesh.ID() }))
+
+	var errs []error
+	migrated := make(map[WorkUnitName]session)
+	byID := make(map[string]workUnit)
+	for _, sesh := range sessions {
+		repoName := NewRepoName(sesh.repo)
+		var candidates []WorkUnitName
+		if n, ok := parseSessionName(st.naming, repoName, sesh.name, st.repoSessions); ok {
+			candidates = append(candidates, n)
+		}
+		if n, ok := parseSessionName(from, repoName, sesh.name, repoSessions); ok {
+			candidates = append(candidates, n)
+		}
+		if len(candidates) == 0 {
+			slog.Warn("Session name doesn't match either naming.", "id", sesh.sesh.ID(), "session_name", sesh.name)
+			continue
+		}
+		n := candidates[0]
+		for _, c := range candidates {
+			ok, err := sesh.repo.Exists(ctx, c.WorkUnit)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if ok {
+				n = c
+				break
+			}
+		}
+		if other, ok := migrated[n]; ok {
+			slog.Warn("Multiple sessions are for the same work unit.", "name", n, "id", sesh.sesh.ID(), "other_id", other.sesh.ID())
+			continue
+		}
+		slog.Info("Found work unit in tmux session.", "id", sesh.sesh.ID(), "session_name", sesh.name, "name", n)
+		migrated[n] = sesh.session
+		byID[sesh.sesh.ID()] = workUnit{sesh.repo, n.WorkUnit}
+	}
+
+	st.sessionsByName = make(map[WorkUnitName]session)
+	st.sessionsByID = make(map[string]workUnit)
+	st.repoSessions = make(map[RepoName]int)
+	st.repos = make(map[RepoName]api.Repository)
+	for n, sesh := range migrated {
+		delete(st.unknownSessions, sesh.name)
+		delete(st.mismatchedSessions, sesh.sesh.ID())
+		st.addSession(n, sesh, byID[sesh.sesh.ID()].repo)
+	}
+	if err := st.updateSessionNames(ctx); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
 // MaybeFindRepository attempts to find an api.Repository that's currently
 // active in tmux and claims that the given work unit exists.
 // Returns an error if multiple api.Repositories claim that the given work unit
@@ -316,16 +685,20 @@ func (st *State) MaybeFindRepository(ctx context.Context, workUnitName string) (
 	return repo, nil
 }
 
+// RepoName identifies a repository. Clones of the same repository have the same
+// VCS and Repo, but different Dirs.
 type RepoName struct {
 	VCS, Repo string
+	// Dir is the repository's root directory.
+	Dir string
 }
 
 func NewRepoName(repo api.Repository) RepoName {
-	return RepoName{VCS: repo.VCS().Name(), Repo: repo.Name()}
+	return RepoName{VCS: repo.VCS().Name(), Repo: repo.Name(), Dir: repo.RootDir()}
 }
 
 func (n RepoName) LogValue() slog.Value {
-	return slog.GroupValue(slog.String("vcs", n.VCS), slog.String("repo", n.Repo))
+	return slog.GroupValue(slog.String("vcs", n.VCS), slog.String("repo", n.Repo), slog.String("dir", n.Dir))
 }
 
 type WorkUnitName struct {
@@ -333,41 +706,12 @@ type WorkUnitName struct {
 	WorkUnit string
 }
 
-func ParseSessionName(repo api.Repository, tmuxSessionName string) WorkUnitName {
-	n := WorkUnitName{RepoName: NewRepoName(repo)}
-
-	sp := strings.SplitN(tmuxSessionName, ">", 3)
-	switch len(sp) {
-	case 1:
-		n.WorkUnit = sp[0]
-	case 2:
-		if n.Repo != sp[0] {
-			slog.Warn("Session name does not agree with repository.", "session_name", tmuxSessionName, "repo", n.Repo)
-		}
-		n.WorkUnit = sp[1]
-	default:
-		if n.VCS != sp[1] || n.Repo != sp[1] {
-			slog.Warn("Session name does not agree with repository.", "session_name", tmuxSessionName, "vcs", n.VCS, "repo", n.Repo)
-		}
-		n.WorkUnit = sp[2]
-	}
-	return n
-}
-
 func NewWorkUnitName(repo api.Repository, workUnitName string) WorkUnitName {
 	return WorkUnitName{NewRepoName(repo), workUnitName}
 }
 
-func (n WorkUnitName) RepoString() string {
-	return fmt.Sprintf("%s>%s", n.Repo, n.WorkUnit)
-}
-
-func (n WorkUnitName) WorkUnitString() string {
-	return n.WorkUnit
-}
-
 func (n WorkUnitName) LogValue() slog.Value {
-	return slog.GroupValue(slog.String("vcs", n.VCS), slog.String("repo", n.Repo), slog.String("work_unit", n.WorkUnit))
+	return slog.GroupValue(slog.String("vcs", n.VCS), slog.String("repo", n.Repo), slog.String("dir", n.Dir), slog.String("work_unit", n.WorkUnit))
 }
 
 type workUnit struct {
@@ -383,3 +727,8 @@ type session struct {
 	sesh tmux.Session
 	name string
 }
+
+type mismatchedSession struct {
+	session
+	repo api.Repository
+}
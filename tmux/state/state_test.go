@@ -4,6 +4,9 @@ import (
 	stdcmp "cmp"
 	"context"
 	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
 	"testing"
 	"time"
 
@@ -118,7 +121,7 @@ func TestNew(t *testing.T) {
 			if err != nil {
 				t.Errorf("New() = _, %v", err)
 			}
-			if diff := cmp.Diff(tc.want, simplifyState(t, st), compareSimplifiedStates, cmpopts.IgnoreFields(RepoName{}, "VCS")); diff != "" {
+			if diff := cmp.Diff(tc.want, simplifyState(t, st), compareSimplifiedStates, cmpopts.IgnoreFields(RepoName{}, "VCS", "Dir")); diff != "" {
 				t.Errorf("State diff (-want +got)\n%s", diff)
 			}
 		})
@@ -292,7 +295,7 @@ func TestNewSession(t *testing.T) {
 				t.Errorf("NewSession(%q, %q) = %v, wantErr %t", tc.repoDir, tc.workUnitName, err, tc.wantErr)
 			}
 
-			if diff := cmp.Diff(tc.want, simplifyState(t, st), compareSimplifiedStates, cmpopts.IgnoreFields(RepoName{}, "VCS")); diff != "" {
+			if diff := cmp.Diff(tc.want, simplifyState(t, st), compareSimplifiedStates, cmpopts.IgnoreFields(RepoName{}, "VCS", "Dir")); diff != "" {
 				t.Errorf("State diff (-want +got)\n%s", diff)
 			}
 			if diff := cmp.Diff(tc.wantTmux, simplifyTmuxState(ctx, tc.tmux), compareSimplifiedTmuxState); diff != "" {
@@ -398,7 +401,7 @@ func TestRename(t *testing.T) {
 			},
 
 			repoDir: "testing/repo1",
-			old:     "repo1>foo",
+			old:     "foo",
 			new:     "baz",
 
 			want: simplifiedState{
@@ -517,7 +520,7 @@ func TestRename(t *testing.T) {
 				t.Errorf("RenameSession(%q, %q, %q) = %v, wantErr = %t", tc.repoDir, tc.old, tc.new, err, tc.wantErr)
 			}
 
-			if diff := cmp.Diff(tc.want, simplifyState(t, st), compareSimplifiedStates, cmpopts.IgnoreFields(RepoName{}, "VCS")); diff != "" {
+			if diff := cmp.Diff(tc.want, simplifyState(t, st), compareSimplifiedStates, cmpopts.IgnoreFields(RepoName{}, "VCS", "Dir")); diff != "" {
 				t.Errorf("State diff (-want +got)\n%s", diff)
 			}
 			if diff := cmp.Diff(tc.wantTmux, simplifyTmuxState(ctx, tc.tmux), compareSimplifiedTmuxState); diff != "" {
@@ -527,6 +530,314 @@ func TestRename(t *testing.T) {
 	}
 }
 
+func TestClones(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	srv := newServer(
+		tmux.NewSessionOptions{Name: "foo", StartDir: "testing/a/app"},
+	)
+	vcs := api.VersionControlSystems{repotest.NewVCS("testing/")}
+	st, err := New(ctx, srv, vcs)
+	if err != nil {
+		t.Fatalf("New() = _, %v", err)
+	}
+	clone, err := vcs.MaybeFindRepository(ctx, "testing/b/app")
+	if err != nil {
+		t.Fatalf("MaybeFindRepository() = _, %v", err)
+	}
+	if _, err := st.NewSession(ctx, clone, "foo"); err != nil {
+		t.Errorf("NewSession() = _, %v", err)
+	}
+
+	wantTmux := simplifiedTmuxState{
+		Sessions: []simplifiedSessionState{
+			{Name: "a/app>foo", Dir: "testing/a/app"},
+			{Name: "b/app>foo", Dir: "testing/b/app"},
+		},
+	}
+	if diff := cmp.Diff(wantTmux, simplifyTmuxState(ctx, srv), compareSimplifiedTmuxState); diff != "" {
+		t.Errorf("tmux diff (-want +got)\n%s", diff)
+	}
+
+	// The clones should still be told apart after reading the session names back
+	// from tmux.
+	st, err = New(ctx, srv, vcs)
+	if err != nil {
+		t.Fatalf("New() = _, %v", err)
+	}
+	want := []WorkUnitName{
+		{RepoName: RepoName{Repo: "app", Dir: "testing/a/app"}, WorkUnit: "foo"},
+		{RepoName: RepoName{Repo: "app", Dir: "testing/b/app"}, WorkUnit: "foo"},
+	}
+	got := simplifyState(t, st).WorkUnits
+	sortByDir := cmpopts.SortSlices(morecmp.Comparing(func(n WorkUnitName) string { return n.Dir }).LessFunc())
+	if diff := cmp.Diff(want, got, sortByDir, cmpopts.IgnoreFields(RepoName{}, "VCS")); diff != "" {
+		t.Errorf("Work units diff (-want +got)\n%s", diff)
+	}
+	for _, n := range got {
+		if got, want := st.RepoLabel(n.RepoName), filepath.Base(filepath.Dir(n.Dir))+"/app"; got != want {
+			t.Errorf("RepoLabel(%v) = %q, want %q", n.RepoName, got, want)
+		}
+	}
+	if got := len(st.Repositories()); got != 2 {
+		t.Errorf("len(Repositories()) = %d, want 2", got)
+	}
+}
+
+func TestRepoLabel(t *testing.T) {
+	repoSessions := map[RepoName]int{
+		{Repo: "app", Dir: "/src/app"}:        1,
+		{Repo: "app", Dir: "/src/app-hotfix"}: 1,
+		{Repo: "app", Dir: "/other/app"}:      1,
+		{Repo: "lib", Dir: "/src/lib"}:        1,
+	}
+	for _, tc := range []struct {
+		n    RepoName
+		want string
+	}{
+		{RepoName{Repo: "app", Dir: "/src/app"}, "src/app"},
+		{RepoName{Repo: "app", Dir: "/src/app-hotfix"}, "app-hotfix"},
+		{RepoName{Repo: "app", Dir: "/other/app"}, "other/app"},
+		{RepoName{Repo: "lib", Dir: "/src/lib"}, "lib"},
+		// A new clone that doesn't have any sessions yet.
+		{RepoName{Repo: "lib", Dir: "/src/lib2"}, "lib2"},
+	} {
+		if got := repoLabel(tc.n, repoSessions); got != tc.want {
+			t.Errorf("repoLabel(%v) = %q, want %q", tc.n, got, tc.want)
+		}
+	}
+}
+
+func TestNew_Naming(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	srv := newServer(
+		tmux.NewSessionOptions{Name: "r/foo", StartDir: "testing/repo"},
+		// Unqualified names are still understood.
+		tmux.NewSessionOptions{Name: "bar", StartDir: "testing/repo"},
+	)
+	vcs := api.VersionControlSystems{repotest.NewVCS("testing/")}
+	st, err := New(ctx, srv, vcs, WithNaming(Naming{Qualified: "{repo_short}/{work_unit}", Unqualified: "{work_unit}", Qualify: QualifyAlways}))
+	if err != nil {
+		t.Fatalf("New() = _, %v", err)
+	}
+
+	want := simplifiedState{
+		WorkUnits: []WorkUnitName{
+			{RepoName: RepoName{Repo: "repo"}, WorkUnit: "foo"},
+			{RepoName: RepoName{Repo: "repo"}, WorkUnit: "bar"},
+		},
+		UnqualifiedRepos: []string{"repo"},
+		Repos:            []RepoName{{Repo: "repo"}},
+	}
+	if diff := cmp.Diff(want, simplifyState(t, st), compareSimplifiedStates, cmpopts.IgnoreFields(RepoName{}, "VCS", "Dir")); diff != "" {
+		t.Errorf("State diff (-want +got)\n%s", diff)
+	}
+	if got, want := st.SessionName(WorkUnitName{RepoName{Repo: "repo"}, "baz"}), "r/baz"; got != want {
+		t.Errorf("SessionName() = %q, want %q", got, want)
+	}
+}
+
+func TestNew_MismatchedNaming(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	srv := newServer(
+		tmux.NewSessionOptions{Name: "<foo>", StartDir: "testing/repo"},
+		tmux.NewSessionOptions{Name: "bar", StartDir: "testing/repo"},
+	)
+	vcs := api.VersionControlSystems{repotest.NewVCS("testing/")}
+	st, err := New(ctx, srv, vcs, WithNaming(Naming{Qualified: "{repo}/{work_unit}", Unqualified: "<{work_unit}>", Qualify: QualifyAuto}))
+	if err != nil {
+		t.Fatalf("New() = _, %v", err)
+	}
+
+	want := simplifiedState{
+		WorkUnits: []WorkUnitName{
+			{RepoName: RepoName{Repo: "repo"}, WorkUnit: "foo"},
+		},
+		UnqualifiedRepos: []string{"repo"},
+		Repos:            []RepoName{{Repo: "repo"}},
+		UnknownSessions:  []string{"bar"},
+	}
+	if diff := cmp.Diff(want, simplifyState(t, st), compareSimplifiedStates, cmpopts.IgnoreFields(RepoName{}, "VCS", "Dir")); diff != "" {
+		t.Errorf("State diff (-want +got)\n%s", diff)
+	}
+	if got := slices.Collect(maps.Keys(st.MismatchedSessions())); !slices.Equal(got, []string{"bar"}) {
+		t.Errorf("MismatchedSessions() = %q, want [bar]", got)
+	}
+}
+
+func TestMigrateNames(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	srv := newServer(
+		tmux.NewSessionOptions{Name: "repo1>foo", StartDir: "testing/repo1"},
+		tmux.NewSessionOptions{Name: "repo2>bar", StartDir: "testing/repo2"},
+		// This session has already been migrated.
+		tmux.NewSessionOptions{Name: "repo2/root", StartDir: "testing/repo2"},
+	)
+	vcs := api.VersionControlSystems{repotest.NewVCS("testing/",
+		repotest.RepoConfig{Name: "repo1", WorkUnits: map[string][]string{repotest.DefaultWorkUnitName: {"foo"}}},
+		repotest.RepoConfig{Name: "repo2", WorkUnits: map[string][]string{repotest.DefaultWorkUnitName: {"bar"}}},
+	)}
+	st, err := New(ctx, srv, vcs, WithNaming(Naming{Qualified: "{repo}/{work_unit}", Unqualified: "{work_unit}", Qualify: QualifyAuto}))
+	if err != nil {
+		t.Fatalf("New() = _, %v", err)
+	}
+
+	if err := st.MigrateNames(ctx, DefaultNaming); err != nil {
+		t.Errorf("MigrateNames() = %v", err)
+	}
+
+	want := simplifiedState{
+		WorkUnits: []WorkUnitName{
+			{RepoName: RepoName{Repo: "repo1"}, WorkUnit: "foo"},
+			{RepoName: RepoName{Repo: "repo2"}, WorkUnit: "bar"},
+			{RepoName: RepoName{Repo: "repo2"}, WorkUnit: "root"},
+		},
+		UnqualifiedRepos: []string{"repo1", "repo2"},
+		Repos:            []RepoName{{Repo: "repo1"}, {Repo: "repo2"}},
+	}
+	if diff := cmp.Diff(want, simplifyState(t, st), compareSimplifiedStates, cmpopts.IgnoreFields(RepoName{}, "VCS", "Dir")); diff != "" {
+		t.Errorf("State diff (-want +got)\n%s", diff)
+	}
+	wantTmux := simplifiedTmuxState{
+		Sessions: []simplifiedSessionState{
+			{Name: "repo1/foo", Dir: "testing/repo1"},
+			{Name: "repo2/bar", Dir: "testing/repo2"},
+			{Name: "repo2/root", Dir: "testing/repo2"},
+		},
+	}
+	if diff := cmp.Diff(wantTmux, simplifyTmuxState(ctx, srv), compareSimplifiedTmuxState); diff != "" {
+		t.Errorf("tmux diff (-want +got)\n%s", diff)
+	}
+}
+
+func TestAdoptSession(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	srv := newServer(
+		tmux.NewSessionOptions{Name: "foo", StartDir: "testing/repo"},
+		tmux.NewSessionOptions{Name: "scratch", StartDir: "/tmp"},
+	)
+	vcs := api.VersionControlSystems{repotest.NewVCS("testing/",
+		repotest.RepoConfig{Name: "repo", WorkUnits: map[string][]string{repotest.DefaultWorkUnitName: {"foo", "bar"}}},
+	)}
+	st, err := New(ctx, srv, vcs)
+	if err != nil {
+		t.Fatalf("New() = _, %v", err)
+	}
+	repo := must(vcs.MaybeFindRepository(ctx, "testing/repo"))
+	scratch := st.UnknownSessions()["scratch"]
+	foo := st.Session(repo, "foo")
+
+	if err := st.AdoptSession(ctx, scratch, repo, "foo"); err == nil {
+		t.Errorf("AdoptSession(scratch, foo) = nil, want an error since foo has a session")
+	}
+	if err := st.AdoptSession(ctx, foo, repo, "bar"); err == nil {
+		t.Errorf("AdoptSession(foo, bar) = nil, want an error since foo's session is for a work unit")
+	}
+	if err := st.AdoptSession(ctx, scratch, repo, "bar"); err != nil {
+		t.Errorf("AdoptSession(scratch, bar) = %v", err)
+	}
+
+	want := simplifiedState{
+		WorkUnits: []WorkUnitName{
+			{RepoName: RepoName{Repo: "repo"}, WorkUnit: "foo"},
+			{RepoName: RepoName{Repo: "repo"}, WorkUnit: "bar"},
+		},
+		UnqualifiedRepos: []string{"repo"},
+		Repos:            []RepoName{{Repo: "repo"}},
+	}
+	if diff := cmp.Diff(want, simplifyState(t, st), compareSimplifiedStates, cmpopts.IgnoreFields(RepoName{}, "VCS", "Dir")); diff != "" {
+		t.Errorf("State diff (-want +got)\n%s", diff)
+	}
+	wantTmux := simplifiedTmuxState{
+		Sessions: []simplifiedSessionState{
+			{Name: "foo", Dir: "testing/repo"},
+			{Name: "bar", Dir: "testing/repo"},
+		},
+	}
+	if diff := cmp.Diff(wantTmux, simplifyTmuxState(ctx, srv), compareSimplifiedTmuxState); diff != "" {
+		t.Errorf("tmux diff (-want +got)\n%s", diff)
+	}
+}
+
+func TestStoredWorkUnit(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	srv := newServer(tmux.NewSessionOptions{Name: "scratch", StartDir: "/tmp"})
+	vcs := api.VersionControlSystems{repotest.NewVCS("testing/",
+		repotest.RepoConfig{Name: "repo", WorkUnits: map[string][]string{repotest.DefaultWorkUnitName: {"foo"}}},
+	)}
+	st, err := New(ctx, srv, vcs)
+	if err != nil {
+		t.Fatalf("New() = _, %v", err)
+	}
+	repo := must(vcs.MaybeFindRepository(ctx, "testing/repo"))
+
+	if got, err := st.StoredWorkUnit(ctx, st.UnknownSessions()["scratch"]); err != nil || got != "" {
+		t.Errorf("StoredWorkUnit(scratch) = %q, %v, want \"\", nil", got, err)
+	}
+	sesh, err := st.NewSession(ctx, repo, "foo")
+	if err != nil {
+		t.Fatalf("NewSession(foo) = _, %v", err)
+	}
+	if got, err := st.StoredWorkUnit(ctx, sesh); err != nil || got != "foo" {
+		t.Errorf("StoredWorkUnit(foo) = %q, %v, want \"foo\", nil", got, err)
+	}
+	if err := st.RenameSession(ctx, repo, "foo", "bar"); err != nil {
+		t.Fatalf("RenameSession(foo, bar) = %v", err)
+	}
+	if got, err := st.StoredWorkUnit(ctx, sesh); err != nil || got != "bar" {
+		t.Errorf("StoredWorkUnit(bar) = %q, %v, want \"bar\", nil", got, err)
+	}
+}
+
+func TestPlanPrune(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	srv := newServer(
+		tmux.NewSessionOptions{Name: "foo", StartDir: "testing/repo"},
+		tmux.NewSessionOptions{Name: "gone2", StartDir: "testing/repo"},
+		tmux.NewSessionOptions{Name: "gone1", StartDir: "testing/repo"},
+	)
+	vcs := api.VersionControlSystems{repotest.NewVCS("testing/", repotest.RepoConfig{
+		Name:      "repo",
+		WorkUnits: map[string][]string{repotest.DefaultWorkUnitName: {"foo"}},
+	})}
+	st, err := New(ctx, srv, vcs)
+	if err != nil {
+		t.Fatalf("New() = _, %v", err)
+	}
+
+	plan := st.PlanPrune(ctx)
+	var got []string
+	for _, s := range plan {
+		got = append(got, s.SessionName)
+	}
+	if diff := cmp.Diff([]string{"gone1", "gone2"}, got); diff != "" {
+		t.Errorf("PlanPrune() diff (-want +got)\n%s", diff)
+	}
+	// Planning doesn't kill anything.
+	if diff := cmp.Diff(3, len(simplifyTmuxState(ctx, srv).Sessions)); diff != "" {
+		t.Errorf("Number of tmux sessions diff (-want +got)\n%s", diff)
+	}
+
+	if err := st.KillSessions(ctx, plan[1:]); err != nil {
+		t.Errorf("KillSessions() = %v", err)
+	}
+	wantTmux := simplifiedTmuxState{
+		Sessions: []simplifiedSessionState{
+			{Name: "foo", Dir: "testing/repo"},
+			{Name: "gone1", Dir: "testing/repo"},
+		},
+	}
+	if diff := cmp.Diff(wantTmux, simplifyTmuxState(ctx, srv), compareSimplifiedTmuxState); diff != "" {
+		t.Errorf("tmux diff (-want +got)\n%s", diff)
+	}
+}
+
 type simplifiedState struct {
 	WorkUnits        []WorkUnitName
 	UnqualifiedRepos []string
@@ -561,8 +872,8 @@ func simplifyState(t *testing.T, st *State) simplifiedState {
 			t.Errorf("sessionsByName[%q] is missing, expected sesh %q", n, id)
 		}
 	}
-	for n := range st.unqualifiedRepos {
-		ret.UnqualifiedRepos = append(ret.UnqualifiedRepos, n)
+	for n := range st.repoSessions {
+		ret.UnqualifiedRepos = append(ret.UnqualifiedRepos, n.Repo)
 	}
 	for n := range st.repos {
 		ret.Repos = append(ret.Repos, n)
@@ -0,0 +1,130 @@
+package state
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JeffFaer/tmux-vcs-sync/api"
+	"github.com/JeffFaer/tmux-vcs-sync/api/repotest"
+	"github.com/JeffFaer/tmux-vcs-sync/tmux"
+	"github.com/google/go-cmp/cmp"
+)
+
+// awkwardWorkUnits are work unit names that tmux wouldn't store as-is.
+var awkwardWorkUnits = []string{
+	"release-1.2",
+	"fix:thing",
+	"100%",
+	"%2E",
+	`back\slash`,
+	"tab\there",
+	"new\nline",
+	"ünïcödé.✓",
+	strings.Repeat("long-", 30),
+	strings.Repeat("long-", 30) + "er",
+	strings.Repeat(".", 50),
+}
+
+func TestEncodeSessionName(t *testing.T) {
+	for _, wu := range append(awkwardWorkUnits, "main", "", "a>b") {
+		s := encodeSessionName(wu)
+		if len(s) > maxSessionNameLength {
+			t.Errorf("encodeSessionName(%q) = %q, which is longer than %d", wu, s, maxSessionNameLength)
+		}
+		if strings.ContainsAny(s, ".:\\\t\n") {
+			t.Errorf("encodeSessionName(%q) = %q, which tmux will change", wu, s)
+		}
+		if got, ok := decodeSessionName(s); ok && got != wu {
+			t.Errorf("decodeSessionName(%q) = %q, true, want %q", s, got, wu)
+		} else if !ok && !isTruncated(s) {
+			t.Errorf("decodeSessionName(%q) = _, false, but it wasn't truncated", s)
+		}
+	}
+	if a, b := encodeSessionName(strings.Repeat("long-", 30)), encodeSessionName(strings.Repeat("long-", 30)+"er"); a == b {
+		t.Errorf("Truncated session names collide: %q", a)
+	}
+}
+
+func TestDecodeSessionName_NotEncoded(t *testing.T) {
+	for _, s := range []string{"100%", "50%off", "%zz", "a%"} {
+		if got, ok := decodeSessionName(s); !ok || got != s {
+			t.Errorf("decodeSessionName(%q) = %q, %t, want %q, true", s, got, ok, s)
+		}
+	}
+}
+
+// newRealServer creates a new tmux server that's isolated from the user's
+// tmux servers.
+func newRealServer(t *testing.T) tmux.Server {
+	t.Helper()
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux is not installed")
+	}
+	// Put the socket somewhere that will get cleaned up.
+	t.Setenv("TMUX_TMPDIR", t.TempDir())
+	n := strings.ReplaceAll(t.Name(), "/", "_")
+	srv := tmux.NewServer(tmux.NamedServerSocket(n), tmux.ServerConfigFile("/dev/null"))
+	t.Cleanup(func() {
+		if err := srv.Kill(context.Background()); err != nil {
+			t.Logf("Failed to kill tmux server %s: %v", n, err)
+		}
+	})
+	return srv
+}
+
+func TestSessionName_RoundTripThroughTmux(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "repo"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	vcs := api.VersionControlSystems{repotest.NewVCS(dir, repotest.RepoConfig{
+		Name:      "repo",
+		WorkUnits: map[string][]string{repotest.DefaultWorkUnitName: awkwardWorkUnits},
+	})}
+	repo, err := vcs.MaybeFindRepository(ctx, filepath.Join(dir, "repo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := newRealServer(t)
+
+	st, err := New(ctx, srv, vcs)
+	if err != nil {
+		t.Fatalf("New() = _, %v", err)
+	}
+	for _, wu := range awkwardWorkUnits {
+		if _, err := st.NewSession(ctx, repo, wu); err != nil {
+			t.Fatalf("NewSession(%q) = _, %v", wu, err)
+		}
+	}
+
+	// Start over so that the work units are parsed from the names that tmux
+	// stored.
+	st, err = New(ctx, srv, vcs)
+	if err != nil {
+		t.Fatalf("New() = _, %v", err)
+	}
+	var got []string
+	for n := range st.Sessions() {
+		got = append(got, n.WorkUnit)
+	}
+	if diff := cmp.Diff(awkwardWorkUnits, got, compareSimplifiedStates); diff != "" {
+		t.Errorf("Work units diff (-want +got)\n%s", diff)
+	}
+	if unknown := st.UnknownSessions(); len(unknown) > 0 {
+		t.Errorf("UnknownSessions() = %v, want none", unknown)
+	}
+	for _, wu := range awkwardWorkUnits {
+		if sesh := st.Session(repo, wu); sesh == nil {
+			t.Errorf("Session(%q) = nil", wu)
+		} else if _, err := st.NewSession(ctx, repo, wu); err == nil {
+			t.Errorf("NewSession(%q) = _, nil, want a duplicate session error", wu)
+		}
+	}
+}
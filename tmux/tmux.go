@@ -23,13 +23,20 @@ func init() {
 	var err error
 	tmux, err = exec.Lookup("tmux")
 	if err != nil {
-		panic(err)
+		// Let each command fail instead, so that programs can still explain
+		// what's wrong.
+		tmux = exec.Executable("tmux")
 	}
 }
 
 type Server interface {
 	// PID returns the process ID of the server, if it's currently active.
 	PID(context.Context) (int, error)
+	// SocketPath returns the path of the socket that the server listens on,
+	// whether or not it's currently active.
+	SocketPath() string
+	// Version returns the version of tmux that this server is running.
+	Version(context.Context) (Version, error)
 
 	// ListSessions lists the sessions that exist in this tmux server.
 	ListSessions(context.Context) (Sessions, error)
@@ -41,8 +48,28 @@ type Server interface {
 	// AttachOrSwitch either attaches the controlling terminal to the given TargetSession or switches the current tmux client to the TargetSession.
 	AttachOrSwitch(context.Context, Session) error
 
+	// Watch reports changes to this tmux server, such as sessions being
+	// created or renamed, until the context is done. The channel is closed
+	// once there won't be any more notifications, e.g. because the server
+	// exited.
+	Watch(context.Context) (<-chan Notification, error)
+
 	// Kill this tmux server.
 	Kill(context.Context) error
+	// Close releases any resources held for communicating with this tmux server,
+	// such as a control mode connection. It does not affect the server itself.
+	Close() error
+}
+
+// Notification is a change to a tmux server, as reported by control mode.
+// See the CONTROL MODE section of tmux(1) for the possible notifications.
+type Notification struct {
+	// Name is the name of the notification, without its leading %.
+	// e.g. session-renamed
+	Name string
+	// Args are the notification's unparsed arguments.
+	// e.g. "$1 new-name"
+	Args string
 }
 
 // NewSessionOptions affects how NewSession creates sessions.
@@ -92,6 +119,18 @@ type Session interface {
 	// Properties retrieves the values of all the given property keys.
 	Properties(context.Context, ...SessionPropertyName) (SessionPropertyValues, error)
 
+	// Windows lists this session's windows, in order.
+	Windows(context.Context) ([]Window, error)
+	// ReplaceWindows replaces all of this session's windows with new windows
+	// like the given ones.
+	ReplaceWindows(context.Context, []Window) error
+
+	// SetUserOption sets a user option for this session. Its value can be
+	// retrieved with the UserOption property.
+	SetUserOption(ctx context.Context, name, value string) error
+	// SetStartDir changes the directory that new windows in this session start
+	// in.
+	SetStartDir(context.Context, string) error
 	// Rename this tmux session to have the given name.
 	Rename(context.Context, string) error
 
@@ -99,6 +138,16 @@ type Session interface {
 	Kill(context.Context) error
 }
 
+// Window describes a window in a tmux session.
+type Window struct {
+	Name string
+	// Layout is the arrangement of the window's panes, in the format of
+	// #{window_layout}. It's optional when creating a window.
+	Layout string
+	// PaneDirs are the working directories of the window's panes, in order.
+	PaneDirs []string
+}
+
 // TODO: jfaer - This indirection is ripe for a refactor after go gets generic methods.
 type SessionPropertyName interface {
 	String() string
@@ -110,8 +159,16 @@ const (
 	SessionID       SessionProperty[string] = "#{session_id}"
 	SessionName     SessionProperty[string] = "#{session_name}"
 	SessionPath     SessionProperty[string] = "#{session_path}"
+	SessionActivity SessionProperty[string] = "#{session_activity}"  // Unix time, in seconds.
+	SessionPaneDir  SessionProperty[string] = "#{pane_current_path}" // The working directory of the session's active pane.
 )
 
+// UserOption is a property for the session's user option with the given name,
+// which doesn't include the leading @. It's empty if the option isn't set.
+func UserOption(name string) SessionProperty[string] {
+	return SessionProperty[string]("#{@" + name + "}")
+}
+
 func (_ SessionProperty[T]) iAmSessionPropertyName() {}
 
 func (prop SessionProperty[T]) Value(t T) SessionPropertyValue {
@@ -184,14 +241,36 @@ type Client interface {
 	// Properties retrieves the values of all the given property keys.
 	Properties(context.Context, ...ClientProperty) (map[ClientProperty]string, error)
 
+	// Switch makes this client display the given Session.
+	Switch(context.Context, Session) error
 	// DisplayMenu displays a menu in this client.
 	DisplayMenu(context.Context, []MenuElement) error
+	// DisplayPopup displays a popup running a shell command in this client. The
+	// popup closes when the command exits.
+	DisplayPopup(context.Context, PopupOptions) error
+}
+
+// PopupOptions affects how DisplayPopup displays a popup.
+type PopupOptions struct {
+	// Title is the optional title displayed in the popup's border.
+	Title string
+	// Width and Height are the optional size of the popup, in any form tmux
+	// accepts. e.g. "80%" or "40"
+	Width, Height string
+	// StartDir is the optional working directory for Command.
+	StartDir string
+	// Command is the shell command to run in the popup.
+	Command string
+	// KeepOpenOnError keeps the popup open if Command fails, so that its output
+	// can be read.
+	KeepOpenOnError bool
 }
 
 type ClientProperty string
 
 const (
-	ClientTTY ClientProperty = "#{client_tty}"
+	ClientTTY       ClientProperty = "#{client_tty}"
+	ClientSessionID ClientProperty = "#{session_id}" // The ID of the session the client is displaying.
 )
 
 type MenuElement interface {
@@ -201,14 +280,45 @@ type MenuElement interface {
 // MenuEntry is an actual entry in the menu that has an executable command.
 type MenuEntry struct {
 	Name, Key, Command string
+	// Disabled entries are displayed, but cannot be selected.
+	Disabled bool
 }
 
 // MenuSpacer allows you to delineate sections within a menu.
 type MenuSpacer struct{}
 
-func (e MenuEntry) args() []string  { return []string{e.Name, e.Key, e.Command} }
+// Submenu is an entry in the menu that opens another menu.
+type Submenu struct {
+	Name, Key string
+	// Title is displayed at the top of the submenu.
+	Title    string
+	Elements []MenuElement
+}
+
+func (e MenuEntry) args() []string {
+	name := e.Name
+	if e.Disabled {
+		name = "-" + name
+	}
+	return []string{name, e.Key, e.Command}
+}
+
 func (e MenuSpacer) args() []string { return []string{""} }
 
+func (e Submenu) args() []string {
+	cmd := append([]string{"display-menu", "-T", e.Title, "--"}, MenuArgs(e.Elements)...)
+	return []string{e.Name, e.Key, FormatCommand(cmd...)}
+}
+
+// MenuArgs formats elems as arguments for tmux display-menu.
+func MenuArgs(elems []MenuElement) []string {
+	var args []string
+	for _, e := range elems {
+		args = append(args, e.args()...)
+	}
+	return args
+}
+
 type envVar struct {
 	socketPath string
 	pid        int
@@ -222,6 +332,9 @@ func getenv() (envVar, error) {
 	}
 
 	sp := strings.SplitN(env, ",", 3)
+	if len(sp) != 3 {
+		return envVar{}, fmt.Errorf("%w: $TMUX should be socket,pid,session: %q", errNotTmux, env)
+	}
 	pid, err := strconv.Atoi(sp[1])
 	if err != nil {
 		return envVar{}, fmt.Errorf("%w: %w", errNotTmux, err)
@@ -229,11 +342,29 @@ func getenv() (envVar, error) {
 	return envVar{sp[0], pid, fmt.Sprintf("$%s", sp[2])}, nil
 }
 
-func (env envVar) server() *server {
-	return &server{serverOptions{socketPath: env.socketPath}, tmux}
+// Environment describes the tmux server and session that this program is
+// running in.
+type Environment struct {
+	SocketPath string
+	PID        int
+	SessionID  string
+}
+
+// CurrentEnvironment parses $TMUX. Returns an error if this program isn't
+// running in tmux, or if $TMUX is malformed.
+func CurrentEnvironment() (Environment, error) {
+	env, err := getenv()
+	if err != nil {
+		return Environment{}, err
+	}
+	return Environment{env.socketPath, env.pid, env.sessionID}, nil
+}
+
+func (env envVar) server(opts ...ServerOption) *server {
+	return NewServer(append([]ServerOption{ServerSocketPath(env.socketPath)}, opts...)...)
 }
 
-func (env envVar) session() *session {
-	srv := env.server()
+func (env envVar) session(opts ...ServerOption) *session {
+	srv := env.server(opts...)
 	return &session{srv, env.sessionID}
 }
@@ -1,12 +1,17 @@
 package tmux
 
 import (
+	"bufio"
 	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"math/rand"
 	"os"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -40,9 +45,9 @@ type TestServer struct {
 	t *testing.T
 }
 
-func NewServerForTesting(ctx context.Context, t *testing.T) TestServer {
-	n := fmt.Sprintf("%s-%s", t.Name(), randomString())
-	srv := NewServer(NamedServerSocket(n), ServerConfigFile("/dev/null"))
+func NewServerForTesting(ctx context.Context, t *testing.T, opts ...ServerOption) TestServer {
+	n := fmt.Sprintf("%s-%s", strings.ReplaceAll(t.Name(), "/", "_"), randomString())
+	srv := NewServer(append([]ServerOption{NamedServerSocket(n), ServerConfigFile("/dev/null")}, opts...)...)
 	srv.tmux = exectest.NewTestCommander(t, tmux)
 	socketPath, err := srv.command(ctx, "start-server", ";", "display-message", "-p", "#{socket_path}").RunStdout()
 	if err != nil {
@@ -206,6 +211,117 @@ func TestServer_Sessions(t *testing.T) {
 	}
 }
 
+func TestSession_Windows(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	srv := NewServerForTesting(ctx, t)
+	a, b := t.TempDir(), t.TempDir()
+	sesh := srv.MustNewSession(ctx, NewSessionOptions{Name: "sesh", StartDir: a})
+
+	want := []Window{
+		{Name: "edit", PaneDirs: []string{a, b}},
+		{Name: "run", PaneDirs: []string{b}},
+	}
+	if err := sesh.ReplaceWindows(ctx, want); err != nil {
+		t.Fatalf("sesh.ReplaceWindows() = %v", err)
+	}
+	got, err := sesh.Windows(ctx)
+	if err != nil {
+		t.Fatalf("sesh.Windows() = _, %v", err)
+	}
+	if diff := cmp.Diff(want, got, cmpopts.IgnoreFields(Window{}, "Layout")); diff != "" {
+		t.Errorf("sesh.Windows() diff (-want +got)\n%s", diff)
+	}
+	for _, w := range got {
+		if w.Layout == "" {
+			t.Errorf("Window %q doesn't have a layout", w.Name)
+		}
+	}
+}
+
+func TestSession_SetStartDir(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	srv := NewServerForTesting(ctx, t)
+	a, b := t.TempDir(), t.TempDir()
+	sesh := srv.MustNewSession(ctx, NewSessionOptions{Name: "sesh", StartDir: a})
+
+	if err := sesh.SetStartDir(ctx, b); err != nil {
+		t.Fatalf("sesh.SetStartDir(%q) = %v", b, err)
+	}
+	props, err := sesh.Properties(ctx, SessionPath, SessionPaneDir)
+	if err != nil {
+		t.Fatalf("sesh.Properties() = _, %v", err)
+	}
+	if got := SinglePropertyValue(SessionPath, props); got != b {
+		t.Errorf("%s = %q, want %q", SessionPath, got, b)
+	}
+	// The existing pane doesn't move.
+	if got := SinglePropertyValue(SessionPaneDir, props); got != a {
+		t.Errorf("%s = %q, want %q", SessionPaneDir, got, a)
+	}
+}
+
+func TestSession_UserOption(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	srv := NewServerForTesting(ctx, t)
+	a := srv.MustNewSession(ctx, NewSessionOptions{Name: "a"})
+	b := srv.MustNewSession(ctx, NewSessionOptions{Name: "b"})
+
+	opt := UserOption("test-option")
+	if err := a.SetUserOption(ctx, "test-option", "foo bar"); err != nil {
+		t.Fatalf("a.SetUserOption() = %v", err)
+	}
+	props, err := srv.MustListSessions(ctx).Property(ctx, opt)
+	if err != nil {
+		t.Fatalf("Sessions.Property(%s) = _, %v", opt, err)
+	}
+	want := map[string]string{a.ID(): "foo bar", b.ID(): ""}
+	got := make(map[string]string)
+	for sesh, v := range props {
+		got[sesh.ID()] = PropertyValue(opt, v)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Sessions.Property(%s) diff (-want +got)\n%s", opt, diff)
+	}
+}
+
+func TestServer_Watch(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	srv := NewServerForTesting(ctx, t)
+	a := srv.MustNewSession(ctx, NewSessionOptions{Name: "a"})
+
+	watchCtx, stop := context.WithCancel(ctx)
+	notifications, err := srv.Watch(watchCtx)
+	if err != nil {
+		t.Fatalf("srv.Watch() = _, %v", err)
+	}
+	if err := a.Rename(ctx, "new name"); err != nil {
+		t.Fatal(err)
+	}
+	want := Notification{Name: "session-renamed", Args: a.ID() + " new name"}
+	var got *Notification
+	for n := range notifications {
+		// Ignore any notifications about the watcher attaching.
+		if n.Name == want.Name {
+			got = &n
+			break
+		}
+	}
+	if got == nil {
+		t.Errorf("srv.Watch() didn't report %q", want.Name)
+	} else if diff := cmp.Diff(want, *got); diff != "" {
+		t.Errorf("Notification diff (-want +got)\n%s", diff)
+	}
+
+	stop()
+	for range notifications {
+		// Drain the channel until Watch closes it.
+	}
+}
+
 func TestServer_AttachOrSwitch(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -254,3 +370,327 @@ func TestServer_AttachOrSwitch(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestClient_Switch(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	srv := NewServerForTesting(ctx, t)
+	a := srv.MustNewSession(ctx, NewSessionOptions{Name: "a"})
+	b := srv.MustNewSession(ctx, NewSessionOptions{Name: "b"})
+
+	RunInTTY(t, srv.mustAttachCommand(ctx, a))
+	var client TestClient
+	err := retry.Do(func() error {
+		clients := srv.MustListClients(ctx)
+		if len(clients) != 1 {
+			return fmt.Errorf("server has %d clients", len(clients))
+		}
+		client = clients[0]
+		return nil
+	}, retry.Delay(10*time.Millisecond), retry.Context(ctx))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.Switch(ctx, b); err != nil {
+		t.Fatalf("client.Switch(b) = %v", err)
+	}
+	if id := client.MustProperties(ctx, ClientSessionID)[ClientSessionID]; id != b.ID() {
+		t.Errorf("Client is connected to %q, expected %q", id, b.ID())
+	}
+}
+
+func TestServer_ControlMode(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	srv := NewServerForTesting(ctx, t, ControlMode())
+
+	// The server doesn't have any sessions yet, so there's nothing for control
+	// mode to attach to.
+	if n := len(srv.MustListSessions(ctx).Sessions()); n != 0 {
+		t.Errorf("New tmux server has %d sessions, expected 0", n)
+	}
+
+	a := srv.MustNewSession(ctx, NewSessionOptions{Name: "a"})
+	b := srv.MustNewSession(ctx, NewSessionOptions{Name: "b"})
+	if srv.control == nil {
+		t.Fatalf("Server did not start a control mode connection")
+	}
+
+	sessions := srv.MustListSessions(ctx)
+	if diff := cmp.Diff([]Session{a.session, b.session}, sessions.Sessions(), tmuxCmpOpt(ctx)); diff != "" {
+		t.Errorf("srv.ListSessions() diff (-want +got)\n%s", diff)
+	}
+
+	// Commands can be sent concurrently over the same connection.
+	names := []string{`with "quotes"`, "with 'single quotes'", "with;semicolon", "with  spaces"}
+	var wg sync.WaitGroup
+	errs := make([]error, len(names))
+	for i, n := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sesh, err := srv.NewSession(ctx, NewSessionOptions{Name: fmt.Sprintf("sesh%d", i)})
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			errs[i] = sesh.Rename(ctx, n)
+		}()
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("Creating session %q: %v", names[i], err)
+		}
+	}
+
+	props, err := srv.MustListSessions(ctx).Properties(ctx, SessionName)
+	if err != nil {
+		t.Fatalf("Sessions.Properties() = _, %v", err)
+	}
+	var got []string
+	for _, vals := range props {
+		got = append(got, SinglePropertyValue(SessionName, vals))
+	}
+	want := append([]string{"a", "b"}, names...)
+	if diff := cmp.Diff(want, got, cmpopts.SortSlices(func(a, b string) bool { return a < b })); diff != "" {
+		t.Errorf("Session names diff (-want +got)\n%s", diff)
+	}
+
+	for _, arg := range []string{`"double"`, "'single'", "$HOME", `back\slash`, "new\nline", "\ttab", "~", "~/tilde", "{braces}", "semi;colon"} {
+		if got, err := srv.runStdout(ctx, "display-message", "-p", arg); err != nil {
+			t.Errorf("display-message -p %q = _, %v", arg, err)
+		} else if got != arg {
+			t.Errorf("display-message -p %q = %q", arg, got)
+		}
+	}
+
+	if err := srv.Close(); err != nil {
+		t.Errorf("srv.Close() = %v", err)
+	}
+	// Closing the connection doesn't prevent the server from being used again.
+	if _, err := srv.PID(ctx); err != nil {
+		t.Errorf("srv.PID() after Close() = _, %v", err)
+	}
+}
+
+// flakyWriter fails the first write.
+type flakyWriter struct {
+	io.WriteCloser
+	failed bool
+}
+
+func (w *flakyWriter) Write(b []byte) (int, error) {
+	if !w.failed {
+		w.failed = true
+		return 0, errors.New("broken pipe")
+	}
+	return w.WriteCloser.Write(b)
+}
+
+func TestControlMode_WriteFailure(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// tmux reads commands from stdin, and replies on stdout.
+	stdin, tmuxStdin := io.Pipe()
+	tmuxStdout, stdout := io.Pipe()
+	c := &controlMode{stdin: &flakyWriter{WriteCloser: tmuxStdin}, done: make(chan struct{}), stop: make(chan struct{})}
+	go c.read(tmuxStdout)
+	go func() {
+		s := bufio.NewScanner(stdin)
+		for i := 0; s.Scan(); i++ {
+			fmt.Fprintf(stdout, "%%begin 1 %d 1\n%s\n%%end 1 %d 1\n", i, s.Text(), i)
+		}
+		stdout.Close()
+	}()
+	defer c.Close()
+
+	if _, _, err := c.Run(ctx, "display-message", "-p", "a"); err == nil {
+		t.Errorf("c.Run(a) = _, _, nil, want an error since it couldn't be sent")
+	}
+	// The next reply belongs to the next command.
+	if got, _, err := c.Run(ctx, "display-message", "-p", "b"); err != nil {
+		t.Errorf("c.Run(b) = _, _, %v", err)
+	} else if want := FormatCommand("display-message", "-p", "b"); got != want {
+		t.Errorf("c.Run(b) = %q, want %q", got, want)
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	for _, tc := range []struct {
+		version string
+
+		want    Version
+		wantErr bool
+	}{
+		{version: "tmux 3.3a", want: Version{Major: 3, Minor: 3, Patch: "a"}},
+		{version: "3.2", want: Version{Major: 3, Minor: 2}},
+		{version: "tmux next-3.5", want: Version{Major: 3, Minor: 5}},
+		{version: "tmux 3.4-rc", want: Version{Major: 3, Minor: 4}},
+		{version: "tmux 2.9a", want: Version{Major: 2, Minor: 9, Patch: "a"}},
+		{version: "tmux openbsd-7.4", wantErr: true},
+		{version: "master", wantErr: true},
+	} {
+		got, err := ParseVersion(tc.version)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("ParseVersion(%q) = _, %v, wantErr %t", tc.version, err, tc.wantErr)
+		}
+		if got != tc.want {
+			t.Errorf("ParseVersion(%q) = %v, want %v", tc.version, got, tc.want)
+		}
+	}
+}
+
+func TestVersion_AtLeast(t *testing.T) {
+	for _, tc := range []struct {
+		a, b Version
+		want bool
+	}{
+		{Version{Major: 3, Minor: 2}, Version{Major: 3, Minor: 2}, true},
+		{Version{Major: 3, Minor: 3, Patch: "a"}, Version{Major: 3, Minor: 3}, true},
+		{Version{Major: 3, Minor: 3}, Version{Major: 3, Minor: 3, Patch: "a"}, false},
+		{Version{Major: 3, Minor: 1}, Version{Major: 3, Minor: 2}, false},
+		{Version{Major: 4, Minor: 0}, Version{Major: 3, Minor: 9}, true},
+		{Version{Major: 2, Minor: 9}, Version{Major: 3, Minor: 0}, false},
+	} {
+		if got := tc.a.AtLeast(tc.b); got != tc.want {
+			t.Errorf("%v.AtLeast(%v) = %t, want %t", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestVersion_Unsupported(t *testing.T) {
+	for _, tc := range []struct {
+		v    Version
+		want []string
+	}{
+		{Version{Major: 3, Minor: 4}, nil},
+		{Version{Major: 3, Minor: 2}, []string{"display-popup -T"}},
+		{Version{Major: 2, Minor: 9}, []string{"display-menu", "list-sessions -f", "control mode", "display-popup", "display-popup -T"}},
+	} {
+		var got []string
+		for _, err := range tc.v.Unsupported() {
+			got = append(got, err.Feature)
+		}
+		if diff := cmp.Diff(tc.want, got); diff != "" {
+			t.Errorf("%v.Unsupported() diff (-want +got)\n%s", tc.v, diff)
+		}
+	}
+}
+
+func TestServer_Version(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	srv := NewServerForTesting(ctx, t)
+
+	want, err := tmux.Command(ctx, "-V").RunStdout()
+	if err != nil {
+		t.Fatalf("tmux -V = _, %v", err)
+	}
+	got, err := srv.Version(ctx)
+	if err != nil {
+		t.Fatalf("srv.Version() = _, %v", err)
+	}
+	if "tmux "+got.String() != want {
+		t.Errorf("srv.Version() = %v, want %q", got, want)
+	}
+}
+
+func TestServer_SocketPath(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	srv := NewServerForTesting(ctx, t)
+	// The server exits if it doesn't have any sessions.
+	srv.MustNewSession(ctx, NewSessionOptions{Name: "a"})
+
+	want, err := srv.runStdout(ctx, "display-message", "-p", "#{socket_path}")
+	if err != nil {
+		t.Fatalf("display-message = _, %v", err)
+	}
+	if got := srv.SocketPath(); got != want {
+		t.Errorf("srv.SocketPath() = %q, want %q", got, want)
+	}
+}
+
+func TestServer_OldVersion(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	srv := NewServerForTesting(ctx, t)
+	// Pretend to be a tmux that doesn't support list-sessions -f or display-menu.
+	srv.version = &Version{Major: 2, Minor: 9}
+
+	a := srv.MustNewSession(ctx, NewSessionOptions{Name: "a"})
+	srv.MustNewSession(ctx, NewSessionOptions{Name: "b"})
+	c := srv.MustNewSession(ctx, NewSessionOptions{Name: "c"})
+
+	props, err := sessions{a.session, c.session}.Properties(ctx, SessionName)
+	if err != nil {
+		t.Fatalf("Sessions.Properties() = _, %v", err)
+	}
+	got := make(map[string]string)
+	for sesh, vals := range props {
+		got[sesh.ID()] = SinglePropertyValue(SessionName, vals)
+	}
+	want := map[string]string{a.ID(): "a", c.ID(): "c"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Sessions.Properties() diff (-want +got)\n%s", diff)
+	}
+
+	if err := (&client{srv.server, currentClientTTY}).DisplayMenu(ctx, nil); err == nil {
+		t.Errorf("DisplayMenu() with tmux %v = nil, expected an error", srv.version)
+	} else if _, ok := err.(*UnsupportedError); !ok {
+		t.Errorf("DisplayMenu() with tmux %v = %v, expected an UnsupportedError", srv.version, err)
+	}
+	if err := (&client{srv.server, currentClientTTY}).DisplayPopup(ctx, PopupOptions{Command: "true"}); err == nil {
+		t.Errorf("DisplayPopup() with tmux %v = nil, expected an error", srv.version)
+	} else if _, ok := err.(*UnsupportedError); !ok {
+		t.Errorf("DisplayPopup() with tmux %v = %v, expected an UnsupportedError", srv.version, err)
+	}
+}
+
+func TestCurrentEnvironment(t *testing.T) {
+	for _, tc := range []struct {
+		env     string
+		want    Environment
+		wantErr bool
+	}{
+		{env: "/tmp/tmux-1000/default,1234,5", want: Environment{SocketPath: "/tmp/tmux-1000/default", PID: 1234, SessionID: "$5"}},
+		{env: "", wantErr: true},
+		{env: "/tmp/tmux-1000/default", wantErr: true},
+		{env: "/tmp/tmux-1000/default,abc,5", wantErr: true},
+	} {
+		t.Setenv("TMUX", tc.env)
+		got, err := CurrentEnvironment()
+		if (err != nil) != tc.wantErr {
+			t.Errorf("CurrentEnvironment() with $TMUX=%q = _, %v, wantErr %t", tc.env, err, tc.wantErr)
+		} else if got != tc.want {
+			t.Errorf("CurrentEnvironment() with $TMUX=%q = %+v, want %+v", tc.env, got, tc.want)
+		}
+	}
+}
+
+func TestServer_IsOtherServer(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	srv := NewServerForTesting(ctx, t)
+	srv.MustNewSession(ctx, NewSessionOptions{Name: "sesh"})
+	pid, err := srv.PID(ctx)
+	if err != nil {
+		t.Fatalf("srv.PID() = _, %v", err)
+	}
+
+	for _, tc := range []struct {
+		env  string
+		want bool
+	}{
+		{env: "", want: false},
+		{env: fmt.Sprintf("/tmp/tmux-1000/default,%d,0", pid), want: false},
+		{env: fmt.Sprintf("/tmp/tmux-1000/default,%d,0", pid+1), want: true},
+	} {
+		t.Setenv("TMUX", tc.env)
+		if got := srv.isOtherServer(ctx); got != tc.want {
+			t.Errorf("isOtherServer() with $TMUX=%q = %t, want %t", tc.env, got, tc.want)
+		}
+	}
+}
@@ -2,20 +2,30 @@ package tmuxtest
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"slices"
 	"strconv"
 
 	"github.com/JeffFaer/tmux-vcs-sync/tmux"
 )
 
+// Version is the tmux version that fake servers claim to be running.
+var Version = tmux.Version{Major: 3, Minor: 4}
+
 type Server struct {
 	pid int
 
 	nextSessionID int
 	sessions      map[string]*Session
+	// clock is the fake Unix time of the last session activity.
+	clock int64
 
 	CurrentSession *Session
+
+	clients  []*Client
+	watchers []chan tmux.Notification
 }
 
 var _ tmux.Server = (*Server)(nil)
@@ -33,7 +43,9 @@ func NewServer(pid int) *Server {
 	return servers[pid]
 }
 
-func (srv *Server) PID(context.Context) (int, error) { return srv.pid, nil }
+func (srv *Server) PID(context.Context) (int, error)              { return srv.pid, nil }
+func (srv *Server) SocketPath() string                            { return fmt.Sprintf("tmuxtest/%d", srv.pid) }
+func (srv *Server) Version(context.Context) (tmux.Version, error) { return Version, nil }
 
 func (srv *Server) ListSessions(context.Context) (tmux.Sessions, error) {
 	var ret Sessions
@@ -47,7 +59,18 @@ func (srv *Server) ListSessions(context.Context) (tmux.Sessions, error) {
 }
 
 func (srv *Server) ListClients(context.Context) ([]tmux.Client, error) {
-	return nil, nil
+	ret := make([]tmux.Client, len(srv.clients))
+	for i, c := range srv.clients {
+		ret[i] = c
+	}
+	return ret, nil
+}
+
+// NewClient attaches a new client to sesh.
+func (srv *Server) NewClient(sesh *Session) *Client {
+	c := &Client{srv: srv, tty: fmt.Sprintf("/dev/pts/%d", len(srv.clients)), Session: sesh}
+	srv.clients = append(srv.clients, c)
+	return c
 }
 
 func (srv *Server) NewSession(_ context.Context, opts tmux.NewSessionOptions) (tmux.Session, error) {
@@ -73,10 +96,12 @@ func (srv *Server) NewSession(_ context.Context, opts tmux.NewSessionOptions) (t
 		srv.sessions = make(map[string]*Session)
 	}
 	srv.sessions[id] = &Session{
-		srv:   srv,
-		id:    id,
-		props: tmux.CreateSessionPropertyValues(tmux.SessionID.Value(id), tmux.SessionName.Value(name), tmux.SessionPath.Value(dir)),
+		srv:     srv,
+		id:      id,
+		props:   tmux.CreateSessionPropertyValues(tmux.SessionID.Value(id), tmux.SessionName.Value(name), tmux.SessionPath.Value(dir), tmux.SessionPaneDir.Value(dir)),
+		windows: []tmux.Window{{PaneDirs: []string{dir}}},
 	}
+	srv.sessions[id].touch()
 	return srv.sessions[id], nil
 }
 
@@ -88,15 +113,37 @@ func (srv *Server) AttachOrSwitch(ctx context.Context, sesh tmux.Session) error
 		return fmt.Errorf("session %q was killed", sesh.ID())
 	}
 	srv.CurrentSession = srv.sessions[sesh.ID()]
+	srv.CurrentSession.touch()
 	return nil
 }
 
+func (srv *Server) Watch(context.Context) (<-chan tmux.Notification, error) {
+	ch := make(chan tmux.Notification, 64)
+	srv.watchers = append(srv.watchers, ch)
+	return ch, nil
+}
+
+// Notify sends a notification to everything that's watching this server.
+// Unlike real tmux, changing the server doesn't send notifications on its own.
+func (srv *Server) Notify(n tmux.Notification) {
+	for _, ch := range srv.watchers {
+		ch <- n
+	}
+}
+
 func (srv *Server) Kill(context.Context) error {
 	srv.sessions = nil
 	srv.CurrentSession = nil
+	srv.clients = nil
+	for _, ch := range srv.watchers {
+		close(ch)
+	}
+	srv.watchers = nil
 	return nil
 }
 
+func (srv *Server) Close() error { return nil }
+
 type Sessions []*Session
 
 var _ tmux.Sessions = (Sessions)(nil)
@@ -141,8 +188,9 @@ type Session struct {
 	srv *Server
 	id  string
 
-	props tmux.SessionPropertyValues
-	dead  bool
+	props   tmux.SessionPropertyValues
+	windows []tmux.Window
+	dead    bool
 }
 
 var _ tmux.Session = (*Session)(nil)
@@ -165,7 +213,12 @@ func (s *Session) Properties(_ context.Context, props ...tmux.SessionPropertyNam
 
 	ret := make(tmux.SessionPropertyValues, len(props))
 	for _, prop := range props {
-		ret[prop] = s.props[prop]
+		v, ok := s.props[prop]
+		if p, isString := prop.(tmux.SessionProperty[string]); !ok && isString {
+			// tmux expands unknown formats, like options that aren't set, to "".
+			v = p.Value("")
+		}
+		ret[prop] = v
 	}
 	return ret, nil
 }
@@ -177,6 +230,44 @@ func (s *Session) setProperty(v tmux.SessionPropertyValue) {
 	s.props.Set(v)
 }
 
+// touch records activity in the session. Each activity happens one second after
+// the last one on the same server.
+func (s *Session) touch() {
+	s.srv.clock++
+	s.setProperty(tmux.SessionActivity.Value(strconv.FormatInt(s.srv.clock, 10)))
+}
+
+func (s *Session) Windows(context.Context) ([]tmux.Window, error) {
+	if s.dead {
+		return nil, fmt.Errorf("session %q was killed", s.id)
+	}
+	return slices.Clone(s.windows), nil
+}
+
+func (s *Session) ReplaceWindows(_ context.Context, windows []tmux.Window) error {
+	if s.dead {
+		return fmt.Errorf("session %q was killed", s.id)
+	}
+	s.windows = slices.Clone(windows)
+	return nil
+}
+
+func (s *Session) SetUserOption(_ context.Context, name, value string) error {
+	if s.dead {
+		return fmt.Errorf("session %q was killed", s.id)
+	}
+	s.setProperty(tmux.UserOption(name).Value(value))
+	return nil
+}
+
+func (s *Session) SetStartDir(_ context.Context, dir string) error {
+	if s.dead {
+		return fmt.Errorf("session %q was killed", s.id)
+	}
+	s.setProperty(tmux.SessionPath.Value(dir))
+	return nil
+}
+
 func (s *Session) Rename(_ context.Context, n string) error {
 	if s.dead {
 		return fmt.Errorf("session %q was killed", s.id)
@@ -192,3 +283,56 @@ func (s *Session) Kill(context.Context) error {
 	s.dead = true
 	return nil
 }
+
+type Client struct {
+	srv *Server
+	tty string
+
+	// Session is the session that the client is displaying.
+	Session *Session
+}
+
+var _ tmux.Client = (*Client)(nil)
+
+func (c *Client) Property(ctx context.Context, prop tmux.ClientProperty) (string, error) {
+	props, err := c.Properties(ctx, prop)
+	if err != nil {
+		return "", err
+	}
+	return props[prop], nil
+}
+
+func (c *Client) Properties(_ context.Context, props ...tmux.ClientProperty) (map[tmux.ClientProperty]string, error) {
+	ret := make(map[tmux.ClientProperty]string, len(props))
+	for _, prop := range props {
+		switch prop {
+		case tmux.ClientTTY:
+			ret[prop] = c.tty
+		case tmux.ClientSessionID:
+			ret[prop] = c.Session.id
+		default:
+			return nil, fmt.Errorf("unsupported client property %q", prop)
+		}
+	}
+	return ret, nil
+}
+
+func (c *Client) Switch(ctx context.Context, sesh tmux.Session) error {
+	if !tmux.SameServer(ctx, c.srv, sesh.Server()) {
+		return fmt.Errorf("session %q does not belong to this server", sesh.ID())
+	}
+	if c.srv.sessions[sesh.ID()].dead {
+		return fmt.Errorf("session %q was killed", sesh.ID())
+	}
+	c.Session = c.srv.sessions[sesh.ID()]
+	c.Session.touch()
+	return nil
+}
+
+func (c *Client) DisplayMenu(context.Context, []tmux.MenuElement) error {
+	return fmt.Errorf("display-menu: %w", errors.ErrUnsupported)
+}
+
+func (c *Client) DisplayPopup(context.Context, tmux.PopupOptions) error {
+	return fmt.Errorf("display-popup: %w", errors.ErrUnsupported)
+}
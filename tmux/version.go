@@ -0,0 +1,121 @@
+package tmux
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Version is a tmux version, e.g. 3.3a.
+type Version struct {
+	Major, Minor int
+	// Patch is the letter tmux uses for bugfix releases, e.g. "a" in 3.3a.
+	Patch string
+}
+
+// The minimum tmux versions required for features this package relies on.
+var (
+	// display-menu.
+	displayMenuVersion = Version{Major: 3, Minor: 0}
+	// list-sessions -f.
+	listFilterVersion = Version{Major: 3, Minor: 1}
+	// attach-session -f in control mode.
+	controlModeVersion = Version{Major: 3, Minor: 2}
+	// display-popup.
+	displayPopupVersion = Version{Major: 3, Minor: 2}
+	// display-popup -T.
+	popupTitleVersion = Version{Major: 3, Minor: 3}
+)
+
+// features are the features of this package that need newer versions of tmux.
+var features = []struct {
+	name    string
+	version Version
+}{
+	{"display-menu", displayMenuVersion},
+	{"list-sessions -f", listFilterVersion},
+	{"control mode", controlModeVersion},
+	{"display-popup", displayPopupVersion},
+	{"display-popup -T", popupTitleVersion},
+}
+
+// Unsupported lists the features of this package that need a newer version of
+// tmux than v.
+func (v Version) Unsupported() []*UnsupportedError {
+	var ret []*UnsupportedError
+	for _, f := range features {
+		if !v.AtLeast(f.version) {
+			ret = append(ret, &UnsupportedError{Feature: f.name, Want: f.version, Got: v})
+		}
+	}
+	return ret
+}
+
+// tmux -V prints things like "tmux 3.3a", "tmux next-3.4" or "tmux 3.4-rc".
+var versionRegex = regexp.MustCompile(`^(?:tmux )?(?:next-)?(\d+)\.(\d+)([a-z]?)(?:-rc\d*)?$`)
+
+// ParseVersion parses the version strings that tmux reports about itself.
+func ParseVersion(s string) (Version, error) {
+	m := versionRegex.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return Version{}, fmt.Errorf("unrecognized tmux version %q", s)
+	}
+	major, err := strconv.Atoi(m[1])
+	if err != nil {
+		return Version{}, fmt.Errorf("tmux version %q: %w", s, err)
+	}
+	minor, err := strconv.Atoi(m[2])
+	if err != nil {
+		return Version{}, fmt.Errorf("tmux version %q: %w", s, err)
+	}
+	return Version{Major: major, Minor: minor, Patch: m[3]}, nil
+}
+
+// AtLeast determines whether v is the same as or newer than other.
+func (v Version) AtLeast(other Version) bool {
+	if v.Major != other.Major {
+		return v.Major > other.Major
+	}
+	if v.Minor != other.Minor {
+		return v.Minor > other.Minor
+	}
+	return v.Patch >= other.Patch
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d%s", v.Major, v.Minor, v.Patch)
+}
+
+// UnsupportedError indicates that the tmux server is too old for some feature.
+type UnsupportedError struct {
+	Feature string
+	Want    Version
+	Got     Version
+}
+
+func (err *UnsupportedError) Error() string {
+	return fmt.Sprintf("%s requires tmux ≥ %s, but found tmux %s", err.Feature, err.Want, err.Got)
+}
+
+// requireVersion returns an UnsupportedError if srv is older than want.
+// If srv's version cannot be determined, the feature is assumed to be
+// supported.
+func requireVersion(ctx context.Context, srv Server, want Version, feature string) error {
+	got, err := srv.Version(ctx)
+	if err != nil {
+		slog.Debug("Could not determine tmux version.", "server", srv, "error", err)
+		return nil
+	}
+	if !got.AtLeast(want) {
+		return &UnsupportedError{Feature: feature, Want: want, Got: got}
+	}
+	return nil
+}
+
+// supports determines whether srv is at least version want.
+func supports(ctx context.Context, srv Server, want Version) bool {
+	return requireVersion(ctx, srv, want, "") == nil
+}
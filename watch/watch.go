@@ -0,0 +1,14 @@
+// Package watch reports changes to files.
+package watch
+
+// Events reports the paths that were added to the Watcher when they change.
+// A single change might be reported more than once.
+// The channel is closed once the Watcher is closed.
+func (w *Watcher) Events() <-chan string {
+	return w.events
+}
+
+// Errors reports problems that happened while watching for changes.
+func (w *Watcher) Errors() <-chan error {
+	return w.errors
+}
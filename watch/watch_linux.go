@@ -0,0 +1,195 @@
+package watch
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// The events that indicate a file changed. Files are usually replaced by
+// renaming a temporary file over them, so watching directories instead of the
+// files themselves is more reliable.
+const mask = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_CLOSE_WRITE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO
+
+// Watcher reports changes to files using inotify.
+type Watcher struct {
+	// fd is f's file descriptor. f.Fd() would make f blocking again.
+	fd     int
+	f      *os.File
+	events chan string
+	errors chan error
+	done   chan struct{}
+
+	closeOnce sync.Once
+
+	mu sync.Mutex
+	// watches are keyed by inotify watch descriptor.
+	watches map[int32]*watch
+}
+
+// watch is a watched directory.
+type watch struct {
+	dir string
+	// root is the added directory that dir is in, if any.
+	root string
+	// files are the added files in dir, keyed by name.
+	files map[string]string
+}
+
+// New creates a Watcher that isn't watching anything yet.
+func New() (*Watcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("inotify_init1: %w", err)
+	}
+	w := &Watcher{
+		fd: fd,
+		// A non-blocking file descriptor lets Close interrupt read.
+		f:       os.NewFile(uintptr(fd), "inotify"),
+		events:  make(chan string),
+		errors:  make(chan error, 1),
+		done:    make(chan struct{}),
+		watches: make(map[int32]*watch),
+	}
+	go w.read()
+	return w, nil
+}
+
+// Add watches path, which is either a directory or a file. Directories are
+// watched recursively. Files don't need to exist yet, but their directory
+// does.
+func (w *Watcher) Add(path string) error {
+	path = filepath.Clean(path)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if fi, err := os.Stat(path); err == nil && fi.IsDir() {
+		return w.addDir(path, path)
+	}
+	wt, err := w.addWatch(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	wt.files[filepath.Base(path)] = path
+	return nil
+}
+
+// addDir watches dir and all of its subdirectories as part of root.
+func (w *Watcher) addDir(dir, root string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		wt, err := w.addWatch(path)
+		if err != nil {
+			return err
+		}
+		wt.root = root
+		return nil
+	})
+}
+
+func (w *Watcher) addWatch(dir string) (*watch, error) {
+	wd, err := syscall.InotifyAddWatch(w.fd, dir, mask)
+	if err != nil {
+		return nil, fmt.Errorf("inotify_add_watch %s: %w", dir, err)
+	}
+	wt, ok := w.watches[int32(wd)]
+	if !ok {
+		wt = &watch{dir: dir, files: make(map[string]string)}
+		w.watches[int32(wd)] = wt
+	}
+	return wt, nil
+}
+
+func (w *Watcher) read() {
+	defer close(w.events)
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := w.f.Read(buf)
+		if errors.Is(err, os.ErrClosed) {
+			return
+		} else if err != nil {
+			w.errors <- fmt.Errorf("reading inotify events: %w", err)
+			return
+		}
+		for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+			// struct inotify_event { int wd; uint32_t mask, cookie, len; char name[]; }
+			wd := int32(binary.NativeEndian.Uint32(buf[off:]))
+			mask := binary.NativeEndian.Uint32(buf[off+4:])
+			l := int(binary.NativeEndian.Uint32(buf[off+12:]))
+			name := strings.TrimRight(string(buf[off+syscall.SizeofInotifyEvent:off+syscall.SizeofInotifyEvent+l]), "\x00")
+			off += syscall.SizeofInotifyEvent + l
+
+			for _, path := range w.changed(wd, mask, name) {
+				select {
+				case w.events <- path:
+				case <-w.done:
+					return
+				}
+			}
+		}
+	}
+}
+
+// changed determines which added paths an inotify event is for.
+func (w *Watcher) changed(wd int32, mask uint32, name string) []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if mask&syscall.IN_Q_OVERFLOW != 0 {
+		// Some events were dropped, so anything could have changed.
+		var all []string
+		for _, wt := range w.watches {
+			if wt.root == wt.dir {
+				all = append(all, wt.root)
+			}
+			for _, path := range wt.files {
+				all = append(all, path)
+			}
+		}
+		return all
+	}
+	wt, ok := w.watches[wd]
+	if !ok {
+		return nil
+	}
+	if mask&syscall.IN_IGNORED != 0 {
+		// The directory was removed.
+		delete(w.watches, wd)
+		return nil
+	}
+
+	var ret []string
+	if wt.root != "" {
+		if mask&syscall.IN_ISDIR != 0 && mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+			if err := w.addDir(filepath.Join(wt.dir, name), wt.root); err != nil {
+				slog.Warn("Could not watch new directory.", "dir", filepath.Join(wt.dir, name), "error", err)
+			}
+		}
+		ret = append(ret, wt.root)
+	}
+	if path, ok := wt.files[name]; ok {
+		ret = append(ret, path)
+	}
+	return ret
+}
+
+// Close stops watching for changes.
+// It's safe to call more than once.
+func (w *Watcher) Close() error {
+	var err error
+	w.closeOnce.Do(func() {
+		close(w.done)
+		err = w.f.Close()
+	})
+	return err
+}
@@ -0,0 +1,116 @@
+//go:build !linux
+
+package watch
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// pollInterval is how often files are checked for changes.
+const pollInterval = time.Second
+
+// Watcher reports changes to files by periodically checking their
+// modification times.
+type Watcher struct {
+	events chan string
+	errors chan error
+	done   chan struct{}
+
+	closeOnce sync.Once
+
+	mu sync.Mutex
+	// fingerprints describe the state of each added path when it was last
+	// checked.
+	fingerprints map[string]string
+}
+
+// New creates a Watcher that isn't watching anything yet.
+func New() (*Watcher, error) {
+	w := &Watcher{
+		events:       make(chan string),
+		errors:       make(chan error, 1),
+		done:         make(chan struct{}),
+		fingerprints: make(map[string]string),
+	}
+	go w.poll()
+	return w, nil
+}
+
+// Add watches path, which is either a directory or a file. Directories are
+// watched recursively. Files don't need to exist yet, but their directory
+// does.
+func (w *Watcher) Add(path string) error {
+	path = filepath.Clean(path)
+	if _, err := os.Stat(filepath.Dir(path)); err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.fingerprints[path] = fingerprint(path)
+	return nil
+}
+
+func (w *Watcher) poll() {
+	defer close(w.events)
+	t := time.NewTicker(pollInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+		case <-w.done:
+			return
+		}
+		for _, path := range w.changed() {
+			select {
+			case w.events <- path:
+			case <-w.done:
+				return
+			}
+		}
+	}
+}
+
+// changed determines which added paths changed since they were last checked.
+func (w *Watcher) changed() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var ret []string
+	for path, old := range w.fingerprints {
+		if cur := fingerprint(path); cur != old {
+			w.fingerprints[path] = cur
+			ret = append(ret, path)
+		}
+	}
+	return ret
+}
+
+// fingerprint summarizes the modification times and sizes of path and, if it's
+// a directory, everything in it.
+func fingerprint(path string) string {
+	var sb strings.Builder
+	filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		fmt.Fprintf(&sb, "%s %d %d\n", p, fi.ModTime().UnixNano(), fi.Size())
+		return nil
+	})
+	return sb.String()
+}
+
+// Close stops watching for changes.
+// It's safe to call more than once.
+func (w *Watcher) Close() error {
+	w.closeOnce.Do(func() { close(w.done) })
+	return nil
+}
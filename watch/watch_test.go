@@ -0,0 +1,87 @@
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatcher(t *testing.T) {
+	dir := t.TempDir()
+	head := filepath.Join(dir, "HEAD")
+	heads := filepath.Join(dir, "refs", "heads")
+	if err := os.MkdirAll(heads, 0700); err != nil {
+		t.Fatal(err)
+	}
+	w, err := New()
+	if err != nil {
+		t.Fatalf("New() = _, %v", err)
+	}
+	defer w.Close()
+	for _, p := range []string{head, heads} {
+		if err := w.Add(p); err != nil {
+			t.Fatalf("w.Add(%q) = %v", p, err)
+		}
+	}
+
+	// wait waits for a change to path, ignoring changes to other paths.
+	wait := func(path string) {
+		t.Helper()
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case got, ok := <-w.Events():
+				if !ok {
+					t.Fatalf("w.Events() closed while waiting for %q", path)
+				}
+				if got == path {
+					return
+				}
+			case err := <-w.Errors():
+				t.Fatalf("w.Errors() = %v", err)
+			case <-timeout:
+				t.Fatalf("Timed out waiting for a change to %q", path)
+			}
+		}
+	}
+	write := func(path, content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Unrelated files in the same directory aren't reported.
+	write(filepath.Join(dir, "index"), "abc")
+	// Replace HEAD the way git does.
+	write(head+".lock", "ref: refs/heads/main\n")
+	if err := os.Rename(head+".lock", head); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case got := <-w.Events():
+		if got != head {
+			t.Errorf("First change = %q, want %q", got, head)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for a change to %q", head)
+	}
+
+	write(filepath.Join(heads, "main"), "abc\n")
+	wait(heads)
+	// Directories are watched recursively, even if they're created later.
+	if err := os.Mkdir(filepath.Join(heads, "feature"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	wait(heads)
+	write(filepath.Join(heads, "feature", "foo"), "abc\n")
+	wait(heads)
+
+	if err := w.Close(); err != nil {
+		t.Errorf("w.Close() = %v", err)
+	}
+	for range w.Events() {
+		// Drain the channel until Close closes it.
+	}
+}